@@ -0,0 +1,42 @@
+package maestro
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+//go:embed assets/logos
+var assets embed.FS
+
+// Logos are the streaming services' logos, as served by /services/{serviceName}/logo.
+// The ones built into the binary can be overridden by putting a file with the same name in dir.
+func Logos(dir string) fs.FS {
+	embedded, err := fs.Sub(assets, "assets/logos")
+	if err != nil {
+		// Only happens if the directory above is renamed without updating this
+		panic(err)
+	}
+
+	if len(dir) == 0 {
+		return embedded
+	}
+
+	return &overlayFS{os.DirFS(dir), embedded}
+}
+
+// overlayFS opens files from the top FS, and falls back to the bottom one if they aren't there
+type overlayFS struct {
+	top    fs.FS
+	bottom fs.FS
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.bottom.Open(name)
+	}
+
+	return f, err
+}
@@ -8,6 +8,10 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/redis/go-redis/v9"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
 	"github.com/yukitsune/maestro/pkg/streamingservice/provider"
 
 	"github.com/sirupsen/logrus"
@@ -16,12 +20,16 @@ import (
 	"github.com/yukitsune/lokirus"
 	"github.com/yukitsune/maestro"
 	"github.com/yukitsune/maestro/internal/grace"
+	"github.com/yukitsune/maestro/pkg/analytics"
 	"github.com/yukitsune/maestro/pkg/api"
+	"github.com/yukitsune/maestro/pkg/api/handlers"
 	"github.com/yukitsune/maestro/pkg/config"
 	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/worker"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"golang.org/x/sync/singleflight"
 )
 
 func main() {
@@ -77,16 +85,47 @@ func serve(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
-	serviceProvider, err := provider.NewDefaultProvider(cfg.Services(), rec)
+	resolver := setupRedirectResolver(cfg.Services().ShortLinks())
+
+	serviceProvider, err := provider.NewDefaultProvider(cfg.Services(), resolver, rec)
 	if err != nil {
 		return err
 	}
 
-	maestroAPI, err := api.NewMaestroServer(cfg.API(), serviceProvider, repo, rec, logger)
+	analyticsRec := analytics.NewRecorder(cfg.Analytics(), repo, logger)
+
+	// Concurrent lookups for the same thing are shared, whether they come from a request or a worker
+	group := &singleflight.Group{}
+
+	maestroAPI, err := api.NewMaestroServer(cfg.API(), serviceProvider, repo, resolver, group, rec, analyticsRec, logger)
 	if err != nil {
 		grace.ExitFromError(err)
 	}
 
+	// Background workers run until we're shut down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	if cfg.Workers().Resolve().Enabled() {
+		resolveWorker := worker.NewResolveWorker(cfg.Workers().Resolve(), serviceProvider, repo, handlers.NewResolver(serviceProvider, repo, group, logger), logger)
+		go resolveWorker.Run(workerCtx)
+	}
+
+	if cfg.Workers().LinkHealth().Enabled() {
+		linkHealthWorker := worker.NewLinkHealthWorker(cfg.Workers().LinkHealth(), serviceProvider, repo, logger)
+		go linkHealthWorker.Run(workerCtx)
+	}
+
+	if cfg.Workers().Trending().Enabled() {
+		// Trending is worked out from the lookups which analytics records, so there's nothing to go on without it
+		if !cfg.Analytics().Enabled() {
+			logger.Warnln("the trending worker is enabled but analytics is disabled, /trending will always be empty")
+		}
+
+		trendingWorker := worker.NewTrendingWorker(cfg.Workers().Trending(), repo, logger)
+		go trendingWorker.Run(workerCtx)
+	}
+
 	// Run our server in a goroutine so that it doesn't block.
 	errorChan := make(chan error, 1)
 	go func() {
@@ -96,6 +135,8 @@ func serve(_ *cobra.Command, _ []string) error {
 	}()
 
 	grace.WaitForShutdownSignalOrError(errorChan, func() {
+		stopWorkers()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		_ = maestroAPI.Shutdown(ctx)
@@ -160,6 +201,12 @@ func configureLogger(cfg config.Logging, logger *logrus.Logger) {
 	}
 }
 
+func setupRedirectResolver(cfg config.ShortLinks) clients.RedirectResolver {
+	// Short links are resolved the same way every time, so there's no need to share them between replicas
+	c := cache.NewLRUCache(cfg.CacheSize(), cfg.CacheTTL())
+	return clients.NewRedirectResolver(streamingservice.ShortLinkHosts, cfg.MaxHops(), cfg.Timeout(), c, clients.NewPublicTransport())
+}
+
 func setupRepository(cfg config.Database, rec metrics.Recorder, logger *logrus.Logger) (db.Repository, error) {
 	opts := options.Client().ApplyURI(cfg.Uri())
 	client, err := mongo.NewClient(opts)
@@ -182,5 +229,29 @@ func setupRepository(cfg config.Database, rec metrics.Recorder, logger *logrus.L
 
 	mdb := client.Database(cfg.Name())
 	repo := db.NewMongoRepository(mdb, rec, logger)
-	return repo, nil
+
+	cacheCfg := cfg.Cache()
+	if !cacheCfg.Enabled() {
+		return repo, nil
+	}
+
+	var c cache.Cache
+	if cacheCfg.Redis().Enabled() {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cacheCfg.Redis().Address(),
+			Password: cacheCfg.Redis().Password(),
+			DB:       cacheCfg.Redis().Database(),
+		})
+
+		err = redisClient.Ping(ctx).Err()
+		if err != nil {
+			return nil, err
+		}
+
+		c = cache.NewRedisCache(redisClient, cacheCfg.Redis().KeyPrefix(), cacheCfg.TTL())
+	} else {
+		c = cache.NewLRUCache(cacheCfg.Size(), cacheCfg.TTL())
+	}
+
+	return db.NewCachedRepository(repo, c, rec, logger), nil
 }
@@ -0,0 +1,20 @@
+package analytics
+
+import (
+	"net/http"
+
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+type noopRecorder struct{}
+
+// NewNoopRecorder creates a Recorder which discards everything it's given
+func NewNoopRecorder() Recorder {
+	return &noopRecorder{}
+}
+
+func (n *noopRecorder) RecordClick(_ *http.Request, _ model.Type, _ string, _ model.StreamingServiceType) {
+}
+
+func (n *noopRecorder) RecordResolution(_ *http.Request, _ model.Type, _ string, _ model.StreamingServiceType) {
+}
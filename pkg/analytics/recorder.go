@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// Recording an event should never hold anything up, so it's given up on if the database is being slow
+const recordTimeout = 5 * time.Second
+
+const defaultQueueSize = 1000
+
+type Recorder interface {
+	// RecordClick counts someone following a link out to the given service
+	RecordClick(r *http.Request, typ model.Type, id string, target model.StreamingServiceType)
+
+	// RecordResolution counts someone looking up a link from the given service.
+	// The source is empty when something was looked up by ISRC or UPC.
+	RecordResolution(r *http.Request, typ model.Type, id string, source model.StreamingServiceType)
+}
+
+type repositoryRecorder struct {
+	cfg    config.Analytics
+	repo   db.Repository
+	queue  chan *model.AnalyticsEvent
+	logger *logrus.Logger
+}
+
+// NewRecorder creates a Recorder which stores events in the given Repository.
+// Events are queued up and stored one at a time in the background, so recording them doesn't slow down the request.
+// If the database can't keep up and the queue fills up, new events are dropped.
+func NewRecorder(cfg config.Analytics, repo db.Repository, logger *logrus.Logger) Recorder {
+	if !cfg.Enabled() {
+		return NewNoopRecorder()
+	}
+
+	queueSize := cfg.QueueSize()
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	rec := &repositoryRecorder{cfg, repo, make(chan *model.AnalyticsEvent, queueSize), logger}
+	go rec.run()
+
+	return rec
+}
+
+func (rec *repositoryRecorder) RecordClick(r *http.Request, typ model.Type, id string, target model.StreamingServiceType) {
+	event := rec.newEvent(r, model.ClickEvent, typ, id)
+	event.Target = target
+
+	rec.enqueue(event)
+}
+
+func (rec *repositoryRecorder) RecordResolution(r *http.Request, typ model.Type, id string, source model.StreamingServiceType) {
+	event := rec.newEvent(r, model.ResolveEvent, typ, id)
+	event.Source = source
+
+	rec.enqueue(event)
+}
+
+func (rec *repositoryRecorder) newEvent(r *http.Request, kind model.AnalyticsEventKind, typ model.Type, id string) *model.AnalyticsEvent {
+	event := &model.AnalyticsEvent{
+		Kind: kind,
+		Type: typ,
+		Id:   id,
+		Time: time.Now().UTC(),
+	}
+
+	if !rec.cfg.PrivacyMode() {
+		event.IPAddress = remoteIP(r)
+		event.UserAgent = r.UserAgent()
+	}
+
+	return event
+}
+
+func (rec *repositoryRecorder) enqueue(event *model.AnalyticsEvent) {
+	select {
+	case rec.queue <- event:
+	default:
+		rec.logger.Warnf("analytics queue is full, dropping %s event for %s %s", event.Kind, event.Type, event.Id)
+	}
+}
+
+func (rec *repositoryRecorder) run() {
+	for event := range rec.queue {
+		rec.record(event)
+	}
+}
+
+func (rec *repositoryRecorder) record(event *model.AnalyticsEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	err := rec.repo.RecordEvent(ctx, event)
+	if err != nil {
+		rec.logger.Errorf("failed to record %s event for %s %s: %s", event.Kind, event.Type, event.Id, err.Error())
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
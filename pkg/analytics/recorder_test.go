@@ -0,0 +1,151 @@
+package analytics_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/analytics"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// eventRepository hands over every event it's given, so tests can wait for them
+type eventRepository struct {
+	db.Repository
+	events chan *model.AnalyticsEvent
+}
+
+func (r *eventRepository) RecordEvent(_ context.Context, event *model.AnalyticsEvent) error {
+	r.events <- event
+	return nil
+}
+
+func newTestRecorder(privacyMode bool) (analytics.Recorder, *eventRepository) {
+	v := viper.New()
+	v.Set("analytics.privacy_mode", privacyMode)
+
+	repo := &eventRepository{db.NewInMemoryRepository(), make(chan *model.AnalyticsEvent, 1)}
+	return analytics.NewRecorder(config.NewAnalyticsViperConfig(v), repo, logrus.New()), repo
+}
+
+func waitForEvent(t *testing.T, repo *eventRepository) *model.AnalyticsEvent {
+	select {
+	case event := <-repo.events:
+		return event
+	case <-time.After(time.Second):
+		require.FailNow(t, "event was never recorded")
+		return nil
+	}
+}
+
+func Test_RecorderPrivacyMode(t *testing.T) {
+	testCases := []struct {
+		name            string
+		privacyMode     bool
+		expectIPAddress string
+		expectUserAgent string
+	}{
+		{
+			name:        "privacy mode",
+			privacyMode: true,
+		},
+		{
+			name:            "no privacy mode",
+			privacyMode:     false,
+			expectIPAddress: "192.0.2.1",
+			expectUserAgent: "test-agent",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rec, repo := newTestRecorder(testCase.privacyMode)
+
+			req := httptest.NewRequest("GET", "/go/track/USUM71703861", nil)
+			req.Header.Set("User-Agent", "test-agent")
+
+			rec.RecordClick(req, model.TrackType, "USUM71703861", model.SpotifyStreamingService)
+
+			event := waitForEvent(t, repo)
+			assert.Equal(t, model.ClickEvent, event.Kind)
+			assert.Equal(t, model.TrackType, event.Type)
+			assert.Equal(t, "USUM71703861", event.Id)
+			assert.Equal(t, model.SpotifyStreamingService, event.Target)
+			assert.Empty(t, event.Source)
+			assert.False(t, event.Time.IsZero())
+			assert.Equal(t, testCase.expectIPAddress, event.IPAddress)
+			assert.Equal(t, testCase.expectUserAgent, event.UserAgent)
+		})
+	}
+}
+
+func Test_RecorderResolution(t *testing.T) {
+	rec, repo := newTestRecorder(true)
+
+	rec.RecordResolution(httptest.NewRequest("GET", "/link", nil), model.AlbumType, "album-1", model.DeezerStreamingService)
+
+	event := waitForEvent(t, repo)
+	assert.Equal(t, model.ResolveEvent, event.Kind)
+	assert.Equal(t, model.DeezerStreamingService, event.Source)
+	assert.Empty(t, event.Target)
+}
+
+// blockingRepository holds on to each event until it's released, like a database which can't keep up
+type blockingRepository struct {
+	db.Repository
+	started chan *model.AnalyticsEvent
+	release chan struct{}
+}
+
+func (r *blockingRepository) RecordEvent(_ context.Context, event *model.AnalyticsEvent) error {
+	r.started <- event
+	<-r.release
+	return nil
+}
+
+func Test_RecorderDropsEventsWhenTheQueueIsFull(t *testing.T) {
+	v := viper.New()
+	v.Set("analytics.queue_size", 1)
+
+	repo := &blockingRepository{db.NewInMemoryRepository(), make(chan *model.AnalyticsEvent), make(chan struct{})}
+	rec := analytics.NewRecorder(config.NewAnalyticsViperConfig(v), repo, logrus.New())
+	req := httptest.NewRequest("GET", "/link", nil)
+
+	rec.RecordResolution(req, model.TrackType, "first", "")
+
+	var event *model.AnalyticsEvent
+	select {
+	case event = <-repo.started:
+	case <-time.After(time.Second):
+		require.FailNow(t, "event was never recorded")
+	}
+
+	assert.Equal(t, "first", event.Id)
+
+	// The first event is still being stored, so the second is queued and the third has nowhere to go
+	rec.RecordResolution(req, model.TrackType, "second", "")
+	rec.RecordResolution(req, model.TrackType, "third", "")
+	repo.release <- struct{}{}
+
+	select {
+	case event = <-repo.started:
+	case <-time.After(time.Second):
+		require.FailNow(t, "event was never recorded")
+	}
+
+	assert.Equal(t, "second", event.Id)
+	repo.release <- struct{}{}
+
+	select {
+	case event = <-repo.started:
+		assert.Fail(t, "dropped event was recorded", event.Id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,7 +1,13 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -24,14 +30,133 @@ func GetAlbumByIdHandler(repo db.Repository) http.HandlerFunc {
 			return
 		}
 
-		if foundAlbums == nil || len(foundAlbums) == 0 {
+		res := NewResult[*model.Album](model.AlbumType)
+		res.AddAll(foundAlbums)
+
+		// Everything we know about might be dead
+		if !res.HasResults() {
 			responses.NotFoundf(w, "could not find any albums with ID %s", id)
 			return
 		}
 
-		res := NewResult[*model.Album](model.AlbumType)
-		res.AddAll(foundAlbums)
-
 		responses.Response(w, res, http.StatusOK)
 	}
 }
+
+func findForUpc(ctx context.Context, upc string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (*Result[*model.Album], error) {
+
+	// Concurrent lookups for the same UPC can share the same result
+	key := fmt.Sprintf("upc:%s", upc)
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		return lookupUpc(detach(ctx), upc, serviceProvider, repo, logger.WithField("upc", upc))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Result[*model.Album]), nil
+}
+
+func lookupUpc(ctx context.Context, upc string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (*Result[*model.Album], error) {
+
+	svcs, err := serviceProvider.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize services: %s", err.Error())
+	}
+
+	res := NewResult[*model.Album](model.AlbumType)
+
+	// Albums with the same UPC share an ID, so we can find everything else we know about from any one of them
+	var id string
+	foundAlbum, err := repo.GetAlbumByUpc(ctx, upc)
+	if err != nil {
+		return nil, err
+	}
+
+	if foundAlbum != nil {
+		id = foundAlbum.AlbumId
+
+		existingAlbums, err := repo.GetAlbumsById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		res.AddAll(existingAlbums)
+	}
+
+	if len(res.Items) != len(svcs) {
+		var newAlbums []*model.Album
+		for key, svc := range svcs {
+			if res.HasResultFor(key) {
+				continue
+			}
+
+			album, found, err := svc.GetAlbumByUpc(upc)
+			if err != nil {
+				logServiceError(logger, key, err)
+				res.SetError(key, err)
+				continue
+			}
+
+			if !found || res.IsDeadLink(album.Link) {
+				res.SetNotFound(key)
+				continue
+			}
+
+			// We might already know about the album from before we knew its UPC
+			existing, err := repo.GetAlbumByLink(ctx, album.Link)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				if existing.IsDead() {
+					res.SetNotFound(key)
+					continue
+				}
+
+				if len(id) == 0 {
+					id = existing.AlbumId
+				}
+
+				// It was matched up with something else before we knew its UPC, but the UPC says it belongs here
+				if existing.AlbumId != id {
+					if err := repo.UpdateAlbumId(ctx, existing, id); err != nil {
+						return nil, err
+					}
+
+					logger.WithField("album_id", id).Infof("moved %s from album %s", existing.Link, existing.AlbumId)
+					existing.AlbumId = id
+				}
+
+				res.Add(existing)
+				continue
+			}
+
+			newAlbums = append(newAlbums, album)
+		}
+
+		// Only the albums we found are added, so any services which failed will be tried again next time
+		if len(newAlbums) > 0 {
+			if len(id) == 0 {
+				id = uuid.New().String()
+			}
+
+			for _, album := range newAlbums {
+				album.AlbumId = id
+			}
+
+			n, err := repo.AddAlbum(ctx, newAlbums)
+			if err != nil {
+				return nil, err
+			}
+
+			logger.WithField("album_id", id).Infof("%d new albums added", n)
+			res.AddAll(newAlbums)
+		}
+	}
+
+	setDisabled(res, serviceProvider)
+	return res, nil
+}
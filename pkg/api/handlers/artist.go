@@ -24,14 +24,15 @@ func GetArtistByIdHandler(repo db.Repository) http.HandlerFunc {
 			return
 		}
 
-		if foundArtists == nil || len(foundArtists) == 0 {
+		res := NewResult[*model.Artist](model.ArtistType)
+		res.AddAll(foundArtists)
+
+		// Everything we know about might be dead
+		if !res.HasResults() {
 			responses.NotFoundf(w, "could not find any artists with ID %s", id)
 			return
 		}
 
-		res := NewResult[*model.Artist](model.ArtistType)
-		res.AddAll(foundArtists)
-
 		responses.Response(w, res, http.StatusOK)
 	}
 }
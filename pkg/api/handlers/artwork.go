@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultArtworkSize = 300
+	minArtworkSize     = 16
+	maxArtworkSize     = 1500
+
+	artworkQuality     = 85
+	artworkCacheMaxAge = 24 * time.Hour
+)
+
+// artworkEncoder writes artwork in one of the formats which can be asked for
+type artworkEncoder struct {
+	contentType string
+	encode      func(img image.Image) ([]byte, error)
+}
+
+var artworkEncoders = map[string]artworkEncoder{
+	"jpeg": {
+		contentType: "image/jpeg",
+		encode: func(img image.Image) ([]byte, error) {
+			var buf bytes.Buffer
+			err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: artworkQuality})
+			return buf.Bytes(), err
+		},
+	},
+}
+
+// unsupportedArtworkFormats are formats which can reasonably be asked for, but which can't be written yet
+var unsupportedArtworkFormats = map[string]bool{
+	"webp": true,
+}
+
+// Deezer serves its artwork in whatever size is in the link, up to 1000x1000
+var deezerArtworkSize = regexp.MustCompile(`^(https://[^/]+\.dzcdn\.net/images/[a-z]+/[0-9a-f]+/)\d+x\d+(-.*)$`)
+
+// GetArtworkHandler serves the artwork for something at a consistent size, so that clients don't have to
+// deal with each service's CDN (or tell it who they are).
+// The biggest artwork any of the services has is used, and the resized copy is cached on disk.
+func GetArtworkHandler(repo db.Repository, client *http.Client, artworkCache cache.Cache, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		vars := mux.Vars(r)
+		typ := model.Type(vars["type"])
+		id, ok := vars["id"]
+		if !ok {
+			responses.BadRequest(w, "missing parameter \"id\"")
+			return
+		}
+
+		if !isShareable(typ) {
+			responses.BadRequestf(w, "unknown type %s", typ)
+			return
+		}
+
+		q := r.URL.Query()
+		size, err := intParameter(q.Get("size"), defaultArtworkSize, minArtworkSize, maxArtworkSize)
+		if err != nil {
+			responses.BadRequestf(w, "invalid size: %s", err.Error())
+			return
+		}
+
+		format := strings.ToLower(q.Get("format"))
+		if len(format) == 0 {
+			format = "jpeg"
+		}
+
+		if unsupportedArtworkFormats[format] {
+			responses.NotImplementedf(w, "artwork can't be served as %s yet, use format=jpeg instead", format)
+			return
+		}
+
+		encoder, ok := artworkEncoders[format]
+		if !ok {
+			responses.BadRequestf(w, "unknown format %s, expected jpeg", format)
+			return
+		}
+
+		key := fmt.Sprintf("artwork:%s:%s:%d:%s", typ, id, size, format)
+		body, ok, err := artworkCache.Get(r.Context(), key)
+		if err != nil {
+			reqLogger.Warnf("failed to read artwork from the cache: %s", err.Error())
+		}
+
+		if !ok {
+			res, err, _ := group.Do(key, func() (any, error) {
+				// Anyone else waiting on this shouldn't miss out if this request goes away
+				ctx := detach(r.Context())
+				body, err := renderArtwork(ctx, repo, client, typ, id, size, encoder, reqLogger)
+				if err != nil || body == nil {
+					return nil, err
+				}
+
+				if err := artworkCache.Set(ctx, key, body); err != nil {
+					reqLogger.Warnf("failed to cache artwork: %s", err.Error())
+				}
+
+				return body, nil
+			})
+
+			if err != nil {
+				responses.Error(w, err)
+				return
+			}
+
+			if res == nil {
+				responses.NotFoundf(w, "could not find any artwork for the %s with ID %s", typ, id)
+				return
+			}
+
+			body = res.([]byte)
+		}
+
+		hash := sha256.Sum256(body)
+		w.Header().Set("Content-Type", encoder.contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(artworkCacheMaxAge.Seconds())))
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])))
+
+		// Takes care of If-None-Match for us
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	}
+}
+
+// renderArtwork finds the biggest artwork for the thing and resizes it.
+// Returns nil if the thing doesn't exist, or none of the services have artwork for it.
+func renderArtwork(ctx context.Context, repo db.Repository, client *http.Client, typ model.Type, id string, size int, encoder artworkEncoder, logger *logrus.Entry) ([]byte, error) {
+	things, err := findThings(ctx, repo, typ, id)
+	if err != nil {
+		return nil, err
+	}
+
+	img := largestArtwork(ctx, client, artworkLinks(things), logger)
+	if img == nil {
+		return nil, nil
+	}
+
+	return encoder.encode(resizeArtwork(img, size))
+}
+
+// artworkLinks lists the links to the largest version of each service's artwork, in the order of the services
+func artworkLinks(things []model.Thing) []string {
+	sorted := make([]model.Thing, len(things))
+	copy(sorted, things)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetSource() < sorted[j].GetSource()
+	})
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, thing := range sorted {
+		link := largestArtworkLink(thing.GetArtworkLink())
+		if len(link) == 0 || seen[link] {
+			continue
+		}
+
+		seen[link] = true
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// largestArtworkLink asks for the biggest version of the artwork the service has.
+// Apple Music and Spotify links already point to the biggest one.
+func largestArtworkLink(link string) string {
+	if m := deezerArtworkSize.FindStringSubmatch(link); m != nil {
+		return m[1] + "1000x1000" + m[2]
+	}
+
+	// e.g. https://api.deezer.com/album/302127/image, which redirects to the CDN
+	u, err := url.Parse(link)
+	if err == nil && u.Host == "api.deezer.com" && strings.HasSuffix(u.Path, "/image") {
+		q := u.Query()
+		q.Set("size", "xl")
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	return link
+}
+
+// largestArtwork fetches all the artwork and returns the one with the most pixels. Only the headers are decoded to
+// find out which one that is, so only the largest is decoded in full.
+// Artwork which can't be fetched, or which is too large, is skipped. Returns nil if none of it could be used.
+func largestArtwork(ctx context.Context, client *http.Client, links []string, logger *logrus.Entry) image.Image {
+	downloaded := make([]*downloadedArtwork, len(links))
+
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+
+			artwork, err := downloadArtwork(ctx, client, link)
+			if err != nil {
+				logger.Warnf("failed to fetch artwork from %s: %s", link, err.Error())
+				return
+			}
+
+			downloaded[i] = artwork
+		}(i, link)
+	}
+
+	wg.Wait()
+
+	var largest *downloadedArtwork
+	for _, artwork := range downloaded {
+		if artwork != nil && (largest == nil || artwork.pixels() > largest.pixels()) {
+			largest = artwork
+		}
+	}
+
+	if largest == nil {
+		return nil
+	}
+
+	img, err := largest.decode()
+	if err != nil {
+		logger.Warnf("failed to decode artwork: %s", err.Error())
+		return nil
+	}
+
+	return img
+}
+
+// resizeArtwork scales the artwork down to fit in a size x size square. It's never scaled up, that only makes
+// it bigger without making it look any better.
+// Transparent artwork is put on a white background since not every format can be transparent.
+func resizeArtwork(img image.Image, size int) image.Image {
+	src := img.Bounds()
+	width, height := src.Dx(), src.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, height*size/width
+		} else {
+			width, height = width*size/height, size
+		}
+	}
+
+	if width < 1 {
+		width = 1
+	}
+
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+type artworkServer struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+// newSizedArtworkServer serves solid squares, red ones at /large.png and blue ones at /small.png.
+// /huge.png is only the header of a PNG which is far too big to decode.
+func newSizedArtworkServer(t *testing.T) *artworkServer {
+	encode := func(size int, c color.Color) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		for x := 0; x < size; x++ {
+			for y := 0; y < size; y++ {
+				img.Set(x, y, c)
+			}
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+		return buf.Bytes()
+	}
+
+	images := map[string][]byte{
+		"/large.png": encode(64, color.RGBA{R: 255, A: 255}),
+		"/small.png": encode(32, color.RGBA{B: 255, A: 255}),
+		"/huge.png":  pngHeader(50000, 50000),
+	}
+
+	srv := &artworkServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.requests.Add(1)
+
+		body, ok := images[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestArtworkHandler(t *testing.T, f *testFixture, client *http.Client) http.HandlerFunc {
+	c, err := cache.NewDiskCache(t.TempDir(), 1<<20, time.Hour)
+	require.NoError(t, err)
+
+	return GetArtworkHandler(f.repo, client, c, &singleflight.Group{}, testLogger().Logger)
+}
+
+func getArtwork(handler http.HandlerFunc, typ string, id string, query string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/artwork/"+typ+"/"+id+query, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+	req = mux.SetURLVars(req, map[string]string{"type": typ, "id": id})
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// addArtworkTracks stores the track on Spotify and Deezer with the given artwork
+func addArtworkTracks(t *testing.T, f *testFixture, spotifyArtwork string, deezerArtwork string) {
+	spotifyTrack := sstesting.Track(model.SpotifyStreamingService)
+	spotifyTrack.ArtworkLink = spotifyArtwork
+
+	deezerTrack := sstesting.Track(model.DeezerStreamingService)
+	deezerTrack.ArtworkLink = deezerArtwork
+
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{spotifyTrack, deezerTrack})
+	require.NoError(t, err)
+}
+
+func Test_ArtworkUsesTheLargestArtwork(t *testing.T) {
+	f := newTestFixture()
+	srv := newSizedArtworkServer(t)
+	addArtworkTracks(t, f, srv.URL+"/small.png", srv.URL+"/large.png")
+
+	handler := newTestArtworkHandler(t, f, srv.Client())
+	rec := getArtwork(handler, "track", sstesting.Isrc, "?size=48", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=86400", rec.Header().Get("Cache-Control"))
+
+	img, err := jpeg.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 48, 48), img.Bounds())
+
+	r, g, b, _ := img.At(24, 24).RGBA()
+	assert.Greater(t, r, uint32(0xf000))
+	assert.Less(t, g, uint32(0x1000))
+	assert.Less(t, b, uint32(0x1000))
+}
+
+func Test_ArtworkIsNotScaledUp(t *testing.T) {
+	f := newTestFixture()
+	srv := newSizedArtworkServer(t)
+	addArtworkTracks(t, f, srv.URL+"/small.png", srv.URL+"/missing.png")
+
+	handler := newTestArtworkHandler(t, f, srv.Client())
+	rec := getArtwork(handler, "track", sstesting.Isrc, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	img, err := jpeg.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 32, 32), img.Bounds())
+}
+
+func Test_ArtworkSkipsHugeArtwork(t *testing.T) {
+	f := newTestFixture()
+	srv := newSizedArtworkServer(t)
+	addArtworkTracks(t, f, srv.URL+"/small.png", srv.URL+"/huge.png")
+
+	handler := newTestArtworkHandler(t, f, srv.Client())
+	rec := getArtwork(handler, "track", sstesting.Isrc, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	img, err := jpeg.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 32, 32), img.Bounds())
+}
+
+func Test_ArtworkIsStillCachedWhenTheRequestGoesAway(t *testing.T) {
+	f := newTestFixture()
+	srv := newSizedArtworkServer(t)
+	addArtworkTracks(t, f, srv.URL+"/small.png", srv.URL+"/large.png")
+
+	c, err := cache.NewDiskCache(t.TempDir(), 1<<20, time.Hour)
+	require.NoError(t, err)
+
+	handler := GetArtworkHandler(f.repo, srv.Client(), c, &singleflight.Group{}, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/artwork/track/"+sstesting.Isrc, nil)
+	ctx, cancel := context.WithCancel(mcontext.WithRequestID(req.Context(), "test-request"))
+	cancel()
+
+	req = mux.SetURLVars(req.WithContext(ctx), map[string]string{"type": "track", "id": sstesting.Isrc})
+	handler(httptest.NewRecorder(), req)
+
+	_, ok, err := c.Get(context.Background(), fmt.Sprintf("artwork:track:%s:%d:jpeg", sstesting.Isrc, defaultArtworkSize))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func Test_ArtworkIsCached(t *testing.T) {
+	f := newTestFixture()
+	srv := newSizedArtworkServer(t)
+	addArtworkTracks(t, f, srv.URL+"/small.png", srv.URL+"/large.png")
+
+	handler := newTestArtworkHandler(t, f, srv.Client())
+	first := getArtwork(handler, "track", sstesting.Isrc, "?size=20&format=jpeg", nil)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, int32(2), srv.requests.Load())
+
+	second := getArtwork(handler, "track", sstesting.Isrc, "?size=20&format=jpeg", nil)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.Bytes(), second.Body.Bytes())
+	assert.Equal(t, int32(2), srv.requests.Load(), "artwork should have come from the cache")
+
+	// Asking again with the same ETag shouldn't send the whole thing again
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	notModified := getArtwork(handler, "track", sstesting.Isrc, "?size=20", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.Bytes())
+
+	// A different size is a different image
+	getArtwork(handler, "track", sstesting.Isrc, "?size=30", nil)
+	assert.Equal(t, int32(4), srv.requests.Load())
+}
+
+func Test_ArtworkRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name   string
+		typ    string
+		id     string
+		query  string
+		expect int
+	}{
+		{
+			name:   "unknown type",
+			typ:    "playlist",
+			id:     "123",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "size too big",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			query:  "?size=5000",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown format",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			query:  "?format=gif",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "webp",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			query:  "?format=webp",
+			expect: http.StatusNotImplemented,
+		},
+		{
+			name:   "unknown track",
+			typ:    "track",
+			id:     "does-not-exist",
+			expect: http.StatusNotFound,
+		},
+		{
+			name:   "no artwork",
+			typ:    "album",
+			id:     "album-1",
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			srv := newSizedArtworkServer(t)
+
+			album := sstesting.Album(model.DeezerStreamingService)
+			album.AlbumId = "album-1"
+			_, err := f.repo.AddAlbum(context.Background(), []*model.Album{album})
+			require.NoError(t, err)
+
+			handler := newTestArtworkHandler(t, f, srv.Client())
+			rec := getArtwork(handler, testCase.typ, testCase.id, testCase.query, nil)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Equal(t, int32(0), srv.requests.Load())
+		})
+	}
+}
+
+func Test_LargestArtworkLink(t *testing.T) {
+	testCases := []struct {
+		name   string
+		link   string
+		expect string
+	}{
+		{
+			name:   "deezer cdn",
+			link:   "https://e-cdns-images.dzcdn.net/images/cover/2e018122cb56986277102d2041a592c8/250x250-000000-80-0-0.jpg",
+			expect: "https://e-cdns-images.dzcdn.net/images/cover/2e018122cb56986277102d2041a592c8/1000x1000-000000-80-0-0.jpg",
+		},
+		{
+			name:   "deezer api",
+			link:   "https://api.deezer.com/album/302127/image",
+			expect: "https://api.deezer.com/album/302127/image?size=xl",
+		},
+		{
+			name:   "apple music",
+			link:   "https://is1-ssl.mzstatic.com/image/thumb/Music/v4/ab/cd/ef/source/3000x3000bb.jpg",
+			expect: "https://is1-ssl.mzstatic.com/image/thumb/Music/v4/ab/cd/ef/source/3000x3000bb.jpg",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, largestArtworkLink(testCase.link))
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/analytics"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
+)
+
+// Nobody needs to send us more than this, even with the maximum number of links
+const maxBatchRequestBytes = 1 << 20
+
+type BatchLinkStatus string
+
+const (
+	BatchLinkFound    BatchLinkStatus = "found"
+	BatchLinkNotFound BatchLinkStatus = "not_found"
+	BatchLinkInvalid  BatchLinkStatus = "invalid"
+	BatchLinkError    BatchLinkStatus = "error"
+)
+
+type BatchLinkRequest struct {
+	Links []string
+}
+
+// BatchLinkResult is the outcome of looking up a single link from a batch request
+type BatchLinkResult struct {
+	Link   string
+	Status BatchLinkStatus
+	Error  string `json:",omitempty"`
+	Result any    `json:",omitempty"`
+
+	// What the link turned out to be, so that the lookup can be counted against the right service
+	input *streamingservice.Input
+}
+
+func PostLinksHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, analyticsRec analytics.Recorder, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		var req *BatchLinkRequest
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchRequestBytes)).Decode(&req)
+		if err != nil || req == nil {
+			responses.BadRequest(w, "request body must be a JSON object with a list of links")
+			return
+		}
+
+		if len(req.Links) == 0 {
+			responses.BadRequest(w, "at least one link is required")
+			return
+		}
+
+		if max := apiConfig.MaxBatchLinks(); len(req.Links) > max {
+			responses.BadRequestf(w, "too many links, at most %d can be looked up at once", max)
+			return
+		}
+
+		// Give up before the server does, so that whatever we've got so far can still be written
+		ctx, cancel := context.WithTimeout(r.Context(), batchTimeout(apiConfig))
+		defer cancel()
+
+		results := resolveBatchLinks(ctx, req.Links, batchConcurrency(apiConfig), serviceProvider, repo, parser, group, reqLogger)
+
+		for _, res := range results {
+			if res.Status == BatchLinkFound {
+				recordResolution(analyticsRec, r, res.input, res.Result)
+			}
+		}
+
+		responses.Response(w, results, http.StatusOK)
+	}
+}
+
+type batchLinkDone struct {
+	i   int
+	res *BatchLinkResult
+}
+
+// resolveBatchLinks looks up each of the links, up to concurrency at once, until the context is done.
+// Any links which weren't looked up in time are marked as failed.
+func resolveBatchLinks(ctx context.Context, links []string, concurrency int, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, logger *logrus.Entry) []*BatchLinkResult {
+	sem := make(chan struct{}, concurrency)
+
+	// Lookups which are still going once we've given up have nowhere to go, so there's room for all of them
+	done := make(chan batchLinkDone, len(links))
+
+	started := 0
+	results := make([]*BatchLinkResult, len(links))
+
+dispatch:
+	for i, link := range links {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case sem <- struct{}{}:
+		}
+
+		started++
+
+		// Lookups go through findForInput so that they're shared with any other requests for the same link
+		go func(i int, link string) {
+			defer func() { <-sem }()
+			done <- batchLinkDone{i, resolveBatchLink(ctx, link, serviceProvider, repo, parser, group, logger)}
+		}(i, link)
+	}
+
+collect:
+	for received := 0; received < started; received++ {
+		select {
+		case <-ctx.Done():
+			break collect
+		case d := <-done:
+			results[d.i] = d.res
+		}
+	}
+
+	// Anything which finished just in time still counts
+drain:
+	for {
+		select {
+		case d := <-done:
+			results[d.i] = d.res
+		default:
+			break drain
+		}
+	}
+
+	for i, res := range results {
+		if res == nil {
+			results[i] = &BatchLinkResult{Link: links[i], Status: BatchLinkError, Error: "timed out before the link could be looked up"}
+		}
+	}
+
+	return results
+}
+
+func resolveBatchLink(ctx context.Context, link string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, logger *logrus.Entry) *BatchLinkResult {
+	input, err := parser.Parse(ctx, link)
+	if err != nil {
+		return batchLinkFailure(link, err, logger)
+	}
+
+	res, found, err := findForParsedInput(ctx, input, serviceProvider, repo, group, logger)
+	if err != nil {
+		return batchLinkFailure(link, err, logger)
+	}
+
+	if !found {
+		return &BatchLinkResult{Link: link, Status: BatchLinkNotFound, Error: "could not find anything"}
+	}
+
+	return &BatchLinkResult{Link: link, Status: BatchLinkFound, Result: res, input: input}
+}
+
+func batchLinkFailure(link string, err error, logger *logrus.Entry) *BatchLinkResult {
+	if errors.Is(err, streamingservice.ErrInvalidInput) {
+		return &BatchLinkResult{Link: link, Status: BatchLinkInvalid, Error: err.Error()}
+	}
+
+	logger.WithField("link", link).Errorf("failed to look up link in batch: %s", err.Error())
+	return &BatchLinkResult{Link: link, Status: BatchLinkError, Error: err.Error()}
+}
+
+func batchTimeout(apiConfig config.API) time.Duration {
+	if d := apiConfig.BatchTimeout(); d > 0 {
+		return d
+	}
+
+	return 10 * time.Second
+}
+
+func batchConcurrency(apiConfig config.API) int {
+	if n := apiConfig.BatchConcurrency(); n > 0 {
+		return n
+	}
+
+	return 1
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func postLinks(t *testing.T, f *testFixture, maxLinks int, body string) *httptest.ResponseRecorder {
+	v := viper.New()
+	v.Set("api.batch.max_links", maxLinks)
+	v.Set("api.batch.concurrency", 2)
+
+	logger := testLogger().Logger
+	handler := PostLinksHandler(config.NewApiViperConfig(v), f.provider, f.repo, testParser(), &singleflight.Group{}, f.analytics, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/links", strings.NewReader(body))
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_PostLinksReturnsResultsInOrder(t *testing.T) {
+	f := newTestFixture()
+
+	trackLink := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+	artistLink := sstesting.LinkFor(model.DeezerStreamingService, model.ArtistType, "cheap-trick")
+	body := `{"Links": ["` + trackLink + `", "not a link", "https://example.com/track/123", "` + artistLink + `"]}`
+
+	rec := postLinks(t, f, 10, body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []struct {
+		Link   string
+		Status BatchLinkStatus
+		Error  string
+		Result *struct {
+			Type  model.Type
+			Items []map[string]any
+		}
+	}
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 4)
+
+	assert.Equal(t, trackLink, results[0].Link)
+	assert.Equal(t, BatchLinkFound, results[0].Status)
+	assert.Equal(t, model.TrackType, results[0].Result.Type)
+	assert.Len(t, results[0].Result.Items, 3)
+
+	assert.Equal(t, "not a link", results[1].Link)
+	assert.Equal(t, BatchLinkInvalid, results[1].Status)
+	assert.NotEmpty(t, results[1].Error)
+	assert.Nil(t, results[1].Result)
+
+	assert.Equal(t, BatchLinkError, results[2].Status)
+	assert.NotEmpty(t, results[2].Error)
+
+	assert.Equal(t, BatchLinkFound, results[3].Status)
+	assert.Equal(t, model.ArtistType, results[3].Result.Type)
+
+	// Only the links which were found count as resolutions
+	events := f.analytics.Events()
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, model.ResolveEvent, event.Kind)
+	}
+}
+
+func Test_PostLinksSharesDuplicateLookups(t *testing.T) {
+	f := newTestFixture()
+
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+	body := `{"Links": ["` + link + `", "` + link + `?si=abc", "` + link + `"]}`
+
+	rec := postLinks(t, f, 10, body)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// One call to get the track from Spotify, and one to find it on each of the other services
+	assert.Equal(t, 3, f.totalCalls())
+}
+
+func Test_PostLinksRejectsBadRequests(t *testing.T) {
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "invalid json",
+			body: `{"Links": [`,
+		},
+		{
+			name: "no links",
+			body: `{"Links": []}`,
+		},
+		{
+			name: "too many links",
+			body: `{"Links": ["` + link + `", "` + link + `", "` + link + `"]}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := postLinks(t, f, 2, testCase.body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
+
+func Test_PostLinksGivesUpAfterTimeout(t *testing.T) {
+	f := newTestFixture()
+	for _, svc := range f.services {
+		svc.WithDelay(time.Second)
+	}
+
+	v := viper.New()
+	v.Set("api.batch.concurrency", 1)
+	v.Set("api.batch.timeout", 100*time.Millisecond)
+
+	handler := PostLinksHandler(config.NewApiViperConfig(v), f.provider, f.repo, testParser(), &singleflight.Group{}, f.analytics, testLogger().Logger)
+
+	trackLink := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+	artistLink := sstesting.LinkFor(model.DeezerStreamingService, model.ArtistType, "cheap-trick")
+	body := `{"Links": ["not a link", "` + trackLink + `", "` + artistLink + `"]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/links", strings.NewReader(body))
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	assert.Less(t, time.Since(start), time.Second, "shouldn't wait for the slow lookups")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []*BatchLinkResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+
+	// Whatever was looked up in time is still there
+	assert.Equal(t, BatchLinkInvalid, results[0].Status)
+
+	for _, res := range results[1:] {
+		assert.Equal(t, BatchLinkError, res.Status)
+		assert.Contains(t, res.Error, "timed out")
+	}
+}
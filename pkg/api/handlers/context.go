@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext carries the values of its parent, but is never cancelled.
+// Lookups can be shared between concurrent requests, and the request which started the lookup
+// might go away before the others have their result.
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return &detachedContext{ctx}
+}
+
+func (c *detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c *detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c *detachedContext) Err() error {
+	return nil
+}
+
+func (c *detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
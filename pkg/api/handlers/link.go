@@ -2,11 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/yukitsune/maestro/pkg/analytics"
 	"github.com/yukitsune/maestro/pkg/api/responses"
 	"github.com/yukitsune/maestro/pkg/db"
 	"net/http"
-	"net/url"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -14,9 +15,10 @@ import (
 	"github.com/yukitsune/maestro/pkg/log"
 	"github.com/yukitsune/maestro/pkg/model"
 	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
 )
 
-func GetLinkHandler(serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Logger) http.HandlerFunc {
+func GetLinkHandler(serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, analyticsRec analytics.Recorder, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		reqLogger, err := log.ForRequest(logger, r)
@@ -32,32 +34,93 @@ func GetLinkHandler(serviceProvider streamingservice.ServiceProvider, repo db.Re
 			return
 		}
 
-		u, err := url.Parse(reqLink)
-		if err != nil || u == nil {
-			responses.BadRequestf(w, "couldn't parse the given link: %s", reqLink)
-			return
-		}
+		input, err := parser.Parse(r.Context(), reqLink)
+		if err != nil {
+			if errors.Is(err, streamingservice.ErrInvalidInput) {
+				responses.BadRequest(w, err.Error())
+				return
+			}
 
-		if !u.IsAbs() {
-			responses.BadRequest(w, "given link must be absolute")
+			responses.Error(w, err)
 			return
 		}
 
-		res, found, err := findForLink(r.Context(), reqLink, serviceProvider, repo, reqLogger)
+		res, found, err := findForParsedInput(r.Context(), input, serviceProvider, repo, group, reqLogger)
 		if err != nil {
+			if errors.Is(err, streamingservice.ErrInvalidInput) {
+				responses.BadRequest(w, err.Error())
+				return
+			}
+
 			responses.Error(w, err)
 			return
 		}
 
 		if !found {
 			responses.NotFound(w, "could not find anything")
+			return
 		}
 
+		recordResolution(analyticsRec, r, input, res)
 		responses.Response(w, res, http.StatusOK)
 	}
 }
 
-func findForLink(ctx context.Context, link string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (any, bool, error) {
+// findForInput looks up whatever we've been given, be it a link, a service URI, an ISRC, or a UPC
+func findForInput(ctx context.Context, input string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, logger *logrus.Entry) (any, bool, error) {
+	parsed, err := parser.Parse(ctx, input)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return findForParsedInput(ctx, parsed, serviceProvider, repo, group, logger)
+}
+
+// findForParsedInput looks up something which has already been through the InputParser
+func findForParsedInput(ctx context.Context, parsed *streamingservice.Input, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (any, bool, error) {
+	switch parsed.Type {
+	case streamingservice.LinkInput:
+		return findForLink(ctx, parsed.Value, serviceProvider, repo, group, logger)
+
+	case streamingservice.IsrcInput:
+		res, err := findForIsrc(ctx, parsed.Value, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
+
+	case streamingservice.UpcInput:
+		res, err := findForUpc(ctx, parsed.Value, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
+
+	default:
+		return nil, false, fmt.Errorf("unknown input type %s", parsed.Type)
+	}
+}
+
+type disabledSetter interface {
+	SetDisabled(key model.StreamingServiceType)
+}
+
+func setDisabled(res disabledSetter, serviceProvider streamingservice.ServiceProvider) {
+	for key, cfg := range serviceProvider.ListConfigs() {
+		if !cfg.Enabled() {
+			res.SetDisabled(key)
+		}
+	}
+}
+
+type linkLookup struct {
+	res   any
+	found bool
+}
+
+func findForLink(ctx context.Context, link string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (any, bool, error) {
 	services, err := serviceProvider.ListServices()
 	if err != nil {
 		return nil, false, err
@@ -70,6 +133,32 @@ func findForLink(ctx context.Context, link string, serviceProvider streamingserv
 
 	logger = logger.WithField("link", link)
 
+	// If someone else is already looking up this link, wait for their result rather than doing it all over again.
+	// Otherwise, we'd end up querying every service and adding the same things multiple times.
+	key := fmt.Sprintf("link:%s", link)
+	v, err, shared := group.Do(key, func() (interface{}, error) {
+		res, found, err := lookupLink(detach(ctx), link, services, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return &linkLookup{res, found}, nil
+	})
+
+	if shared {
+		logger.Debugln("shared result with concurrent lookups")
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	lookup := v.(*linkLookup)
+	return lookup.res, lookup.found, nil
+}
+
+func lookupLink(ctx context.Context, link string, services streamingservice.StreamingServices, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (any, bool, error) {
+
 	// Search the database for an existing thing with the given link
 	typ, dbRes, err := repo.GetByLink(ctx, link)
 	if err != nil {
@@ -79,21 +168,34 @@ func findForLink(ctx context.Context, link string, serviceProvider streamingserv
 	switch typ {
 	case model.ArtistType:
 		artist := dbRes.(*model.Artist)
-		res, err := findForExistingArtist(ctx, artist, services, repo, logger)
-		return res, res.HasResults(), err
+		res, err := findForExistingArtist(ctx, artist, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
 
 	case model.AlbumType:
 		album := dbRes.(*model.Album)
-		res, err := findForExistingAlbum(ctx, album, services, repo, logger)
-		return res, res.HasResults(), err
+		res, err := findForExistingAlbum(ctx, album, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
 
 	case model.TrackType:
 		track := dbRes.(*model.Track)
-		res, err := findForExistingTrack(ctx, track, services, repo, logger)
-		return res, res.HasResults(), err
+		logger.WithField("isrc", track.Isrc).Debugln("found a track")
+		res, err := findForIsrc(ctx, track.Isrc, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
 
 	case model.UnknownType:
-		res, found, err := findNewThing(ctx, link, services, repo, logger)
+		res, found, err := findNewThing(ctx, link, services, serviceProvider, repo, group, logger)
 		return res, found, err
 
 	default:
@@ -101,7 +203,33 @@ func findForLink(ctx context.Context, link string, serviceProvider streamingserv
 	}
 }
 
-func findForExistingArtist(ctx context.Context, foundArtist *model.Artist, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Artist], error) {
+// findForExistingArtist looks for the artist on any services we don't know about yet.
+// Anything else adding to the same artist at the same time, like the ResolveWorker, shares the same result.
+func findForExistingArtist(ctx context.Context, foundArtist *model.Artist, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (*Result[*model.Artist], error) {
+	key := fmt.Sprintf("artist:%s", foundArtist.ArtistId)
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		services, err := serviceProvider.ListServices()
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := lookupExistingArtist(detach(ctx), foundArtist, services, repo, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		setDisabled(res, serviceProvider)
+		return res, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Result[*model.Artist]), nil
+}
+
+func lookupExistingArtist(ctx context.Context, foundArtist *model.Artist, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Artist], error) {
 
 	logger = logger.WithField("artist_id", foundArtist.ArtistId)
 	logger.Debugln("found an artist")
@@ -133,12 +261,21 @@ func findForExistingArtist(ctx context.Context, foundArtist *model.Artist, servi
 		logger.Debugf("searching %s for artist\n", key)
 		artist, found, err := service.SearchArtist(foundArtist)
 		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
+			logServiceError(logger, key, err)
+			res.SetError(key, err)
 			continue
 		}
 
 		if !found {
 			logger.Debugf("couldn't find anything for %s", key)
+			res.SetNotFound(key)
+			continue
+		}
+
+		// Searching can turn up the same link we already know is dead, which is no use to anyone
+		if res.IsDeadLink(artist.Link) {
+			logger.Debugf("%s only has a dead artist", key)
+			res.SetNotFound(key)
 			continue
 		}
 
@@ -161,7 +298,33 @@ func findForExistingArtist(ctx context.Context, foundArtist *model.Artist, servi
 	return res, nil
 }
 
-func findForExistingAlbum(ctx context.Context, foundAlbum *model.Album, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Album], error) {
+// findForExistingAlbum looks for the album on any services we don't know about yet.
+// Anything else adding to the same album at the same time, like the ResolveWorker, shares the same result.
+func findForExistingAlbum(ctx context.Context, foundAlbum *model.Album, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (*Result[*model.Album], error) {
+	key := fmt.Sprintf("album:%s", foundAlbum.AlbumId)
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		services, err := serviceProvider.ListServices()
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := lookupExistingAlbum(detach(ctx), foundAlbum, services, repo, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		setDisabled(res, serviceProvider)
+		return res, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Result[*model.Album]), nil
+}
+
+func lookupExistingAlbum(ctx context.Context, foundAlbum *model.Album, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Album], error) {
 
 	logger = logger.WithField("album_id", foundAlbum.AlbumId)
 	logger.Debugln("found an album")
@@ -193,12 +356,21 @@ func findForExistingAlbum(ctx context.Context, foundAlbum *model.Album, services
 		logger.Debugf("searching %s for album\n", key)
 		album, found, err := service.SearchAlbum(foundAlbum)
 		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
+			logServiceError(logger, key, err)
+			res.SetError(key, err)
 			continue
 		}
 
 		if !found {
 			logger.Debugf("couldn't find anything for %s", key)
+			res.SetNotFound(key)
+			continue
+		}
+
+		// Searching can turn up the same link we already know is dead, which is no use to anyone
+		if res.IsDeadLink(album.Link) {
+			logger.Debugf("%s only has a dead album", key)
+			res.SetNotFound(key)
 			continue
 		}
 
@@ -221,65 +393,6 @@ func findForExistingAlbum(ctx context.Context, foundAlbum *model.Album, services
 	return res, nil
 }
 
-func findForExistingTrack(ctx context.Context, foundTrack *model.Track, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Track], error) {
-
-	logger = logger.WithField("isrc", foundTrack.Isrc)
-	logger.Debugln("found a track")
-
-	res := NewResult[*model.Track](model.TrackType)
-
-	// Find any related track based on the ISRC
-	existingTracks, err := repo.GetTracksByIsrc(ctx, foundTrack.Isrc)
-	if err != nil {
-		return nil, err
-	}
-
-	res.AddAll(existingTracks)
-
-	// If we have results for all known services, then we're good to go
-	if len(res.Items) == len(services) {
-		return res, nil
-	}
-
-	logger.Debugf("looks like we have some new services since we found this track (found %d, looking for %d)\n", len(existingTracks), len(services))
-
-	// Query the remaining streaming service
-	var newTracks []*model.Track
-	for key, service := range services {
-		if res.HasResultFor(key) {
-			continue
-		}
-
-		logger.Debugf("searching %s for track\n", key)
-		track, found, err := service.GetTrackByIsrc(foundTrack.Isrc)
-		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
-			continue
-		}
-
-		if !found {
-			logger.Debugf("couldn't find anything for %s", key)
-			continue
-		}
-
-		newTracks = append(newTracks, track)
-	}
-
-	// Add the new tracks to the database
-	if len(newTracks) != 0 {
-
-		n, err := repo.AddTracks(ctx, newTracks)
-		if err != nil {
-			return nil, err
-		}
-
-		logger.Infof("%d new tracks added\n", n)
-	}
-
-	res.AddAll(newTracks)
-	return res, nil
-}
-
 func handleNewArtist(ctx context.Context, newArtist *model.Artist, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Artist], error) {
 
 	res := NewResult[*model.Artist](model.ArtistType)
@@ -305,12 +418,14 @@ func handleNewArtist(ctx context.Context, newArtist *model.Artist, services stre
 		logger.Debugf("searching %s for artist with name %s\n", key, newArtist.Name)
 		foundArtist, found, err := service.SearchArtist(newArtist)
 		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
+			logServiceError(logger, key, err)
+			res.SetError(key, err)
 			continue
 		}
 
 		if !found {
 			logger.Debugf("couldn't find anything for %s", key)
+			res.SetNotFound(key)
 			continue
 		}
 
@@ -354,12 +469,14 @@ func handleNewAlbum(ctx context.Context, newAlbum *model.Album, services streami
 		logger.Debugf("searching %s for album with name %s\n", key, newAlbum.Name)
 		foundAlbum, found, err := service.SearchAlbum(newAlbum)
 		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
+			logServiceError(logger, key, err)
+			res.SetError(key, err)
 			continue
 		}
 
 		if !found {
 			logger.Debugf("couldn't find anything for %s", key)
+			res.SetNotFound(key)
 			continue
 		}
 
@@ -378,46 +495,7 @@ func handleNewAlbum(ctx context.Context, newAlbum *model.Album, services streami
 	return res, nil
 }
 
-func handleNewTrack(ctx context.Context, newTrack *model.Track, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (*Result[*model.Track], error) {
-
-	res := NewResult[*model.Track](model.TrackType)
-	res.Add(newTrack)
-
-	logger = logger.WithField("isrc", newTrack.Isrc)
-
-	newTracks := []*model.Track{
-		newTrack,
-	}
-
-	// Query the other streaming services using what we found from the target streaming service
-	for key, service := range services {
-		logger.Debugf("searching %s for track with name %s\n", key, newTrack.Name)
-		foundTrack, found, err := service.GetTrackByIsrc(newTrack.Isrc)
-		if err != nil {
-			logger.Errorf("%s: %s", service, err.Error())
-			continue
-		}
-
-		if !found {
-			logger.Debugf("couldn't find anything for %s", key)
-			continue
-		}
-
-		res.Add(foundTrack)
-		newTracks = append(newTracks, foundTrack)
-	}
-
-	n, err := repo.AddTracks(ctx, newTracks)
-	if err != nil {
-		return nil, err
-	}
-
-	logger.Infof("%d new tracks added", n)
-
-	return res, nil
-}
-
-func findNewThing(ctx context.Context, link string, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) (any, bool, error) {
+func findNewThing(ctx context.Context, link string, services streamingservice.StreamingServices, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (any, bool, error) {
 
 	logger.Debugln("looks like this is a new thing")
 
@@ -451,17 +529,32 @@ func findNewThing(ctx context.Context, link string, services streamingservice.St
 	case model.ArtistType:
 		artist := res.(*model.Artist)
 		res, err := handleNewArtist(ctx, artist, otherServices, repo, logger)
-		return res, res.HasResults(), err
+		if err != nil {
+			return nil, false, err
+		}
+
+		setDisabled(res, serviceProvider)
+		return res, res.HasResults(), nil
 
 	case model.AlbumType:
 		album := res.(*model.Album)
 		res, err := handleNewAlbum(ctx, album, otherServices, repo, logger)
-		return res, res.HasResults(), err
+		if err != nil {
+			return nil, false, err
+		}
+
+		setDisabled(res, serviceProvider)
+		return res, res.HasResults(), nil
 
 	case model.TrackType:
+		// Other lookups might have found the same track from its ISRC, or from another service's link
 		track := res.(*model.Track)
-		res, err := handleNewTrack(ctx, track, otherServices, repo, logger)
-		return res, res.HasResults(), err
+		res, err := findForIsrc(ctx, track.Isrc, serviceProvider, repo, group, logger.WithField("isrc", track.Isrc), track)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return res, res.HasResults(), nil
 
 	case model.UnknownType:
 		return nil, false, fmt.Errorf("could not find anything from %s", targetKey)
@@ -470,3 +563,13 @@ func findNewThing(ctx context.Context, link string, services streamingservice.St
 		return nil, false, fmt.Errorf("unknown type %s", typ)
 	}
 }
+
+func logServiceError(logger *logrus.Entry, key model.StreamingServiceType, err error) {
+	// Services with an open circuit are expected to fail, so there's no need to be loud about it
+	if errors.Is(err, streamingservice.ErrCircuitOpen) {
+		logger.Debugf("%s: %s", key, err.Error())
+		return
+	}
+
+	logger.Errorf("%s: %s", key, err.Error())
+}
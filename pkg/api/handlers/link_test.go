@@ -0,0 +1,961 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+type testFixture struct {
+	repo      db.Repository
+	services  sstesting.FakeStreamingServices
+	provider  streamingservice.ServiceProvider
+	analytics *testAnalyticsRecorder
+}
+
+func newTestFixture() *testFixture {
+	services := sstesting.NewFakeStreamingServices()
+
+	return &testFixture{
+		repo:      db.NewInMemoryRepository(),
+		services:  services,
+		provider:  services.Provider(),
+		analytics: &testAnalyticsRecorder{},
+	}
+}
+
+// replaceService swaps out one of the fixture's services, e.g. for one which doesn't know about anything
+func (f *testFixture) replaceService(svc *sstesting.FakeStreamingService) {
+	f.services[svc.Key()] = svc
+	f.provider = f.services.Provider()
+}
+
+func (f *testFixture) totalCalls() int {
+	total := 0
+	for _, svc := range f.services {
+		total += svc.Calls()
+	}
+
+	return total
+}
+
+func (f *testFixture) streamingServices(t *testing.T) streamingservice.StreamingServices {
+	svcs, err := f.provider.ListServices()
+	require.NoError(t, err)
+	return svcs
+}
+
+func testParser() streamingservice.InputParser {
+	resolver := clients.NewRedirectResolver(streamingservice.ShortLinkHosts, 5, time.Second, cache.NewLRUCache(10, time.Minute), clients.NewRedirectingTransport(nil))
+	return streamingservice.NewInputParser(resolver)
+}
+
+func testLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func Test_FindForLink(t *testing.T) {
+	testCases := []struct {
+		name          string
+		setup         func(t *testing.T, f *testFixture)
+		link          string
+		expectErr     bool
+		expectFound   bool
+		expectType    model.Type
+		expectItems   int
+		expectCalls   int
+		expectStored  int
+		expectGroupId bool
+	}{
+		{
+			name:         "new track is found on every service",
+			link:         sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender") + "?si=abc123",
+			expectFound:  true,
+			expectType:   model.TrackType,
+			expectItems:  3,
+			expectCalls:  3,
+			expectStored: 3,
+		},
+		{
+			name:          "new artist is found on every service",
+			link:          sstesting.LinkFor(model.AppleMusicStreamingService, model.ArtistType, "cheap-trick"),
+			expectFound:   true,
+			expectType:    model.ArtistType,
+			expectItems:   3,
+			expectCalls:   3,
+			expectStored:  3,
+			expectGroupId: true,
+		},
+		{
+			name:          "new album is found on every service",
+			link:          sstesting.LinkFor(model.DeezerStreamingService, model.AlbumType, "heaven-tonight"),
+			expectFound:   true,
+			expectType:    model.AlbumType,
+			expectItems:   3,
+			expectCalls:   3,
+			expectStored:  3,
+			expectGroupId: true,
+		},
+		{
+			name: "existing track does not query any services",
+			setup: func(t *testing.T, f *testFixture) {
+				var tracks []*model.Track
+				for _, key := range sstesting.ServiceKeys {
+					tracks = append(tracks, sstesting.Track(key))
+				}
+
+				_, err := f.repo.AddTracks(context.Background(), tracks)
+				require.NoError(t, err)
+			},
+			link:         sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+			expectFound:  true,
+			expectType:   model.TrackType,
+			expectItems:  3,
+			expectCalls:  0,
+			expectStored: 3,
+		},
+		{
+			name: "existing track only queries missing services",
+			setup: func(t *testing.T, f *testFixture) {
+				tracks := []*model.Track{
+					sstesting.Track(model.SpotifyStreamingService),
+					sstesting.Track(model.AppleMusicStreamingService),
+				}
+
+				_, err := f.repo.AddTracks(context.Background(), tracks)
+				require.NoError(t, err)
+			},
+			link:         sstesting.LinkFor(model.AppleMusicStreamingService, model.TrackType, "surrender"),
+			expectFound:  true,
+			expectType:   model.TrackType,
+			expectItems:  3,
+			expectCalls:  1,
+			expectStored: 3,
+		},
+		{
+			name: "failing services are skipped",
+			setup: func(t *testing.T, f *testFixture) {
+				f.services[model.DeezerStreamingService].WithError(fmt.Errorf("api responded with 500 Internal Server Error"))
+			},
+			link:         sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+			expectFound:  true,
+			expectType:   model.TrackType,
+			expectItems:  2,
+			expectCalls:  3,
+			expectStored: 2,
+		},
+		{
+			name:      "link for an unknown service fails",
+			link:      "https://example.com/track/surrender",
+			expectErr: true,
+		},
+		{
+			name:        "link which can't be found fails",
+			link:        sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "does-not-exist"),
+			expectErr:   true,
+			expectCalls: 1,
+		},
+		{
+			name: "link which errors upstream fails",
+			setup: func(t *testing.T, f *testFixture) {
+				f.services[model.SpotifyStreamingService].WithError(fmt.Errorf("api responded with 503 Service Unavailable"))
+			},
+			link:        sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+			expectErr:   true,
+			expectCalls: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			if testCase.setup != nil {
+				testCase.setup(t, f)
+			}
+
+			res, found, err := findForLink(context.Background(), testCase.link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+			assert.Equal(t, testCase.expectCalls, f.totalCalls())
+
+			if testCase.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			switch testCase.expectType {
+			case model.ArtistType:
+				artistRes := res.(*Result[*model.Artist])
+				assert.Len(t, artistRes.Items, testCase.expectItems)
+				assertSameArtistId(t, artistRes.Items)
+
+				stored, err := f.repo.GetArtistsById(context.Background(), artistRes.Items[0].ArtistId)
+				require.NoError(t, err)
+				assert.Len(t, stored, testCase.expectStored)
+
+			case model.AlbumType:
+				albumRes := res.(*Result[*model.Album])
+				assert.Len(t, albumRes.Items, testCase.expectItems)
+				assertSameAlbumId(t, albumRes.Items)
+
+				stored, err := f.repo.GetAlbumsById(context.Background(), albumRes.Items[0].AlbumId)
+				require.NoError(t, err)
+				assert.Len(t, stored, testCase.expectStored)
+
+			case model.TrackType:
+				trackRes := res.(*Result[*model.Track])
+				assert.Len(t, trackRes.Items, testCase.expectItems)
+
+				stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+				require.NoError(t, err)
+				assert.Len(t, stored, testCase.expectStored)
+
+			default:
+				t.Fatalf("unexpected type %s", testCase.expectType)
+			}
+		})
+	}
+}
+
+func Test_FindForLinkReportsServiceStatuses(t *testing.T) {
+	testCases := []struct {
+		name          string
+		setup         func(f *testFixture)
+		expectStatus  ServiceStatus
+		expectItems   int
+		expectHasErrs bool
+	}{
+		{
+			name:         "found",
+			expectStatus: ServiceStatus{Status: StatusFound},
+			expectItems:  3,
+		},
+		{
+			name: "not found",
+			setup: func(f *testFixture) {
+				f.replaceService(sstesting.NewFakeStreamingService(model.DeezerStreamingService))
+			},
+			expectStatus: ServiceStatus{Status: StatusNotFound},
+			expectItems:  2,
+		},
+		{
+			name: "upstream error",
+			setup: func(f *testFixture) {
+				f.services[model.DeezerStreamingService].WithError(&clients.ResponseError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"})
+			},
+			expectStatus:  ServiceStatus{Status: StatusError, ErrorClass: streamingservice.ErrorClassUpstream},
+			expectItems:   2,
+			expectHasErrs: true,
+		},
+		{
+			name: "rate limited",
+			setup: func(f *testFixture) {
+				f.services[model.DeezerStreamingService].WithError(&clients.ResponseError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"})
+			},
+			expectStatus:  ServiceStatus{Status: StatusError, ErrorClass: streamingservice.ErrorClassRateLimited},
+			expectItems:   2,
+			expectHasErrs: true,
+		},
+		{
+			name: "timeout",
+			setup: func(f *testFixture) {
+				f.services[model.DeezerStreamingService].WithError(fmt.Errorf("request failed: %w", context.DeadlineExceeded))
+			},
+			expectStatus:  ServiceStatus{Status: StatusTimeout, ErrorClass: streamingservice.ErrorClassTimeout},
+			expectItems:   2,
+			expectHasErrs: true,
+		},
+		{
+			name: "disabled",
+			setup: func(f *testFixture) {
+				f.services[model.DeezerStreamingService].Disabled()
+			},
+			expectStatus: ServiceStatus{Status: StatusDisabled},
+			expectItems:  2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			if testCase.setup != nil {
+				testCase.setup(f)
+			}
+
+			link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+			res, found, err := findForLink(context.Background(), link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+			require.NoError(t, err)
+			require.True(t, found)
+
+			trackRes := res.(*Result[*model.Track])
+			assert.Len(t, trackRes.Items, testCase.expectItems)
+			assert.Equal(t, testCase.expectHasErrs, trackRes.HasErrors())
+			assert.Equal(t, ServiceStatus{Status: StatusFound}, trackRes.Services[model.SpotifyStreamingService])
+			assert.Equal(t, testCase.expectStatus, trackRes.Services[model.DeezerStreamingService])
+		})
+	}
+}
+
+func Test_FailedServicesAreRetried(t *testing.T) {
+	f := newTestFixture()
+	deezer := f.services[model.DeezerStreamingService]
+	deezer.WithError(fmt.Errorf("api responded with 500 Internal Server Error"))
+
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+	res, _, err := findForLink(context.Background(), link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	assert.Len(t, res.(*Result[*model.Track]).Items, 2)
+
+	stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2, "tracks from failed services shouldn't be stored")
+
+	// Once Deezer has recovered, only Deezer should be queried
+	deezer.WithError(nil)
+	callsBefore := f.totalCalls()
+
+	res, _, err = findForLink(context.Background(), link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+
+	trackRes := res.(*Result[*model.Track])
+	assert.Len(t, trackRes.Items, 3)
+	assert.False(t, trackRes.HasErrors())
+	assert.Equal(t, 1, f.totalCalls()-callsBefore)
+	assert.Equal(t, 2, deezer.Calls())
+}
+
+func Test_DeadLinksAreReplaced(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	// Spotify has taken the track down and put it back up under a new link
+	deadTrack := sstesting.Track(model.SpotifyStreamingService)
+	deadTrack.Dead = true
+
+	newTrack := sstesting.Track(model.SpotifyStreamingService)
+	newTrack.Link = sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender-remastered")
+
+	f.services[model.SpotifyStreamingService].Remove(deadTrack.Link).WithTracks(newTrack)
+
+	_, err := f.repo.AddTracks(ctx, []*model.Track{sstesting.Track(model.AppleMusicStreamingService), deadTrack, sstesting.Track(model.DeezerStreamingService)})
+	require.NoError(t, err)
+
+	// Looking up the dead link still works, but it shouldn't be returned
+	res, found, err := findForLink(ctx, deadTrack.Link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	require.True(t, found)
+
+	trackRes := res.(*Result[*model.Track])
+	assert.Len(t, trackRes.Items, 3)
+	for _, track := range trackRes.Items {
+		assert.NotEqual(t, deadTrack.Link, track.Link)
+	}
+
+	assert.Equal(t, 1, f.totalCalls(), "only spotify should have been queried")
+
+	stored, err := f.repo.GetTrackByLink(ctx, newTrack.Link)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+}
+
+func Test_DeadLinksAreNotReturnedAgain(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	// Search still turns up the dead link, which happens while the service's search index catches up
+	deadTrack := sstesting.Track(model.SpotifyStreamingService)
+	deadTrack.Dead = true
+
+	_, err := f.repo.AddTracks(ctx, []*model.Track{sstesting.Track(model.AppleMusicStreamingService), deadTrack, sstesting.Track(model.DeezerStreamingService)})
+	require.NoError(t, err)
+
+	res, err := findForIsrc(ctx, sstesting.Isrc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+
+	assert.Len(t, res.Items, 2)
+	assert.Equal(t, ServiceStatus{Status: StatusNotFound}, res.Services[model.SpotifyStreamingService])
+
+	stored, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3, "the dead link shouldn't be stored twice")
+}
+
+func Test_FindForIsrcReportsServiceStatuses(t *testing.T) {
+	f := newTestFixture()
+	f.services[model.DeezerStreamingService].WithError(&clients.ResponseError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"})
+	f.services[model.AppleMusicStreamingService].Disabled()
+
+	res, err := findForIsrc(context.Background(), sstesting.Isrc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+
+	assert.Len(t, res.Items, 1)
+	assert.Equal(t, map[model.StreamingServiceType]ServiceStatus{
+		model.SpotifyStreamingService:    {Status: StatusFound},
+		model.DeezerStreamingService:     {Status: StatusError, ErrorClass: streamingservice.ErrorClassUpstream},
+		model.AppleMusicStreamingService: {Status: StatusDisabled},
+	}, res.Services)
+}
+
+func Test_ConcurrentLookupsForTheSameLinkAreShared(t *testing.T) {
+	f := newTestFixture()
+	for _, svc := range f.services {
+		svc.WithDelay(50 * time.Millisecond)
+	}
+
+	group := &singleflight.Group{}
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.ArtistType, "cheap-trick")
+
+	n := 10
+	results := make([]any, n)
+	errs := make([]error, n)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Some requests will have slightly different links, but they should be cleaned up to the same link
+			reqLink := link
+			if i%2 == 0 {
+				reqLink = fmt.Sprintf("%s?si=%d", link, i)
+			}
+
+			results[i], _, errs[i] = findForLink(context.Background(), reqLink, f.provider, f.repo, group, testLogger())
+		}(i)
+	}
+
+	wg.Wait()
+
+	artistId := results[0].(*Result[*model.Artist]).Items[0].ArtistId
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, artistId, results[i].(*Result[*model.Artist]).Items[0].ArtistId)
+	}
+
+	assert.Equal(t, len(sstesting.ServiceKeys), f.totalCalls(), "each service should only be queried once")
+
+	stored, err := f.repo.GetArtistsById(context.Background(), artistId)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(sstesting.ServiceKeys), "each artist should only be stored once")
+}
+
+func Test_ConcurrentLookupsForTheSameIsrcAreShared(t *testing.T) {
+	f := newTestFixture()
+	for _, svc := range f.services {
+		svc.WithDelay(50 * time.Millisecond)
+	}
+
+	group := &singleflight.Group{}
+
+	n := 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res, err := findForIsrc(context.Background(), sstesting.Isrc, f.provider, f.repo, group, testLogger())
+			assert.NoError(t, err)
+			assert.Len(t, res.Items, len(sstesting.ServiceKeys))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, len(sstesting.ServiceKeys), f.totalCalls(), "each service should only be queried once")
+
+	stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(sstesting.ServiceKeys), "each track should only be stored once")
+}
+
+func Test_ConcurrentLookupsForTheSameTrackAreShared(t *testing.T) {
+	f := newTestFixture()
+	for _, svc := range f.services {
+		svc.WithDelay(50 * time.Millisecond)
+	}
+
+	group := &singleflight.Group{}
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+
+	// Half of the lookups start from a link, and the other half from the ISRC, but they're all after the same track
+	n := 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if i%2 == 0 {
+				_, found, err := findForLink(context.Background(), link, f.provider, f.repo, group, testLogger())
+				assert.NoError(t, err)
+				assert.True(t, found)
+				return
+			}
+
+			res, err := findForIsrc(context.Background(), sstesting.Isrc, f.provider, f.repo, group, testLogger())
+			assert.NoError(t, err)
+			assert.True(t, res.HasResults())
+		}(i)
+	}
+
+	wg.Wait()
+
+	stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(sstesting.ServiceKeys), "each track should only be stored once")
+}
+
+func Test_FindNewThing(t *testing.T) {
+	testCases := []struct {
+		name        string
+		link        string
+		expectType  model.Type
+		expectErr   bool
+		expectItems int
+	}{
+		{
+			name:        "artist",
+			link:        sstesting.LinkFor(model.SpotifyStreamingService, model.ArtistType, "cheap-trick"),
+			expectType:  model.ArtistType,
+			expectItems: 3,
+		},
+		{
+			name:        "album",
+			link:        sstesting.LinkFor(model.SpotifyStreamingService, model.AlbumType, "heaven-tonight"),
+			expectType:  model.AlbumType,
+			expectItems: 3,
+		},
+		{
+			name:        "track",
+			link:        sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+			expectType:  model.TrackType,
+			expectItems: 3,
+		},
+		{
+			name:      "unknown service",
+			link:      "https://example.com/track/surrender",
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			res, found, err := findNewThing(context.Background(), testCase.link, f.streamingServices(t), f.provider, f.repo, &singleflight.Group{}, testLogger())
+			if testCase.expectErr {
+				assert.Error(t, err)
+				assert.False(t, found)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, found)
+
+			// The original thing should always come first
+			switch testCase.expectType {
+			case model.ArtistType:
+				items := res.(*Result[*model.Artist]).Items
+				assert.Len(t, items, testCase.expectItems)
+				assert.Equal(t, testCase.link, items[0].Link)
+				assertSameArtistId(t, items)
+
+			case model.AlbumType:
+				items := res.(*Result[*model.Album]).Items
+				assert.Len(t, items, testCase.expectItems)
+				assert.Equal(t, testCase.link, items[0].Link)
+				assertSameAlbumId(t, items)
+
+			case model.TrackType:
+				items := res.(*Result[*model.Track]).Items
+				assert.Len(t, items, testCase.expectItems)
+				assert.Equal(t, testCase.link, items[0].Link)
+			}
+		})
+	}
+}
+
+func Test_FindForExistingArtist(t *testing.T) {
+	testCases := []struct {
+		name         string
+		storedFor    []model.StreamingServiceType
+		expectCalls  int
+		expectStored int
+	}{
+		{
+			name:         "complete artist",
+			storedFor:    sstesting.ServiceKeys,
+			expectCalls:  0,
+			expectStored: 3,
+		},
+		{
+			name:         "artist missing a service",
+			storedFor:    []model.StreamingServiceType{model.SpotifyStreamingService, model.DeezerStreamingService},
+			expectCalls:  1,
+			expectStored: 3,
+		},
+		{
+			name:         "artist only known to one service",
+			storedFor:    []model.StreamingServiceType{model.SpotifyStreamingService},
+			expectCalls:  2,
+			expectStored: 3,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			var artists []*model.Artist
+			for _, key := range testCase.storedFor {
+				artist := sstesting.Artist(key)
+				artist.ArtistId = "artist-id"
+				artists = append(artists, artist)
+			}
+
+			_, err := f.repo.AddArtist(context.Background(), artists)
+			require.NoError(t, err)
+
+			res, err := findForExistingArtist(context.Background(), artists[0], f.provider, f.repo, &singleflight.Group{}, testLogger())
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expectCalls, f.totalCalls())
+			assert.Len(t, res.Items, len(sstesting.ServiceKeys))
+			for _, item := range res.Items {
+				assert.Equal(t, "artist-id", item.ArtistId)
+			}
+
+			stored, err := f.repo.GetArtistsById(context.Background(), "artist-id")
+			require.NoError(t, err)
+			assert.Len(t, stored, testCase.expectStored)
+		})
+	}
+}
+
+func Test_FindForExistingAlbum(t *testing.T) {
+	testCases := []struct {
+		name         string
+		storedFor    []model.StreamingServiceType
+		failing      []model.StreamingServiceType
+		expectCalls  int
+		expectItems  int
+		expectStored int
+	}{
+		{
+			name:         "complete album",
+			storedFor:    sstesting.ServiceKeys,
+			expectCalls:  0,
+			expectItems:  3,
+			expectStored: 3,
+		},
+		{
+			name:         "album missing a service",
+			storedFor:    []model.StreamingServiceType{model.SpotifyStreamingService, model.DeezerStreamingService},
+			expectCalls:  1,
+			expectItems:  3,
+			expectStored: 3,
+		},
+		{
+			name:         "album missing a failing service",
+			storedFor:    []model.StreamingServiceType{model.SpotifyStreamingService, model.DeezerStreamingService},
+			failing:      []model.StreamingServiceType{model.AppleMusicStreamingService},
+			expectCalls:  1,
+			expectItems:  2,
+			expectStored: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			for _, key := range testCase.failing {
+				f.services[key].WithError(fmt.Errorf("oops"))
+			}
+
+			var albums []*model.Album
+			for _, key := range testCase.storedFor {
+				album := sstesting.Album(key)
+				album.AlbumId = "album-id"
+				albums = append(albums, album)
+			}
+
+			_, err := f.repo.AddAlbum(context.Background(), albums)
+			require.NoError(t, err)
+
+			res, err := findForExistingAlbum(context.Background(), albums[0], f.provider, f.repo, &singleflight.Group{}, testLogger())
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expectCalls, f.totalCalls())
+			assert.Len(t, res.Items, testCase.expectItems)
+			assertSameAlbumId(t, res.Items)
+
+			stored, err := f.repo.GetAlbumsById(context.Background(), "album-id")
+			require.NoError(t, err)
+			assert.Len(t, stored, testCase.expectStored)
+		})
+	}
+}
+
+func Test_FindForExistingTrack(t *testing.T) {
+	testCases := []struct {
+		name         string
+		storedFor    []model.StreamingServiceType
+		disabled     []model.StreamingServiceType
+		expectCalls  int
+		expectItems  int
+		expectStored int
+	}{
+		{
+			name:         "complete track",
+			storedFor:    sstesting.ServiceKeys,
+			expectCalls:  0,
+			expectItems:  3,
+			expectStored: 3,
+		},
+		{
+			name:         "track missing a service",
+			storedFor:    []model.StreamingServiceType{model.AppleMusicStreamingService},
+			expectCalls:  2,
+			expectItems:  3,
+			expectStored: 3,
+		},
+		{
+			name:         "track missing a disabled service",
+			storedFor:    []model.StreamingServiceType{model.AppleMusicStreamingService, model.SpotifyStreamingService},
+			disabled:     []model.StreamingServiceType{model.DeezerStreamingService},
+			expectCalls:  0,
+			expectItems:  2,
+			expectStored: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			for _, key := range testCase.disabled {
+				f.services[key].Disabled()
+			}
+
+			var tracks []*model.Track
+			for _, key := range testCase.storedFor {
+				tracks = append(tracks, sstesting.Track(key))
+			}
+
+			_, err := f.repo.AddTracks(context.Background(), tracks)
+			require.NoError(t, err)
+
+			res, err := findForIsrc(context.Background(), tracks[0].Isrc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expectCalls, f.totalCalls())
+			assert.Len(t, res.Items, testCase.expectItems)
+
+			stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+			require.NoError(t, err)
+			assert.Len(t, stored, testCase.expectStored)
+		})
+	}
+}
+
+func assertSameArtistId(t *testing.T, artists []*model.Artist) {
+	require.NotEmpty(t, artists)
+	id := artists[0].ArtistId
+	assert.NotEmpty(t, id)
+	for _, artist := range artists {
+		assert.Equal(t, id, artist.ArtistId)
+	}
+}
+
+func assertSameAlbumId(t *testing.T, albums []*model.Album) {
+	require.NotEmpty(t, albums)
+	id := albums[0].AlbumId
+	assert.NotEmpty(t, id)
+	for _, album := range albums {
+		assert.Equal(t, id, album.AlbumId)
+	}
+}
+
+func Test_FindForInput(t *testing.T) {
+	const testUpc = "074643529821"
+
+	testCases := []struct {
+		name          string
+		input         string
+		expectInvalid bool
+		expectType    model.Type
+		expectItems   int
+	}{
+		{
+			name:        "link",
+			input:       sstesting.LinkFor(model.DeezerStreamingService, model.TrackType, "surrender"),
+			expectType:  model.TrackType,
+			expectItems: 3,
+		},
+		{
+			name:        "isrc",
+			input:       "us-um7-17-03861",
+			expectType:  model.TrackType,
+			expectItems: 3,
+		},
+		{
+			name:        "upc",
+			input:       testUpc,
+			expectType:  model.AlbumType,
+			expectItems: 3,
+		},
+		{
+			name:          "something else",
+			input:         "cheap trick surrender",
+			expectInvalid: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			for key, svc := range f.services {
+				album := sstesting.Album(key)
+				album.Upc = testUpc
+				svc.Remove(album.Link).WithAlbums(album)
+			}
+
+			res, found, err := findForInput(context.Background(), testCase.input, f.provider, f.repo, testParser(), &singleflight.Group{}, testLogger())
+			if testCase.expectInvalid {
+				assert.ErrorIs(t, err, streamingservice.ErrInvalidInput)
+				assert.Equal(t, 0, f.totalCalls())
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, found)
+
+			switch testCase.expectType {
+			case model.TrackType:
+				assert.Len(t, res.(*Result[*model.Track]).Items, testCase.expectItems)
+			case model.AlbumType:
+				assert.Len(t, res.(*Result[*model.Album]).Items, testCase.expectItems)
+			}
+		})
+	}
+}
+
+func Test_FindForUpcReusesStoredAlbums(t *testing.T) {
+	const testUpc = "074643529821"
+
+	f := newTestFixture()
+	ctx := context.Background()
+
+	// We already know about the album on Spotify, but not its UPC
+	existing := sstesting.Album(model.SpotifyStreamingService)
+	existing.AlbumId = "album-1"
+	_, err := f.repo.AddAlbum(ctx, []*model.Album{existing})
+	require.NoError(t, err)
+
+	for key, svc := range f.services {
+		album := sstesting.Album(key)
+		album.Upc = testUpc
+		svc.Remove(album.Link).WithAlbums(album)
+	}
+
+	res, err := findForUpc(ctx, testUpc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	require.Len(t, res.Items, 3)
+
+	for _, album := range res.Items {
+		assert.Equal(t, "album-1", album.AlbumId)
+	}
+
+	stored, err := f.repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+
+	// Now that we know the UPC, there's no need to ask the services again
+	callsBefore := f.totalCalls()
+	res, err = findForUpc(ctx, testUpc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 3)
+	assert.Equal(t, callsBefore, f.totalCalls())
+}
+
+func Test_FindForUpcMergesAlbumsMatchedSeparately(t *testing.T) {
+	const testUpc = "074643529821"
+
+	f := newTestFixture()
+	ctx := context.Background()
+
+	// The Spotify album was matched up with something else before we knew its UPC
+	appleMusicAlbum := sstesting.Album(model.AppleMusicStreamingService)
+	appleMusicAlbum.AlbumId = "album-1"
+	appleMusicAlbum.Upc = testUpc
+	spotifyAlbum := sstesting.Album(model.SpotifyStreamingService)
+	spotifyAlbum.AlbumId = "album-2"
+	_, err := f.repo.AddAlbum(ctx, []*model.Album{appleMusicAlbum, spotifyAlbum})
+	require.NoError(t, err)
+
+	for key, svc := range f.services {
+		album := sstesting.Album(key)
+		album.Upc = testUpc
+		svc.Remove(album.Link).WithAlbums(album)
+	}
+
+	res, err := findForUpc(ctx, testUpc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	require.Len(t, res.Items, 3)
+
+	for _, album := range res.Items {
+		assert.Equal(t, "album-1", album.AlbumId)
+	}
+
+	stored, err := f.repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+
+	stored, err = f.repo.GetAlbumsById(ctx, "album-2")
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func Test_FindForUpcSkipsDeadAlbums(t *testing.T) {
+	const testUpc = "074643529821"
+
+	f := newTestFixture()
+	ctx := context.Background()
+
+	dead := sstesting.Album(model.SpotifyStreamingService)
+	dead.AlbumId = "album-1"
+	dead.Dead = true
+	_, err := f.repo.AddAlbum(ctx, []*model.Album{dead})
+	require.NoError(t, err)
+
+	for key, svc := range f.services {
+		album := sstesting.Album(key)
+		album.Upc = testUpc
+		svc.Remove(album.Link).WithAlbums(album)
+	}
+
+	res, err := findForUpc(ctx, testUpc, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 2)
+	assert.False(t, res.HasResultFor(model.SpotifyStreamingService))
+	assert.Equal(t, StatusNotFound, res.Services[model.SpotifyStreamingService].Status)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	embedWidth  = 400
+	embedHeight = 152
+)
+
+// OEmbed is a rich oEmbed response, see https://oembed.com
+type OEmbed struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title,omitempty"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+func GetOEmbedHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		q := r.URL.Query()
+		link := strings.TrimSpace(q.Get("url"))
+		if len(link) == 0 {
+			responses.BadRequest(w, "missing parameter \"url\"")
+			return
+		}
+
+		// JSON is the only format we support, the spec says anything else is "not implemented"
+		if format := q.Get("format"); len(format) > 0 && format != "json" {
+			responses.NotImplementedf(w, "unsupported format %s", format)
+			return
+		}
+
+		width, err := embedSize(q.Get("maxwidth"), embedWidth)
+		if err != nil {
+			responses.BadRequestf(w, "invalid maxwidth: %s", err.Error())
+			return
+		}
+
+		height, err := embedSize(q.Get("maxheight"), embedHeight)
+		if err != nil {
+			responses.BadRequestf(w, "invalid maxheight: %s", err.Error())
+			return
+		}
+
+		page, err := findOEmbedPage(r.Context(), link, apiConfig, serviceProvider, repo, parser, group, reqLogger)
+		if err != nil {
+			if errors.Is(err, streamingservice.ErrInvalidInput) {
+				responses.BadRequest(w, err.Error())
+				return
+			}
+
+			responses.Error(w, err)
+			return
+		}
+
+		if page == nil {
+			responses.NotFound(w, "could not find anything")
+			return
+		}
+
+		page.setLinks(apiConfig.PublicURL())
+
+		res := &OEmbed{
+			Type:         "rich",
+			Version:      "1.0",
+			Title:        page.Title,
+			AuthorName:   strings.Join(page.ArtistNames, ", "),
+			ProviderName: "Maestro",
+			ProviderURL:  apiConfig.PublicURL(),
+			HTML: fmt.Sprintf(
+				`<iframe src="%s" width="%d" height="%d" title="%s" frameborder="0" loading="lazy"></iframe>`,
+				template.HTMLEscapeString(page.EmbedLink),
+				width,
+				height,
+				template.HTMLEscapeString(page.Title)),
+			Width:  width,
+			Height: height,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		responses.Response(w, res, http.StatusOK)
+	}
+}
+
+// findOEmbedPage finds what the given link points to. Links to our own share pages are looked up by their ID,
+// anything else goes through the same lookup as /link.
+func findOEmbedPage(ctx context.Context, link string, apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, logger *logrus.Entry) (*sharePage, error) {
+	if typ, id, ok := parseSharePageLink(apiConfig.PublicURL(), link); ok {
+		return findSharePage(ctx, typ, id, serviceProvider, repo, group, logger)
+	}
+
+	res, found, err := findForInput(ctx, link, serviceProvider, repo, parser, group, logger)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return sharePageFor(res, serviceProvider), nil
+}
+
+// parseSharePageLink finds the type and ID in a link to one of our share pages
+func parseSharePageLink(publicURL string, link string) (model.Type, string, bool) {
+	path, ok := strings.CutPrefix(link, publicURL+"/share/")
+	if !ok {
+		return model.UnknownType, "", false
+	}
+
+	typ, id, ok := strings.Cut(path, "/")
+	if !ok || !isShareable(model.Type(typ)) {
+		return model.UnknownType, "", false
+	}
+
+	id, err := url.PathUnescape(id)
+	if err != nil || len(id) == 0 {
+		return model.UnknownType, "", false
+	}
+
+	return model.Type(typ), id, true
+}
+
+// embedSize returns the default size, unless the consumer has asked for something smaller
+func embedSize(max string, def int) (int, error) {
+	if len(max) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(max)
+	if err != nil {
+		return 0, err
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("must be greater than 0")
+	}
+
+	if n < def {
+		return n, nil
+	}
+
+	return def, nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func getOEmbed(t *testing.T, f *testFixture, params url.Values) *httptest.ResponseRecorder {
+	handler := GetOEmbedHandler(testApiConfig(), f.provider, f.repo, testParser(), &singleflight.Group{}, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/oembed?"+params.Encode(), nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_OEmbedForServiceLink(t *testing.T) {
+	f := newTestFixture()
+
+	rec := getOEmbed(t, f, url.Values{
+		"url":      {sstesting.LinkFor(model.DeezerStreamingService, model.TrackType, "surrender")},
+		"format":   {"json"},
+		"maxwidth": {"300"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var res OEmbed
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+	assert.Equal(t, "rich", res.Type)
+	assert.Equal(t, "1.0", res.Version)
+	assert.Equal(t, "Surrender by Cheap Trick", res.Title)
+	assert.Equal(t, "Cheap Trick", res.AuthorName)
+	assert.Equal(t, 300, res.Width)
+	assert.Equal(t, embedHeight, res.Height)
+	assert.Equal(t, `<iframe src="https://maestro.test/embed/track/USUM71703861" width="300" height="152" title="Surrender by Cheap Trick" frameborder="0" loading="lazy"></iframe>`, res.HTML)
+}
+
+func Test_OEmbedForSharePage(t *testing.T) {
+	f := newTestFixture()
+
+	// Find the track first, so that the share page has something to show
+	rec := getOEmbed(t, f, url.Values{"url": {sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	calls := f.totalCalls()
+
+	rec = getOEmbed(t, f, url.Values{"url": {"https://maestro.test/share/track/USUM71703861"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, calls, f.totalCalls())
+
+	var res OEmbed
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, embedWidth, res.Width)
+	assert.Contains(t, res.HTML, `src="https://maestro.test/embed/track/USUM71703861"`)
+}
+
+func Test_SharePageLinksToOEmbed(t *testing.T) {
+	f := newTestFixture()
+
+	rec := getSharePage(t, f, "track", sstesting.Isrc)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<link rel="alternate" type="application/json+oembed" href="https://maestro.test/oembed?format=json&amp;url=https%3A%2F%2Fmaestro.test%2Fshare%2Ftrack%2FUSUM71703861" title="Surrender by Cheap Trick">`)
+}
+
+func Test_OEmbedRejectsBadRequests(t *testing.T) {
+	link := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+
+	testCases := []struct {
+		name   string
+		params url.Values
+		expect int
+	}{
+		{
+			name:   "missing url",
+			params: url.Values{"format": {"json"}},
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "xml",
+			params: url.Values{"url": {link}, "format": {"xml"}},
+			expect: http.StatusNotImplemented,
+		},
+		{
+			name:   "invalid maxwidth",
+			params: url.Values{"url": {link}, "maxwidth": {"wide"}},
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "not a link",
+			params: url.Values{"url": {"cheap trick surrender"}},
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "share page for something we don't know about",
+			params: url.Values{"url": {"https://maestro.test/share/album/does-not-exist"}},
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := getOEmbed(t, f, testCase.params)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
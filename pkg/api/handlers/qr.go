@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/qr"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+const (
+	defaultQRSize   = 512
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+
+	// Share pages don't move, but the artwork can change, so it's worth checking again every now and then
+	qrCacheMaxAge = 24 * time.Hour
+
+	// Artwork is usually well under this, anything bigger isn't worth waiting for
+	maxArtworkBytes = 10 << 20
+
+	// Images can be small to download but huge once they're decoded, so anything with more pixels than this is skipped
+	maxArtworkPixels = 4096 * 4096
+)
+
+var errArtworkTooLarge = errors.New("artwork is too large")
+
+// GetQRCodeHandler renders a QR code which links to the share page for something, as either a PNG or an SVG
+func GetQRCodeHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, client *http.Client, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		vars := mux.Vars(r)
+		typ := model.Type(vars["type"])
+		id, ok := vars["id"]
+		if !ok {
+			responses.BadRequest(w, "missing parameter \"id\"")
+			return
+		}
+
+		format := vars["format"]
+		if format != "png" && format != "svg" {
+			responses.BadRequestf(w, "unknown format %s, expected one of png or svg", format)
+			return
+		}
+
+		if !isShareable(typ) {
+			responses.BadRequestf(w, "unknown type %s", typ)
+			return
+		}
+
+		opts, withArtwork, err := qrOptions(r)
+		if err != nil {
+			responses.BadRequest(w, err.Error())
+			return
+		}
+
+		page, err := storedSharePage(r.Context(), typ, id, serviceProvider, repo)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if page == nil {
+			responses.NotFoundf(w, "could not find any %ss with ID %s", typ, id)
+			return
+		}
+
+		page.setLinks(apiConfig.PublicURL())
+
+		var body []byte
+		var contentType string
+		switch format {
+		case "png":
+			// The QR code is still useful without the artwork, so it's left out if it can't be found
+			var artwork image.Image
+			if withArtwork && len(page.ImageLink) > 0 {
+				artwork, err = fetchArtwork(r.Context(), client, page.ImageLink)
+				if err != nil {
+					reqLogger.Warnf("failed to fetch artwork for QR code: %s", err.Error())
+				}
+			}
+
+			body, err = qr.PNG(page.PageLink, opts, artwork)
+			contentType = "image/png"
+
+		case "svg":
+			var artworkLink string
+			if withArtwork {
+				artworkLink = page.ImageLink
+			}
+
+			body, err = qr.SVG(page.PageLink, opts, artworkLink)
+			contentType = "image/svg+xml"
+		}
+
+		if err != nil {
+			if errors.Is(err, qr.ErrTooSmall) {
+				responses.BadRequest(w, err.Error())
+				return
+			}
+
+			responses.Error(w, err)
+			return
+		}
+
+		hash := sha256.Sum256(body)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(qrCacheMaxAge.Seconds())))
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])))
+
+		// Takes care of If-None-Match for us
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	}
+}
+
+// qrOptions reads the size, level, margin, and artwork parameters
+func qrOptions(r *http.Request) (qr.Options, bool, error) {
+	q := r.URL.Query()
+
+	size, err := intParameter(q.Get("size"), defaultQRSize, minQRSize, maxQRSize)
+	if err != nil {
+		return qr.Options{}, false, fmt.Errorf("invalid size: %w", err)
+	}
+
+	margin, err := intParameter(q.Get("margin"), defaultQRMargin, 0, maxQRMargin)
+	if err != nil {
+		return qr.Options{}, false, fmt.Errorf("invalid margin: %w", err)
+	}
+
+	level := qr.LevelMedium
+	if l := q.Get("level"); len(l) > 0 {
+		level = qr.Level(strings.ToUpper(l))
+	}
+
+	switch level {
+	case qr.LevelLow, qr.LevelMedium, qr.LevelQuartile, qr.LevelHigh:
+	default:
+		return qr.Options{}, false, fmt.Errorf("unknown level %s, expected one of L, M, Q, or H", level)
+	}
+
+	withArtwork := false
+	if a := q.Get("artwork"); len(a) > 0 {
+		withArtwork, err = strconv.ParseBool(a)
+		if err != nil {
+			return qr.Options{}, false, fmt.Errorf("invalid artwork: %w", err)
+		}
+	}
+
+	// The artwork covers up part of the code, so it needs as much error correction as it can get
+	if withArtwork {
+		level = qr.LevelHigh
+	}
+
+	return qr.Options{Size: size, Level: level, Margin: margin}, withArtwork, nil
+}
+
+// intParameter returns the default if nothing was given, or an error if what was given is out of range
+func intParameter(value string, def int, min int, max int) (int, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < min || n > max {
+		return 0, fmt.Errorf("must be between %d and %d", min, max)
+	}
+
+	return n, nil
+}
+
+func fetchArtwork(ctx context.Context, client *http.Client, link string) (image.Image, error) {
+	artwork, err := downloadArtwork(ctx, client, link)
+	if err != nil {
+		return nil, err
+	}
+
+	return artwork.decode()
+}
+
+// downloadedArtwork is artwork which has been downloaded, but only its header has been decoded
+type downloadedArtwork struct {
+	data   []byte
+	config image.Config
+}
+
+// downloadArtwork fetches the artwork and reads its header, so we know how big it is before decoding all of it.
+// Artwork with more than maxArtworkPixels is rejected.
+func downloadArtwork(ctx context.Context, client *http.Client, link string) (*downloadedArtwork, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxArtworkBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	artwork := &downloadedArtwork{data, cfg}
+	if artwork.pixels() > maxArtworkPixels {
+		return nil, fmt.Errorf("%w: %dx%d", errArtworkTooLarge, cfg.Width, cfg.Height)
+	}
+
+	return artwork, nil
+}
+
+func (a *downloadedArtwork) pixels() int {
+	return a.config.Width * a.config.Height
+}
+
+func (a *downloadedArtwork) decode() (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(a.data))
+	return img, err
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+)
+
+func getQRCode(t *testing.T, f *testFixture, client *http.Client, typ string, id string, format string, query string, header http.Header) *httptest.ResponseRecorder {
+	handler := GetQRCodeHandler(testApiConfig(), f.provider, f.repo, client, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/qr/"+typ+"/"+id+"."+format+query, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+	req = mux.SetURLVars(req, map[string]string{"type": typ, "id": id, "format": format})
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// newArtworkServer serves a solid red square
+func newArtworkServer(t *testing.T) *httptest.Server {
+	artwork := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			artwork.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, artwork))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(buf.Bytes())
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// pngHeader is the start of a PNG which says it's width x height, without any of the pixels
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // bit depth
+	ihdr[13] = 2 // truecolour
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)-4))
+	buf.Write(ihdr)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	return buf.Bytes()
+}
+
+func Test_FetchArtworkSkipsHugeImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(pngHeader(50000, 50000))
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := fetchArtwork(context.Background(), srv.Client(), srv.URL+"/artwork.png")
+	assert.ErrorIs(t, err, errArtworkTooLarge)
+}
+
+func Test_QRCodePNG(t *testing.T) {
+	f := newTestFixture()
+	srv := newArtworkServer(t)
+
+	track := sstesting.Track(model.SpotifyStreamingService)
+	track.ArtworkLink = srv.URL + "/artwork.png"
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{track})
+	require.NoError(t, err)
+
+	rec := getQRCode(t, f, srv.Client(), "track", sstesting.Isrc, "png", "?size=200&artwork=true", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=86400", rec.Header().Get("Cache-Control"))
+
+	img, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 200), img.Bounds())
+
+	r, g, b, _ := img.At(100, 100).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0, 0}, []uint32{r, g, b})
+
+	// Asking again with the same ETag shouldn't send the whole thing again
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec = getQRCode(t, f, srv.Client(), "track", sstesting.Isrc, "png", "?size=200&artwork=true", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+}
+
+func Test_QRCodeSVG(t *testing.T) {
+	f := newTestFixture()
+
+	track := sstesting.Track(model.SpotifyStreamingService)
+	track.ArtworkLink = "https://images.test/surrender.jpg"
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{track})
+	require.NoError(t, err)
+
+	rec := getQRCode(t, f, http.DefaultClient, "track", sstesting.Isrc, "svg", "?artwork=1&margin=0", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/svg+xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `href="https://images.test/surrender.jpg"`)
+
+	rec = getQRCode(t, f, http.DefaultClient, "track", sstesting.Isrc, "svg", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "<image")
+}
+
+func Test_QRCodeRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name   string
+		typ    string
+		id     string
+		format string
+		query  string
+		expect int
+	}{
+		{
+			name:   "unknown type",
+			typ:    "playlist",
+			id:     "123",
+			format: "png",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown format",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			format: "gif",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "too big",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			format: "png",
+			query:  "?size=100000",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown level",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			format: "svg",
+			query:  "?level=Z",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "margin too big",
+			typ:    "track",
+			id:     sstesting.Isrc,
+			format: "svg",
+			query:  "?margin=100",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown track",
+			typ:    "track",
+			id:     "does-not-exist",
+			format: "png",
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			addTestTracks(t, f, model.SpotifyStreamingService)
+
+			rec := getQRCode(t, f, http.DefaultClient, testCase.typ, testCase.id, testCase.format, testCase.query, nil)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/analytics"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+const (
+	servicePreferenceCookieName = "maestro_services"
+	servicePreferenceMaxAge     = 365 * 24 * time.Hour
+)
+
+// GetRedirectHandler sends people straight to the thing on the service they prefer.
+// Preferences are given as an Accept-style list in the service parameter, e.g. "spotify,deezer;q=0.5" (escaped, as
+// Go won't parse unescaped semicolons in query strings), and are remembered in a cookie
+// so that later links go to the same place without asking.
+func GetRedirectHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, analyticsRec analytics.Recorder, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		vars := mux.Vars(r)
+		typ := model.Type(vars["type"])
+		id, ok := vars["id"]
+		if !ok {
+			responses.BadRequest(w, "missing parameter \"id\"")
+			return
+		}
+
+		if !isShareable(typ) {
+			responses.BadRequestf(w, "unknown type %s", typ)
+			return
+		}
+
+		var preferences []model.StreamingServiceType
+		if requested := r.URL.Query().Get("service"); len(requested) > 0 {
+			preferences = parseServicePreferences(requested, serviceProvider)
+			if len(preferences) == 0 {
+				responses.BadRequestf(w, "unknown service %s", requested)
+				return
+			}
+
+			rememberServicePreferences(w, apiConfig, preferences)
+		} else if cookie, err := r.Cookie(servicePreferenceCookieName); err == nil {
+			// The cookie is only a hint, if it's been mangled we'll just pick a service ourselves
+			if value, err := url.QueryUnescape(cookie.Value); err == nil {
+				preferences = parseServicePreferences(value, serviceProvider)
+			}
+		}
+
+		things, err := findThings(r.Context(), repo, typ, id)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		thing := preferredThing(things, preferences, serviceProvider)
+		if thing == nil {
+			responses.NotFoundf(w, "could not find any %ss with ID %s", typ, id)
+			return
+		}
+
+		reqLogger.Debugf("redirecting to %s", thing.GetLink())
+		analyticsRec.RecordClick(r, typ, id, thing.GetSource())
+
+		// Where we send people depends on their cookie, so shared caches shouldn't hold on to it
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Add("Vary", "Cookie")
+		http.Redirect(w, r, thing.GetLink(), http.StatusFound)
+	}
+}
+
+// findThings returns everything we know about with the given ID, the same way /artist/{id}, /album/{id}, and
+// /track/{isrc} do, but without asking the streaming services. Dead links are left out, there's no point sending
+// anyone to them.
+func findThings(ctx context.Context, repo db.Repository, typ model.Type, id string) ([]model.Thing, error) {
+	switch typ {
+	case model.ArtistType:
+		artists, err := repo.GetArtistsById(ctx, id)
+		return toThings(artists), err
+
+	case model.AlbumType:
+		albums, err := repo.GetAlbumsById(ctx, id)
+		return toThings(albums), err
+
+	case model.TrackType:
+		tracks, err := repo.GetTracksByIsrc(ctx, id)
+		return toThings(tracks), err
+
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+}
+
+func toThings[T model.Thing](items []T) []model.Thing {
+	things := make([]model.Thing, 0, len(items))
+	for _, item := range items {
+		if item.IsDead() {
+			continue
+		}
+
+		things = append(things, item)
+	}
+
+	return things
+}
+
+// preferredThing returns the thing from the most preferred service. If none of the preferred services have it,
+// the first one by service name is used so that the same link always goes to the same place.
+func preferredThing(things []model.Thing, preferences []model.StreamingServiceType, serviceProvider streamingservice.ServiceProvider) model.Thing {
+	if len(things) == 0 {
+		return nil
+	}
+
+	for _, preference := range preferences {
+		for _, thing := range things {
+			if thing.GetSource() == preference {
+				return thing
+			}
+		}
+	}
+
+	configs := serviceProvider.ListConfigs()
+	serviceName := func(key model.StreamingServiceType) string {
+		if cfg, ok := configs[key]; ok {
+			return cfg.Name()
+		}
+
+		return key.String()
+	}
+
+	best := things[0]
+	for _, thing := range things[1:] {
+		if serviceName(thing.GetSource()) < serviceName(best.GetSource()) {
+			best = thing
+		}
+	}
+
+	return best
+}
+
+// parseServicePreferences reads an Accept-style list of services, e.g. "spotify, deezer;q=0.5, apple_music;q=0.8",
+// and returns the services in order of preference. Services we don't know about, and anything with q=0, are dropped.
+func parseServicePreferences(value string, serviceProvider streamingservice.ServiceProvider) []model.StreamingServiceType {
+	type preference struct {
+		service model.StreamingServiceType
+		quality float64
+	}
+
+	var preferences []preference
+	seen := make(map[model.StreamingServiceType]bool)
+	for _, part := range strings.Split(value, ",") {
+		key, params, _ := strings.Cut(part, ";")
+		service := model.StreamingServiceType(strings.ToLower(strings.TrimSpace(key)))
+		if len(service) == 0 || seen[service] {
+			continue
+		}
+
+		if _, err := serviceProvider.GetConfig(service); err != nil {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, q, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				continue
+			}
+
+			quality = parsed
+		}
+
+		seen[service] = true
+		if quality > 0 {
+			preferences = append(preferences, preference{service, quality})
+		}
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+
+	services := make([]model.StreamingServiceType, 0, len(preferences))
+	for _, p := range preferences {
+		services = append(services, p.service)
+	}
+
+	return services
+}
+
+// rememberServicePreferences stores the preferences in a cookie. They're already in order, so the q-values
+// don't need to be kept.
+func rememberServicePreferences(w http.ResponseWriter, apiConfig config.API, preferences []model.StreamingServiceType) {
+	keys := make([]string, 0, len(preferences))
+	for _, service := range preferences {
+		keys = append(keys, service.String())
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     servicePreferenceCookieName,
+		Value:    url.QueryEscape(strings.Join(keys, ",")),
+		Path:     "/go",
+		MaxAge:   int(servicePreferenceMaxAge.Seconds()),
+		Secure:   strings.HasPrefix(apiConfig.PublicURL(), "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+)
+
+func getRedirect(t *testing.T, f *testFixture, typ string, id string, service string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	handler := GetRedirectHandler(testApiConfig(), f.provider, f.repo, f.analytics, testLogger().Logger)
+
+	target := "/go/" + typ + "/" + id
+	if len(service) > 0 {
+		target += "?" + url.Values{"service": {service}}.Encode()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+	req = mux.SetURLVars(req, map[string]string{"type": typ, "id": id})
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func addTestTracks(t *testing.T, f *testFixture, keys ...model.StreamingServiceType) {
+	var tracks []*model.Track
+	for _, key := range keys {
+		tracks = append(tracks, sstesting.Track(key))
+	}
+
+	_, err := f.repo.AddTracks(context.Background(), tracks)
+	require.NoError(t, err)
+}
+
+func Test_Redirect(t *testing.T) {
+	testCases := []struct {
+		name    string
+		service string
+		stored  []model.StreamingServiceType
+		expect  model.StreamingServiceType
+	}{
+		{
+			name:    "requested service",
+			service: "spotify",
+			stored:  sstesting.ServiceKeys,
+			expect:  model.SpotifyStreamingService,
+		},
+		{
+			name:    "requested service doesn't have it",
+			service: "spotify",
+			stored:  []model.StreamingServiceType{model.DeezerStreamingService, model.AppleMusicStreamingService},
+			expect:  model.AppleMusicStreamingService,
+		},
+		{
+			name:    "priority list",
+			service: "spotify;q=0.2,deezer;q=0.9,apple_music;q=0.5",
+			stored:  sstesting.ServiceKeys,
+			expect:  model.DeezerStreamingService,
+		},
+		{
+			name:    "priority list falls through",
+			service: "spotify,deezer;q=0.5",
+			stored:  []model.StreamingServiceType{model.DeezerStreamingService, model.AppleMusicStreamingService},
+			expect:  model.DeezerStreamingService,
+		},
+		{
+			name:    "unknown services in the list are ignored",
+			service: "myspace,deezer",
+			stored:  sstesting.ServiceKeys,
+			expect:  model.DeezerStreamingService,
+		},
+		{
+			name:   "no preference",
+			stored: sstesting.ServiceKeys,
+			expect: model.AppleMusicStreamingService,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			addTestTracks(t, f, testCase.stored...)
+
+			rec := getRedirect(t, f, "track", sstesting.Isrc, testCase.service)
+			require.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, sstesting.Track(testCase.expect).Link, rec.Header().Get("Location"))
+			assert.Equal(t, 0, f.totalCalls())
+
+			assert.Equal(t, []*model.AnalyticsEvent{
+				{Kind: model.ClickEvent, Type: model.TrackType, Id: sstesting.Isrc, Target: testCase.expect},
+			}, f.analytics.Events())
+		})
+	}
+}
+
+func Test_RedirectSkipsDeadLinks(t *testing.T) {
+	f := newTestFixture()
+
+	spotifyTrack := sstesting.Track(model.SpotifyStreamingService)
+	spotifyTrack.Dead = true
+	deezerTrack := sstesting.Track(model.DeezerStreamingService)
+
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{spotifyTrack, deezerTrack})
+	require.NoError(t, err)
+
+	rec := getRedirect(t, f, "track", sstesting.Isrc, "spotify")
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, deezerTrack.Link, rec.Header().Get("Location"))
+}
+
+func Test_RedirectReturnsNotFoundWhenOnlyDeadLinksAreLeft(t *testing.T) {
+	f := newTestFixture()
+
+	track := sstesting.Track(model.SpotifyStreamingService)
+	track.Dead = true
+
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{track})
+	require.NoError(t, err)
+
+	rec := getRedirect(t, f, "track", sstesting.Isrc, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, f.analytics.Events())
+}
+
+func Test_RedirectRemembersPreference(t *testing.T) {
+	f := newTestFixture()
+	addTestTracks(t, f, sstesting.ServiceKeys...)
+
+	rec := getRedirect(t, f, "track", sstesting.Isrc, "deezer;q=0.5,spotify")
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, sstesting.Track(model.SpotifyStreamingService).Link, rec.Header().Get("Location"))
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, servicePreferenceCookieName, cookies[0].Name)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// Spotify doesn't have the next one, so it should go to the next preferred service rather than the default
+	f = newTestFixture()
+	addTestTracks(t, f, model.AppleMusicStreamingService, model.DeezerStreamingService)
+
+	rec = getRedirect(t, f, "track", sstesting.Isrc, "", cookies[0])
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, sstesting.Track(model.DeezerStreamingService).Link, rec.Header().Get("Location"))
+	assert.Empty(t, rec.Result().Cookies())
+
+	// Asking for something else explicitly wins over the cookie
+	rec = getRedirect(t, f, "track", sstesting.Isrc, "apple_music", cookies[0])
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, sstesting.Track(model.AppleMusicStreamingService).Link, rec.Header().Get("Location"))
+}
+
+func Test_RedirectRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name    string
+		typ     string
+		id      string
+		service string
+		expect  int
+	}{
+		{
+			name:   "unknown type",
+			typ:    "playlist",
+			id:     "123",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:    "unknown service",
+			typ:     "track",
+			id:      sstesting.Isrc,
+			service: "myspace",
+			expect:  http.StatusBadRequest,
+		},
+		{
+			name:   "unknown track",
+			typ:    "track",
+			id:     "does-not-exist",
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := getRedirect(t, f, testCase.typ, testCase.id, testCase.service)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Empty(t, rec.Result().Cookies())
+			assert.Empty(t, f.analytics.Events())
+		})
+	}
+}
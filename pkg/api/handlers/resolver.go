@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
+)
+
+// Resolver looks for things we already know about on any services which are missing, the same way a lookup does.
+// It shares the lookups' singleflight.Group, so a lookup and the ResolveWorker can't add the same things twice.
+type Resolver struct {
+	serviceProvider streamingservice.ServiceProvider
+	repo            db.Repository
+	group           *singleflight.Group
+	logger          *logrus.Logger
+}
+
+func NewResolver(serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Logger) *Resolver {
+	return &Resolver{
+		serviceProvider: serviceProvider,
+		repo:            repo,
+		group:           group,
+		logger:          logger,
+	}
+}
+
+// Resolve looks for the artist or album with the given ID, or the track with the given ISRC, on any missing services.
+// It returns how many services it was found on which it wasn't before.
+func (r *Resolver) Resolve(ctx context.Context, typ model.Type, id string) (int, error) {
+	logger := r.logger.WithField("resolve", fmt.Sprintf("%s:%s", typ, id))
+
+	switch typ {
+	case model.ArtistType:
+		existing, err := r.repo.GetArtistsById(ctx, id)
+		if err != nil || len(existing) == 0 {
+			return 0, err
+		}
+
+		res, err := findForExistingArtist(ctx, existing[0], r.serviceProvider, r.repo, r.group, logger)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(res.Items) - countAlive(existing), nil
+
+	case model.AlbumType:
+		existing, err := r.repo.GetAlbumsById(ctx, id)
+		if err != nil || len(existing) == 0 {
+			return 0, err
+		}
+
+		res, err := findForExistingAlbum(ctx, existing[0], r.serviceProvider, r.repo, r.group, logger)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(res.Items) - countAlive(existing), nil
+
+	case model.TrackType:
+		existing, err := r.repo.GetTracksByIsrc(ctx, id)
+		if err != nil || len(existing) == 0 {
+			return 0, err
+		}
+
+		res, err := findForIsrc(ctx, id, r.serviceProvider, r.repo, r.group, logger)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(res.Items) - countAlive(existing), nil
+
+	default:
+		return 0, fmt.Errorf("unknown type %s", typ)
+	}
+}
+
+func countAlive[T model.Thing](things []T) int {
+	n := 0
+	for _, thing := range things {
+		if !thing.IsDead() {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func Test_ResolverSharesLookups(t *testing.T) {
+	testCases := []struct {
+		name   string
+		typ    model.Type
+		id     string
+		link   string
+		stored func(ctx context.Context, f *testFixture) (int, error)
+	}{
+		{
+			name: "artist",
+			typ:  model.ArtistType,
+			id:   "artist-id",
+			link: sstesting.LinkFor(model.SpotifyStreamingService, model.ArtistType, "cheap-trick"),
+			stored: func(ctx context.Context, f *testFixture) (int, error) {
+				artists, err := f.repo.GetArtistsById(ctx, "artist-id")
+				return len(artists), err
+			},
+		},
+		{
+			name: "album",
+			typ:  model.AlbumType,
+			id:   "album-id",
+			link: sstesting.LinkFor(model.SpotifyStreamingService, model.AlbumType, "heaven-tonight"),
+			stored: func(ctx context.Context, f *testFixture) (int, error) {
+				albums, err := f.repo.GetAlbumsById(ctx, "album-id")
+				return len(albums), err
+			},
+		},
+		{
+			name: "track",
+			typ:  model.TrackType,
+			id:   sstesting.Isrc,
+			link: sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+			stored: func(ctx context.Context, f *testFixture) (int, error) {
+				tracks, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+				return len(tracks), err
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			ctx := context.Background()
+			for _, svc := range f.services {
+				svc.WithDelay(50 * time.Millisecond)
+			}
+
+			// Only Spotify is known about so far
+			artist := sstesting.Artist(model.SpotifyStreamingService)
+			artist.ArtistId = "artist-id"
+			_, err := f.repo.AddArtist(ctx, []*model.Artist{artist})
+			require.NoError(t, err)
+
+			album := sstesting.Album(model.SpotifyStreamingService)
+			album.AlbumId = "album-id"
+			_, err = f.repo.AddAlbum(ctx, []*model.Album{album})
+			require.NoError(t, err)
+
+			_, err = f.repo.AddTracks(ctx, []*model.Track{sstesting.Track(model.SpotifyStreamingService)})
+			require.NoError(t, err)
+
+			group := &singleflight.Group{}
+			resolver := NewResolver(f.provider, f.repo, group, testLogger().Logger)
+
+			// The worker and a lookup both notice the same thing is missing services at the same time
+			wg := sync.WaitGroup{}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := resolver.Resolve(ctx, testCase.typ, testCase.id)
+				assert.NoError(t, err)
+			}()
+
+			go func() {
+				defer wg.Done()
+				_, found, err := findForLink(ctx, testCase.link, f.provider, f.repo, group, testLogger())
+				assert.NoError(t, err)
+				assert.True(t, found)
+			}()
+
+			wg.Wait()
+
+			n, err := testCase.stored(ctx, f)
+			require.NoError(t, err)
+			assert.Equal(t, len(sstesting.ServiceKeys), n, "each service should only be stored once")
+		})
+	}
+}
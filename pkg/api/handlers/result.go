@@ -2,11 +2,31 @@ package handlers
 
 import (
 	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
 )
 
+type LookupStatus string
+
+const (
+	StatusFound    LookupStatus = "found"
+	StatusNotFound LookupStatus = "not_found"
+	StatusError    LookupStatus = "error"
+	StatusTimeout  LookupStatus = "timeout"
+	StatusDisabled LookupStatus = "disabled"
+)
+
+// ServiceStatus describes what happened when we looked for something on a streaming service
+type ServiceStatus struct {
+	Status     LookupStatus
+	ErrorClass streamingservice.ErrorClass `json:",omitempty"`
+}
+
 type Result[T model.Thing] struct {
-	Type  model.Type
-	Items []T
+	Type     model.Type
+	Items    []T
+	Services map[model.StreamingServiceType]ServiceStatus `json:",omitempty"`
+
+	deadLinks map[string]bool
 }
 
 func NewResult[T model.Thing](typ model.Type) *Result[T] {
@@ -16,7 +36,20 @@ func NewResult[T model.Thing](typ model.Type) *Result[T] {
 	}
 }
 
+// Add adds the given thing to the result, replacing anything we already had from the same service.
+// Dead things are left out so that their service is treated as missing, and a replacement can be looked for.
 func (r *Result[T]) Add(t T) {
+	if t.IsDead() {
+		if r.deadLinks == nil {
+			r.deadLinks = make(map[string]bool)
+		}
+
+		r.deadLinks[t.GetLink()] = true
+		return
+	}
+
+	r.setStatus(t.GetSource(), ServiceStatus{Status: StatusFound})
+
 	if r.HasResultFor(t.GetSource()) {
 		for i, item := range r.Items {
 			if item.GetSource() == t.GetSource() {
@@ -44,6 +77,49 @@ func (r *Result[T]) HasResultFor(key model.StreamingServiceType) bool {
 	return false
 }
 
+// IsDeadLink returns true if the given link belongs to a dead thing which was left out of the result
+func (r *Result[T]) IsDeadLink(link string) bool {
+	return r.deadLinks[link]
+}
+
 func (r *Result[T]) HasResults() bool {
 	return len(r.Items) > 0
 }
+
+func (r *Result[T]) SetNotFound(key model.StreamingServiceType) {
+	r.setStatus(key, ServiceStatus{Status: StatusNotFound})
+}
+
+func (r *Result[T]) SetError(key model.StreamingServiceType, err error) {
+	class := streamingservice.ClassifyError(err)
+
+	status := StatusError
+	if class == streamingservice.ErrorClassTimeout {
+		status = StatusTimeout
+	}
+
+	r.setStatus(key, ServiceStatus{Status: status, ErrorClass: class})
+}
+
+func (r *Result[T]) SetDisabled(key model.StreamingServiceType) {
+	r.setStatus(key, ServiceStatus{Status: StatusDisabled})
+}
+
+// HasErrors returns true if any of the services couldn't be queried, meaning the result may be incomplete
+func (r *Result[T]) HasErrors() bool {
+	for _, status := range r.Services {
+		if status.Status == StatusError || status.Status == StatusTimeout {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Result[T]) setStatus(key model.StreamingServiceType, status ServiceStatus) {
+	if r.Services == nil {
+		r.Services = make(map[model.StreamingServiceType]ServiceStatus)
+	}
+
+	r.Services[key] = status
+}
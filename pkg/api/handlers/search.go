@@ -0,0 +1,656 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+// How many results we ask each service for. Anything past the first few results is rarely what people are after.
+const searchLimitPerService = 5
+
+// errInvalidChoice is returned when the links someone has chosen from a search can't be stored together
+var errInvalidChoice = errors.New("invalid choice")
+
+// Matches bracketed bits like "(Remastered 2011)" or "[Live]", and suffixes like " - Single Version"
+var searchNoisePattern = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]|\s-\s.*$`)
+
+func GetSearchHandler(serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if len(query) == 0 {
+			responses.BadRequest(w, "missing parameter \"q\"")
+			return
+		}
+
+		typ := model.TrackType
+		if t := r.URL.Query().Get("type"); len(t) > 0 {
+			typ = model.Type(t)
+		}
+
+		if typ != model.ArtistType && typ != model.AlbumType && typ != model.TrackType {
+			responses.BadRequestf(w, "unknown type %s, expected one of artist, album, or track", typ)
+			return
+		}
+
+		services, err := serviceProvider.ListServices()
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		reqLogger = reqLogger.WithField("query", query)
+		hits, errs := searchServices(query, typ, services, reqLogger)
+
+		var res any
+		switch typ {
+		case model.ArtistType:
+			groups := groupSearchHits(hits, func(*model.Artist) string { return "" }, artistSearchKey)
+			results, err := artistSearchResults(r.Context(), groups, repo)
+			if err != nil {
+				responses.Error(w, err)
+				return
+			}
+
+			setSearchStatuses(results, services, errs, serviceProvider)
+			res = results
+
+		case model.AlbumType:
+			groups := groupSearchHits(hits, func(a *model.Album) string { return a.Upc }, albumSearchKey)
+			results, err := albumSearchResults(r.Context(), groups, repo)
+			if err != nil {
+				responses.Error(w, err)
+				return
+			}
+
+			setSearchStatuses(results, services, errs, serviceProvider)
+			res = results
+
+		case model.TrackType:
+			groups := groupSearchHits(hits, func(t *model.Track) string { return t.Isrc }, trackSearchKey)
+			results, err := trackSearchResults(r.Context(), groups, repo)
+			if err != nil {
+				responses.Error(w, err)
+				return
+			}
+
+			setSearchStatuses(results, services, errs, serviceProvider)
+			res = results
+
+		default:
+			responses.Error(w, fmt.Errorf("unknown type %s", typ))
+			return
+		}
+
+		responses.Response(w, res, http.StatusOK)
+	}
+}
+
+// SearchChoiceRequest is one of the groups from a search, picked out so that it can be stored and shared
+type SearchChoiceRequest struct {
+	Type  model.Type
+	Links []string
+}
+
+// PostSearchHandler stores the links someone picked from a search under one ID, the same way /link would have.
+// Links we already know about keep their ID, and the rest are added to it.
+func PostSearchHandler(serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		var req *SearchChoiceRequest
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchRequestBytes)).Decode(&req)
+		if err != nil || req == nil {
+			responses.BadRequest(w, "request body must be a JSON object with a type and a list of links")
+			return
+		}
+
+		if req.Type != model.ArtistType && req.Type != model.AlbumType && req.Type != model.TrackType {
+			responses.BadRequestf(w, "unknown type %s, expected one of artist, album, or track", req.Type)
+			return
+		}
+
+		if len(req.Links) == 0 {
+			responses.BadRequest(w, "at least one link is required")
+			return
+		}
+
+		services, err := serviceProvider.ListServices()
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		var res any
+		switch req.Type {
+		case model.ArtistType:
+			res, err = storeChosenArtists(r.Context(), req.Links, services, serviceProvider, repo, reqLogger)
+		case model.AlbumType:
+			res, err = storeChosenAlbums(r.Context(), req.Links, services, serviceProvider, repo, reqLogger)
+		case model.TrackType:
+			res, err = storeChosenTracks(r.Context(), req.Links, services, serviceProvider, repo, reqLogger)
+		}
+
+		if err != nil {
+			if errors.Is(err, errInvalidChoice) {
+				responses.BadRequest(w, err.Error())
+				return
+			}
+
+			responses.Error(w, err)
+			return
+		}
+
+		responses.Response(w, res, http.StatusOK)
+	}
+}
+
+// searchServices runs the query against all the given services at once
+func searchServices(query string, typ model.Type, services streamingservice.StreamingServices, logger *logrus.Entry) (map[model.StreamingServiceType][]model.Thing, map[model.StreamingServiceType]error) {
+	hits := make(map[model.StreamingServiceType][]model.Thing)
+	errs := make(map[model.StreamingServiceType]error)
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for key, service := range services {
+		wg.Add(1)
+		go func(key model.StreamingServiceType, service streamingservice.StreamingService) {
+			defer wg.Done()
+
+			logger.Debugf("searching %s\n", key)
+			res, err := service.Search(query, typ, searchLimitPerService)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				logServiceError(logger, key, err)
+				errs[key] = err
+				return
+			}
+
+			hits[key] = res
+		}(key, service)
+	}
+
+	wg.Wait()
+
+	return hits, errs
+}
+
+type searchGroup[T model.Thing] struct {
+	items []T
+	id    string
+	key   string
+	rank  int
+}
+
+func (g *searchGroup[T]) hasResultFor(key model.StreamingServiceType) bool {
+	for _, item := range g.items {
+		if item.GetSource() == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// groupSearchHits clusters the results from each service into groups which (hopefully) refer to the same thing.
+// Results are first grouped by their ID (ISRC or UPC), then anything without an ID is matched up using its metadata.
+// Groups only ever have one result from each service, and the groups which were found on the most services come first.
+func groupSearchHits[T model.Thing](hits map[model.StreamingServiceType][]model.Thing, idOf func(T) string, keyOf func(T) string) []*searchGroup[T] {
+
+	// Services are sorted so that the grouping doesn't depend on map ordering
+	var keys []model.StreamingServiceType
+	for key := range hits {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	var groups []*searchGroup[T]
+	var unmatched []*searchGroup[T]
+
+	// Each service's results are best matches first, so we go through everyone's first result, then second, and so on
+	for rank := 0; rank < searchLimitPerService; rank++ {
+		for _, key := range keys {
+			if rank >= len(hits[key]) {
+				continue
+			}
+
+			item, ok := hits[key][rank].(T)
+			if !ok {
+				continue
+			}
+
+			id := idOf(item)
+			if len(id) == 0 {
+				unmatched = append(unmatched, &searchGroup[T]{items: []T{item}, key: keyOf(item), rank: rank})
+				continue
+			}
+
+			var group *searchGroup[T]
+			for _, g := range groups {
+				if g.id == id {
+					group = g
+					break
+				}
+			}
+
+			if group == nil {
+				group = &searchGroup[T]{id: id, key: keyOf(item), rank: rank}
+				groups = append(groups, group)
+			}
+
+			// The same thing can turn up more than once on a service, we only want the best match
+			if group.hasResultFor(key) {
+				continue
+			}
+
+			group.items = append(group.items, item)
+		}
+	}
+
+	for _, u := range unmatched {
+		item := u.items[0]
+
+		var group *searchGroup[T]
+		for _, g := range groups {
+			if g.key == u.key && !g.hasResultFor(item.GetSource()) {
+				group = g
+				break
+			}
+		}
+
+		if group == nil {
+			groups = append(groups, u)
+			continue
+		}
+
+		group.items = append(group.items, item)
+		if u.rank < group.rank {
+			group.rank = u.rank
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].items) != len(groups[j].items) {
+			return len(groups[i].items) > len(groups[j].items)
+		}
+
+		return groups[i].rank < groups[j].rank
+	})
+
+	return groups
+}
+
+// artistSearchResults turns the groups into results. Nothing is stored, the artists we already know about are
+// returned as we know them and anything else is returned as-is, without an ID. Results are only stored once one of
+// them is looked up with /link, or the group is chosen with PostSearchHandler.
+func artistSearchResults(ctx context.Context, groups []*searchGroup[*model.Artist], repo db.Repository) ([]*Result[*model.Artist], error) {
+	results := []*Result[*model.Artist]{}
+	for _, group := range groups {
+		res := NewResult[*model.Artist](model.ArtistType)
+		for _, artist := range group.items {
+			existing, err := repo.GetArtistByLink(ctx, artist.Link)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				artist = existing
+			}
+
+			res.Add(artist)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// albumSearchResults turns the groups into results, in the same way as artistSearchResults.
+// Albums which were matched by name are left without a UPC, we can't know that they really are the same album.
+func albumSearchResults(ctx context.Context, groups []*searchGroup[*model.Album], repo db.Repository) ([]*Result[*model.Album], error) {
+	results := []*Result[*model.Album]{}
+	for _, group := range groups {
+		res := NewResult[*model.Album](model.AlbumType)
+		for _, album := range group.items {
+			existing, err := repo.GetAlbumByLink(ctx, album.Link)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				album = existing
+			}
+
+			res.Add(album)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// trackSearchResults turns the groups into results, in the same way as artistSearchResults.
+// Tracks which were matched by name are left without an ISRC, they could just as well be a live version or a remix.
+func trackSearchResults(ctx context.Context, groups []*searchGroup[*model.Track], repo db.Repository) ([]*Result[*model.Track], error) {
+	results := []*Result[*model.Track]{}
+	for _, group := range groups {
+		res := NewResult[*model.Track](model.TrackType)
+		for _, track := range group.items {
+			existing, err := repo.GetTrackByLink(ctx, track.Link)
+			if err != nil {
+				return nil, err
+			}
+
+			if existing != nil {
+				track = existing
+			}
+
+			res.Add(track)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// fetchChosen gets each of the chosen things, from the database if we already know about them or from their service if not.
+// The ones we already know about are returned separately, as they already have an ID.
+func fetchChosen[T model.Thing](ctx context.Context, typ model.Type, links []string, services streamingservice.StreamingServices, repo db.Repository, logger *logrus.Entry) ([]T, []T, error) {
+	var stored []T
+	var fetched []T
+
+	seen := make(map[model.StreamingServiceType]bool)
+	for _, link := range links {
+		var key model.StreamingServiceType
+		var service streamingservice.StreamingService
+		for k, s := range services {
+			if s.LinkBelongsToService(link) {
+				key = k
+				service = s
+				break
+			}
+		}
+
+		if service == nil {
+			return nil, nil, fmt.Errorf("%w: couldn't find a streaming service for %s", errInvalidChoice, link)
+		}
+
+		if seen[key] {
+			return nil, nil, fmt.Errorf("%w: only one link from each service can be chosen, found more than one from %s", errInvalidChoice, key)
+		}
+
+		seen[key] = true
+		link = service.CleanLink(link)
+
+		storedTyp, dbRes, err := repo.GetByLink(ctx, link)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if storedTyp != model.UnknownType {
+			if storedTyp != typ {
+				return nil, nil, fmt.Errorf("%w: %s is a %s, not a %s", errInvalidChoice, link, storedTyp, typ)
+			}
+
+			stored = append(stored, dbRes.(T))
+			continue
+		}
+
+		logger.Debugf("searching %s\n", key)
+		foundTyp, thing, err := service.GetFromLink(link)
+		if err != nil {
+			if streamingservice.IsNotFound(err) {
+				return nil, nil, fmt.Errorf("%w: could not find anything for %s", errInvalidChoice, link)
+			}
+
+			return nil, nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		if foundTyp == model.UnknownType {
+			return nil, nil, fmt.Errorf("%w: could not find anything for %s", errInvalidChoice, link)
+		}
+
+		if foundTyp != typ {
+			return nil, nil, fmt.Errorf("%w: %s is a %s, not a %s", errInvalidChoice, link, foundTyp, typ)
+		}
+
+		fetched = append(fetched, thing.(T))
+	}
+
+	return stored, fetched, nil
+}
+
+// sharedId returns the ID the stored things all share, or an empty string if nothing has been stored yet.
+// Things which have already been grouped separately can't be chosen together, they'd need to be merged.
+func sharedId[T model.Thing](stored []T, idOf func(T) string) (string, error) {
+	id := ""
+	for _, thing := range stored {
+		if len(id) != 0 && idOf(thing) != id {
+			return "", fmt.Errorf("%w: some of the links have already been grouped with something else", errInvalidChoice)
+		}
+
+		id = idOf(thing)
+	}
+
+	return id, nil
+}
+
+func storeChosenArtists(ctx context.Context, links []string, services streamingservice.StreamingServices, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (*Result[*model.Artist], error) {
+	stored, fetched, err := fetchChosen[*model.Artist](ctx, model.ArtistType, links, services, repo, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := sharedId(stored, func(a *model.Artist) string { return a.ArtistId })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) == 0 {
+		id = uuid.New().String()
+	}
+
+	if len(fetched) != 0 {
+		for _, artist := range fetched {
+			artist.ArtistId = id
+		}
+
+		n, err := repo.AddArtist(ctx, fetched)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.WithField("artist_id", id).Infof("%d new artists added", n)
+	}
+
+	artists, err := repo.GetArtistsById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewResult[*model.Artist](model.ArtistType)
+	res.AddAll(artists)
+	setDisabled(res, serviceProvider)
+	return res, nil
+}
+
+func storeChosenAlbums(ctx context.Context, links []string, services streamingservice.StreamingServices, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (*Result[*model.Album], error) {
+	stored, fetched, err := fetchChosen[*model.Album](ctx, model.AlbumType, links, services, repo, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := sharedId(stored, func(a *model.Album) string { return a.AlbumId })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) == 0 {
+		id = uuid.New().String()
+	}
+
+	if len(fetched) != 0 {
+		for _, album := range fetched {
+			album.AlbumId = id
+		}
+
+		n, err := repo.AddAlbum(ctx, fetched)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.WithField("album_id", id).Infof("%d new albums added", n)
+	}
+
+	albums, err := repo.GetAlbumsById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewResult[*model.Album](model.AlbumType)
+	res.AddAll(albums)
+	setDisabled(res, serviceProvider)
+	return res, nil
+}
+
+// storeChosenTracks stores the tracks under the ISRC they share. Tracks which were matched by name don't always
+// have one, in which case they take the ISRC of the others.
+func storeChosenTracks(ctx context.Context, links []string, services streamingservice.StreamingServices, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (*Result[*model.Track], error) {
+	stored, fetched, err := fetchChosen[*model.Track](ctx, model.TrackType, links, services, repo, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	isrc, err := sharedId(stored, func(t *model.Track) string { return t.Isrc })
+	if err != nil {
+		return nil, err
+	}
+
+	for _, track := range fetched {
+		if len(track.Isrc) == 0 {
+			continue
+		}
+
+		if len(isrc) == 0 {
+			isrc = track.Isrc
+		} else if track.Isrc != isrc {
+			return nil, fmt.Errorf("%w: the tracks have different ISRCs, so they aren't the same recording", errInvalidChoice)
+		}
+	}
+
+	if len(isrc) == 0 {
+		return nil, fmt.Errorf("%w: none of the tracks have an ISRC", errInvalidChoice)
+	}
+
+	if len(fetched) != 0 {
+		for _, track := range fetched {
+			track.Isrc = isrc
+		}
+
+		n, err := repo.AddTracks(ctx, fetched)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.WithField("isrc", isrc).Infof("%d new tracks added", n)
+	}
+
+	tracks, err := repo.GetTracksByIsrc(ctx, isrc)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewResult[*model.Track](model.TrackType)
+	res.AddAll(tracks)
+	setDisabled(res, serviceProvider)
+	return res, nil
+}
+
+// setSearchStatuses records why each group is missing a result from any of the services
+func setSearchStatuses[T model.Thing](results []*Result[T], services streamingservice.StreamingServices, errs map[model.StreamingServiceType]error, serviceProvider streamingservice.ServiceProvider) {
+	for _, res := range results {
+		for key := range services {
+			if res.HasResultFor(key) {
+				continue
+			}
+
+			if err, ok := errs[key]; ok {
+				res.SetError(key, err)
+			} else {
+				res.SetNotFound(key)
+			}
+		}
+
+		setDisabled(res, serviceProvider)
+	}
+}
+
+func artistSearchKey(artist *model.Artist) string {
+	return normalizeSearchText(artist.Name)
+}
+
+func albumSearchKey(album *model.Album) string {
+	return fmt.Sprintf("%s|%s", normalizeSearchText(firstOrEmpty(album.ArtistNames)), normalizeSearchText(album.Name))
+}
+
+func trackSearchKey(track *model.Track) string {
+	return fmt.Sprintf("%s|%s", normalizeSearchText(firstOrEmpty(track.ArtistNames)), normalizeSearchText(track.Name))
+}
+
+// normalizeSearchText strips out the bits of a name which tend to differ between services,
+// e.g. "Surrender (Remastered 2011)" and "Surrender - Remastered" both become "surrender"
+func normalizeSearchText(s string) string {
+	s = searchNoisePattern.ReplaceAllString(strings.ToLower(s), "")
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return -1
+	}, s)
+}
+
+func firstOrEmpty(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	return s[0]
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func search(t *testing.T, f *testFixture, query string, typ model.Type) *httptest.ResponseRecorder {
+	handler := GetSearchHandler(f.provider, f.repo, testLogger().Logger)
+
+	params := url.Values{}
+	params.Set("q", query)
+	if len(typ) > 0 {
+		params.Set("type", string(typ))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/search?"+params.Encode(), nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func chooseSearchResult(t *testing.T, f *testFixture, typ model.Type, links ...string) *httptest.ResponseRecorder {
+	handler := PostSearchHandler(f.provider, f.repo, testLogger().Logger)
+
+	body, err := json.Marshal(&SearchChoiceRequest{Type: typ, Links: links})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/search", bytes.NewReader(body))
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_SearchGroupsTracksAcrossServices(t *testing.T) {
+	f := newTestFixture()
+
+	// Some services don't tell us the ISRC when searching, so those have to be matched up by name
+	deezerTrack := sstesting.Track(model.DeezerStreamingService)
+	deezerTrack.Isrc = ""
+	deezerTrack.Name = "Surrender (Remastered)"
+	f.replaceService(sstesting.NewFakeStreamingService(model.DeezerStreamingService).WithTracks(deezerTrack))
+
+	// A different recording with the same name shouldn't be grouped with the original
+	liveTrack := model.NewTrack("USSM19900001", "Surrender", []string{"Cheap Trick"}, "Budokan", "", model.SpotifyStreamingService, model.DefaultMarket, sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender-live"))
+	f.services[model.SpotifyStreamingService].WithTracks(liveTrack)
+
+	rec := search(t, f, "cheap trick surrender", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []*Result[*model.Track]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, model.TrackType, results[0].Type)
+	assert.Len(t, results[0].Items, 3)
+	for _, track := range results[0].Items {
+		// The Deezer track was only matched by name, so we can't say what its ISRC is
+		if track.Source == model.DeezerStreamingService {
+			assert.Empty(t, track.Isrc)
+		} else {
+			assert.Equal(t, sstesting.Isrc, track.Isrc)
+		}
+	}
+
+	require.Len(t, results[1].Items, 1)
+	assert.Equal(t, liveTrack.Link, results[1].Items[0].Link)
+	assert.Equal(t, StatusNotFound, results[1].Services[model.DeezerStreamingService].Status)
+
+	// Nothing is stored until one of the results is looked up
+	stored, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	stored, err = f.repo.GetTracksByIsrc(context.Background(), liveTrack.Isrc)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func Test_SearchReusesExistingIds(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	existing := sstesting.Album(model.AppleMusicStreamingService)
+	existing.AlbumId = "album-1"
+	_, err := f.repo.AddAlbum(ctx, []*model.Album{existing})
+	require.NoError(t, err)
+
+	rec := search(t, f, "heaven tonight", model.AlbumType)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []*Result[*model.Album]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Items, 3)
+
+	for _, album := range results[0].Items {
+		if album.Source == existing.Source {
+			assert.Equal(t, "album-1", album.AlbumId)
+		} else {
+			assert.Empty(t, album.AlbumId)
+		}
+	}
+
+	stored, err := f.repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func Test_SearchResultsAreStoredOnceLookedUp(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	rec := search(t, f, "cheap trick surrender", model.TrackType)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []*Result[*model.Track]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.NotEmpty(t, results)
+	require.NotEmpty(t, results[0].Items)
+
+	res, found, err := findForLink(ctx, results[0].Items[0].Link, f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Len(t, res.(*Result[*model.Track]).Items, 3)
+
+	stored, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+}
+
+func Test_SearchRecordsFailingServices(t *testing.T) {
+	f := newTestFixture()
+	f.services[model.DeezerStreamingService].WithError(fmt.Errorf("api responded with 500 Internal Server Error"))
+
+	rec := search(t, f, "cheap trick", model.ArtistType)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var results []*Result[*model.Artist]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+
+	assert.Len(t, results[0].Items, 2)
+	assert.Equal(t, StatusFound, results[0].Services[model.SpotifyStreamingService].Status)
+	assert.Equal(t, StatusError, results[0].Services[model.DeezerStreamingService].Status)
+}
+
+func Test_SearchReturnsNothingForUnknownQueries(t *testing.T) {
+	f := newTestFixture()
+
+	rec := search(t, f, "does not exist", model.TrackType)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+func Test_SearchRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		typ   model.Type
+	}{
+		{
+			name:  "missing query",
+			query: " ",
+		},
+		{
+			name:  "unknown type",
+			query: "cheap trick",
+			typ:   "playlist",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := search(t, f, testCase.query, testCase.typ)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
+
+func Test_ChoosingASearchResultStoresItUnderOneId(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	var links []string
+	for _, key := range sstesting.ServiceKeys {
+		links = append(links, sstesting.LinkFor(key, model.AlbumType, "heaven-tonight"))
+	}
+
+	rec := chooseSearchResult(t, f, model.AlbumType, links...)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var res *Result[*model.Album]
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(t, res.Items, 3)
+
+	id := res.Items[0].AlbumId
+	require.NotEmpty(t, id)
+	for _, album := range res.Items {
+		assert.Equal(t, id, album.AlbumId)
+	}
+
+	stored, err := f.repo.GetAlbumsById(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+
+	// Now that it's stored, looking it up doesn't need to go anywhere near the services
+	calls := f.totalCalls()
+	lookup, found, err := findForLink(ctx, links[0], f.provider, f.repo, &singleflight.Group{}, testLogger())
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, id, lookup.(*Result[*model.Album]).Items[0].AlbumId)
+	assert.Equal(t, calls, f.totalCalls())
+}
+
+func Test_ChoosingASearchResultKeepsExistingIds(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	existing := sstesting.Artist(model.AppleMusicStreamingService)
+	existing.ArtistId = "artist-1"
+	_, err := f.repo.AddArtist(ctx, []*model.Artist{existing})
+	require.NoError(t, err)
+
+	rec := chooseSearchResult(t, f, model.ArtistType,
+		existing.Link,
+		sstesting.LinkFor(model.SpotifyStreamingService, model.ArtistType, "cheap-trick"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	stored, err := f.repo.GetArtistsById(ctx, "artist-1")
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+
+	// The Apple Music artist was already stored, there was no need to fetch it again
+	assert.Equal(t, 0, f.services[model.AppleMusicStreamingService].Calls())
+}
+
+func Test_ChoosingASearchResultGivesTracksTheSameIsrc(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	// Deezer's track was matched by name, so it's only grouped with the others once someone says it's the same
+	deezerTrack := sstesting.Track(model.DeezerStreamingService)
+	deezerTrack.Isrc = ""
+	f.replaceService(sstesting.NewFakeStreamingService(model.DeezerStreamingService).WithTracks(deezerTrack))
+
+	rec := chooseSearchResult(t, f, model.TrackType,
+		sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender"),
+		deezerTrack.Link)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	stored, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
+
+func Test_ChoosingASearchResultRejectsBadChoices(t *testing.T) {
+	spotifyTrack := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+	deezerTrack := sstesting.LinkFor(model.DeezerStreamingService, model.TrackType, "surrender")
+
+	testCases := []struct {
+		name  string
+		typ   model.Type
+		links []string
+	}{
+		{
+			name: "unknown type",
+			typ:  "playlist",
+			links: []string{
+				spotifyTrack,
+			},
+		},
+		{
+			name: "no links",
+			typ:  model.TrackType,
+		},
+		{
+			name: "unknown service",
+			typ:  model.TrackType,
+			links: []string{
+				"https://example.com/track/surrender",
+			},
+		},
+		{
+			name: "more than one link from a service",
+			typ:  model.TrackType,
+			links: []string{
+				spotifyTrack,
+				sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender-live"),
+			},
+		},
+		{
+			name: "wrong type",
+			typ:  model.AlbumType,
+			links: []string{
+				spotifyTrack,
+				deezerTrack,
+			},
+		},
+		{
+			name: "unknown link",
+			typ:  model.TrackType,
+			links: []string{
+				sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "does-not-exist"),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := chooseSearchResult(t, f, testCase.typ, testCase.links...)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			typ, _, err := f.repo.GetByLink(context.Background(), spotifyTrack)
+			require.NoError(t, err)
+			assert.Equal(t, model.UnknownType, typ)
+		})
+	}
+}
+
+func Test_ChoosingASearchResultRejectsThingsWhichAreAlreadyGrouped(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	spotifyArtist := sstesting.Artist(model.SpotifyStreamingService)
+	spotifyArtist.ArtistId = "artist-1"
+	deezerArtist := sstesting.Artist(model.DeezerStreamingService)
+	deezerArtist.ArtistId = "artist-2"
+	_, err := f.repo.AddArtist(ctx, []*model.Artist{spotifyArtist, deezerArtist})
+	require.NoError(t, err)
+
+	rec := chooseSearchResult(t, f, model.ArtistType, spotifyArtist.Link, deezerArtist.Link)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
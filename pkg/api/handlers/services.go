@@ -1,21 +1,35 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"github.com/yukitsune/maestro/pkg/api/responses"
-	"io/ioutil"
+	"fmt"
+	"io/fs"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
-	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/api/responses"
 	"github.com/yukitsune/maestro/pkg/log"
 	"github.com/yukitsune/maestro/pkg/model"
 	"github.com/yukitsune/maestro/pkg/streamingservice"
 )
 
+const (
+	logoVariantLight = "light"
+	logoVariantDark  = "dark"
+
+	// Logos hardly ever change, and when they do the ETag will catch it
+	logoCacheMaxAge = 7 * 24 * time.Hour
+)
+
 func GetListServicesHandler(serviceProvider streamingservice.ServiceProvider) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var services []model.StreamingService
@@ -24,6 +38,7 @@ func GetListServicesHandler(serviceProvider streamingservice.ServiceProvider) ht
 				Key:     cfg.Type(),
 				Name:    cfg.Name(),
 				Enabled: cfg.Enabled(),
+				State:   serviceProvider.GetCircuitState(cfg.Type()),
 			}
 
 			services = append(services, sr)
@@ -38,7 +53,12 @@ func GetListServicesHandler(serviceProvider streamingservice.ServiceProvider) ht
 	}
 }
 
-func GetServiceLogoHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, logger *logrus.Logger) http.HandlerFunc {
+// GetServiceLogoHandler serves a streaming service's logo. A light or dark variant can be asked for with ?variant=,
+// and an SVG (or PNG) version with ?format=.
+// Logos are loaded once and then served from memory.
+func GetServiceLogoHandler(logos fs.FS, serviceProvider streamingservice.ServiceProvider, logger *logrus.Logger) http.HandlerFunc {
+	loaded := &logoCache{fs: logos, logos: make(map[string]*logo), modTime: time.Now().UTC().Truncate(time.Second)}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		reqLogger, err := log.ForRequest(logger, r)
@@ -60,16 +80,30 @@ func GetServiceLogoHandler(apiConfig config.API, serviceProvider streamingservic
 			return
 		}
 
-		reqLogger.Debugf("logo file name: %s", cfg.LogoFileName())
+		q := r.URL.Query()
+		variant := q.Get("variant")
+		switch variant {
+		case "", logoVariantLight, logoVariantDark:
+		default:
+			responses.BadRequestf(w, "unknown variant %s, expected one of %s or %s", variant, logoVariantLight, logoVariantDark)
+			return
+		}
+
+		fileName := cfg.LogoFileName()
+		if format := q.Get("format"); len(format) > 0 {
+			if format != "png" && format != "svg" {
+				responses.BadRequestf(w, "unknown format %s, expected one of png or svg", format)
+				return
+			}
+
+			fileName = strings.TrimSuffix(fileName, path.Ext(fileName)) + "." + format
+		}
 
-		logoFilePath := filepath.Join(apiConfig.AssetsDirectory(), "logos", cfg.LogoFileName())
-		reqLogger.Debugf("logo path: %s", logoFilePath)
+		reqLogger.Debugf("logo file name: %s, variant: %s", fileName, variant)
 
-		// Ensure the file exists
-		_, err = os.Stat(logoFilePath)
+		l, err := loaded.find(fileName, variant)
 		if err != nil {
-			exists := !errors.Is(err, os.ErrNotExist)
-			if !exists {
+			if errors.Is(err, fs.ErrNotExist) {
 				reqLogger.Debugln("logo does not exist")
 				responses.NotFoundf(w, "couldn't find logo for %s", serviceName)
 				return
@@ -79,12 +113,82 @@ func GetServiceLogoHandler(apiConfig config.API, serviceProvider streamingservic
 			return
 		}
 
-		logo, err := ioutil.ReadFile(logoFilePath)
-		if err != nil {
-			responses.Error(w, err)
-			return
+		w.Header().Set("Content-Type", l.contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(logoCacheMaxAge.Seconds())))
+		w.Header().Set("ETag", l.etag)
+
+		// Takes care of If-None-Match and If-Modified-Since for us
+		http.ServeContent(w, r, "", l.modTime, bytes.NewReader(l.body))
+	}
+}
+
+// logo is a logo which has been loaded into memory, ready to be served
+type logo struct {
+	body        []byte
+	contentType string
+	etag        string
+	modTime     time.Time
+}
+
+// logoCache remembers the logos which have been asked for, so that they're only read once
+type logoCache struct {
+	mu    sync.Mutex
+	fs    fs.FS
+	logos map[string]*logo
+
+	// modTime is used for logos which don't have one, i.e. the ones built into the binary
+	modTime time.Time
+}
+
+// find returns the variant of the logo, or the logo itself if there's no such variant
+func (c *logoCache) find(fileName string, variant string) (*logo, error) {
+	if len(variant) > 0 {
+		ext := path.Ext(fileName)
+		l, err := c.load(strings.TrimSuffix(fileName, ext) + "-" + variant + ext)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return l, err
 		}
+	}
+
+	return c.load(fileName)
+}
+
+func (c *logoCache) load(fileName string) (*logo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.logos[fileName]; ok {
+		return l, nil
+	}
+
+	// Logo file names come from our own config, but they're checked anyway so that they can't go anywhere else
+	if !fs.ValidPath(fileName) || strings.Contains(fileName, "/") {
+		return nil, fs.ErrNotExist
+	}
+
+	body, err := fs.ReadFile(c.fs, fileName)
+	if err != nil {
+		return nil, err
+	}
 
-		responses.Image(w, logo)
+	modTime := c.modTime
+	if info, err := fs.Stat(c.fs, fileName); err == nil && !info.ModTime().IsZero() {
+		modTime = info.ModTime()
 	}
+
+	contentType := mime.TypeByExtension(path.Ext(fileName))
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(body)
+	}
+
+	hash := sha256.Sum256(body)
+	l := &logo{
+		body:        body,
+		contentType: contentType,
+		etag:        fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])),
+		modTime:     modTime,
+	}
+
+	c.logos[fileName] = l
+	return l, nil
 }
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+)
+
+var testLogoModTime = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func testLogos() fstest.MapFS {
+	return fstest.MapFS{
+		"spotify.png":      {Data: []byte("spotify"), ModTime: testLogoModTime},
+		"spotify-dark.png": {Data: []byte("spotify dark"), ModTime: testLogoModTime},
+		"spotify.svg":      {Data: []byte("<svg></svg>"), ModTime: testLogoModTime},
+		"deezer.png":       {Data: []byte("deezer"), ModTime: testLogoModTime},
+	}
+}
+
+func getServiceLogo(handler http.HandlerFunc, serviceName string, query string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/services/"+serviceName+"/logo"+query, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+	req = mux.SetURLVars(req, map[string]string{"serviceName": serviceName})
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_ServiceLogo(t *testing.T) {
+	testCases := []struct {
+		name        string
+		service     string
+		query       string
+		expect      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "logo",
+			service:     "spotify",
+			expect:      http.StatusOK,
+			contentType: "image/png",
+			body:        "spotify",
+		},
+		{
+			name:        "dark variant",
+			service:     "spotify",
+			query:       "?variant=dark",
+			expect:      http.StatusOK,
+			contentType: "image/png",
+			body:        "spotify dark",
+		},
+		{
+			name:        "missing variant falls back to the logo",
+			service:     "deezer",
+			query:       "?variant=light",
+			expect:      http.StatusOK,
+			contentType: "image/png",
+			body:        "deezer",
+		},
+		{
+			name:        "svg",
+			service:     "spotify",
+			query:       "?format=svg",
+			expect:      http.StatusOK,
+			contentType: "image/svg+xml",
+			body:        "<svg></svg>",
+		},
+		{
+			name:    "missing svg",
+			service: "deezer",
+			query:   "?format=svg",
+			expect:  http.StatusNotFound,
+		},
+		{
+			name:    "unknown variant",
+			service: "spotify",
+			query:   "?variant=../../etc/passwd",
+			expect:  http.StatusBadRequest,
+		},
+		{
+			name:    "unknown format",
+			service: "spotify",
+			query:   "?format=gif",
+			expect:  http.StatusBadRequest,
+		},
+		{
+			name:    "missing logo",
+			service: "apple_music",
+			expect:  http.StatusNotFound,
+		},
+		{
+			name:    "unknown service",
+			service: "napster",
+			expect:  http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			handler := GetServiceLogoHandler(testLogos(), f.provider, testLogger().Logger)
+
+			rec := getServiceLogo(handler, testCase.service, testCase.query, nil)
+			require.Equal(t, testCase.expect, rec.Code)
+
+			if testCase.expect == http.StatusOK {
+				assert.Equal(t, testCase.contentType, rec.Header().Get("Content-Type"))
+				assert.Equal(t, testCase.body, rec.Body.String())
+				assert.Equal(t, "public, max-age=604800", rec.Header().Get("Cache-Control"))
+				assert.Equal(t, testLogoModTime.Format(http.TimeFormat), rec.Header().Get("Last-Modified"))
+				assert.NotEmpty(t, rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func Test_ServiceLogoIsNotSentAgainWhenUnchanged(t *testing.T) {
+	f := newTestFixture()
+	logos := testLogos()
+	handler := GetServiceLogoHandler(logos, f.provider, testLogger().Logger)
+
+	rec := getServiceLogo(handler, "spotify", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	etag := rec.Header().Get("ETag")
+	rec = getServiceLogo(handler, "spotify", "", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = getServiceLogo(handler, "spotify", "", http.Header{"If-Modified-Since": {testLogoModTime.Format(http.TimeFormat)}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Logos are only read once
+	delete(logos, "spotify.png")
+	rec = getServiceLogo(handler, "spotify", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func Test_BuiltInLogosCanBeOverridden(t *testing.T) {
+	f := newTestFixture()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "spotify.png"), []byte("override"), 0o644))
+
+	handler := GetServiceLogoHandler(maestro.Logos(dir), f.provider, testLogger().Logger)
+
+	rec := getServiceLogo(handler, "spotify", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "override", rec.Body.String())
+
+	// Built in
+	rec = getServiceLogo(handler, "deezer", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+}
+
+func Test_BuiltInLogos(t *testing.T) {
+	testCases := []struct {
+		name        string
+		service     string
+		query       string
+		contentType string
+
+		// The variant should be its own logo rather than the regular one, which is found with this query
+		differsFrom string
+	}{
+		{name: "apple music", service: "apple_music", contentType: "image/png"},
+		{name: "apple music svg", service: "apple_music", query: "?format=svg", contentType: "image/svg+xml"},
+		{name: "deezer dark", service: "deezer", query: "?variant=dark", contentType: "image/png", differsFrom: ""},
+		{name: "deezer dark svg", service: "deezer", query: "?variant=dark&format=svg", contentType: "image/svg+xml", differsFrom: "?format=svg"},
+		{name: "spotify svg", service: "spotify", query: "?variant=light&format=svg", contentType: "image/svg+xml"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+			handler := GetServiceLogoHandler(maestro.Logos(""), f.provider, testLogger().Logger)
+
+			rec := getServiceLogo(handler, testCase.service, testCase.query, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, testCase.contentType, rec.Header().Get("Content-Type"))
+			assert.NotEmpty(t, rec.Body.Bytes())
+
+			if strings.Contains(testCase.query, "variant=dark") {
+				regular := getServiceLogo(handler, testCase.service, testCase.differsFrom, nil)
+				require.Equal(t, http.StatusOK, regular.Code)
+				assert.NotEqual(t, regular.Body.Bytes(), rec.Body.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
+)
+
+//go:embed templates/share.gohtml
+var shareTemplateSource string
+
+//go:embed templates/embed.gohtml
+var embedTemplateSource string
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var (
+	shareTemplate = template.Must(template.New("share").Funcs(templateFuncs).Parse(shareTemplateSource))
+	embedTemplate = template.Must(template.New("embed").Funcs(templateFuncs).Parse(embedTemplateSource))
+)
+
+// sharePage is what's shown when someone opens (or a chat app previews) a link to something
+type sharePage struct {
+	Type model.Type
+	Id   string
+
+	Name        string
+	ArtistNames []string
+	AlbumName   string
+
+	Title         string
+	Description   string
+	ImageLink     string
+	OpenGraphType string
+
+	PageLink   string
+	EmbedLink  string
+	OEmbedLink string
+
+	Buttons []shareButton
+}
+
+// shareButton links to the thing on one of the streaming services
+type shareButton struct {
+	Service  model.StreamingServiceType
+	Name     string
+	Link     string
+	LogoLink string
+}
+
+func GetSharePageHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return pageHandler(shareTemplate, apiConfig, serviceProvider, repo, group, logger)
+}
+
+// GetEmbedHandler serves the widget which is shown in an iframe when something is embedded via oEmbed
+func GetEmbedHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return pageHandler(embedTemplate, apiConfig, serviceProvider, repo, group, logger)
+}
+
+// pageHandler renders the given template with the sharePage for the type and ID in the route
+func pageHandler(tmpl *template.Template, apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		vars := mux.Vars(r)
+		typ := model.Type(vars["type"])
+		id, ok := vars["id"]
+		if !ok {
+			responses.BadRequest(w, "missing parameter \"id\"")
+			return
+		}
+
+		if !isShareable(typ) {
+			responses.BadRequestf(w, "unknown type %s", typ)
+			return
+		}
+
+		page, err := findSharePage(r.Context(), typ, id, serviceProvider, repo, group, reqLogger)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if page == nil {
+			responses.NotFoundf(w, "could not find any %ss with ID %s", typ, id)
+			return
+		}
+
+		page.setLinks(apiConfig.PublicURL())
+		responses.Page(w, tmpl, page, http.StatusOK)
+	}
+}
+
+func isShareable(typ model.Type) bool {
+	return typ == model.ArtistType || typ == model.AlbumType || typ == model.TrackType
+}
+
+// findSharePage looks up the thing with the given ID the same way /artist/{id}, /album/{id}, and /track/{isrc} do.
+// Returns nil if there's nothing to share.
+func findSharePage(ctx context.Context, typ model.Type, id string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry) (*sharePage, error) {
+	// Tracks are looked up the same way as /track/{isrc}, which can find them on other services too
+	if typ == model.TrackType {
+		res, err := findForIsrc(ctx, id, serviceProvider, repo, group, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return sharePageFor(res, serviceProvider), nil
+	}
+
+	if !isShareable(typ) {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	return storedSharePage(ctx, typ, id, serviceProvider, repo)
+}
+
+// storedSharePage describes a thing using only what's in the repository, without asking the streaming services
+func storedSharePage(ctx context.Context, typ model.Type, id string, serviceProvider streamingservice.ServiceProvider, repo db.Repository) (*sharePage, error) {
+	switch typ {
+	case model.ArtistType:
+		artists, err := repo.GetArtistsById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		res := NewResult[*model.Artist](model.ArtistType)
+		res.AddAll(artists)
+		return sharePageFor(res, serviceProvider), nil
+
+	case model.AlbumType:
+		albums, err := repo.GetAlbumsById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		res := NewResult[*model.Album](model.AlbumType)
+		res.AddAll(albums)
+		return sharePageFor(res, serviceProvider), nil
+
+	case model.TrackType:
+		tracks, err := repo.GetTracksByIsrc(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		res := NewResult[*model.Track](model.TrackType)
+		res.AddAll(tracks)
+		return sharePageFor(res, serviceProvider), nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// sharePageFor describes the result of a lookup, returns nil if nothing was found
+func sharePageFor(res any, serviceProvider streamingservice.ServiceProvider) *sharePage {
+	switch res := res.(type) {
+	case *Result[*model.Artist]:
+		return newSharePage(res, serviceProvider, func(artist *model.Artist) *sharePage {
+			return &sharePage{
+				Type:          model.ArtistType,
+				Id:            artist.ArtistId,
+				Name:          artist.Name,
+				Title:         artist.Name,
+				ImageLink:     artist.ArtworkLink,
+				OpenGraphType: "profile",
+			}
+		})
+
+	case *Result[*model.Album]:
+		return newSharePage(res, serviceProvider, func(album *model.Album) *sharePage {
+			return &sharePage{
+				Type:          model.AlbumType,
+				Id:            album.AlbumId,
+				Name:          album.Name,
+				ArtistNames:   album.ArtistNames,
+				Title:         byArtists(album.Name, album.ArtistNames),
+				ImageLink:     album.ArtworkLink,
+				OpenGraphType: "music.album",
+			}
+		})
+
+	case *Result[*model.Track]:
+		return newSharePage(res, serviceProvider, func(track *model.Track) *sharePage {
+			return &sharePage{
+				Type:          model.TrackType,
+				Id:            track.Isrc,
+				Name:          track.Name,
+				ArtistNames:   track.ArtistNames,
+				AlbumName:     track.AlbumName,
+				Title:         byArtists(track.Name, track.ArtistNames),
+				ImageLink:     track.ArtworkLink,
+				OpenGraphType: "music.song",
+			}
+		})
+	}
+
+	return nil
+}
+
+// setLinks fills in the links back to the API, which depend on where it can be reached from
+func (p *sharePage) setLinks(publicURL string) {
+	p.PageLink = sharePageLink(publicURL, p.Type, p.Id)
+	p.EmbedLink = fmt.Sprintf("%s/embed/%s/%s", publicURL, p.Type, url.PathEscape(p.Id))
+	p.OEmbedLink = fmt.Sprintf("%s/oembed?%s", publicURL, url.Values{"url": {p.PageLink}, "format": {"json"}}.Encode())
+
+	for i, button := range p.Buttons {
+		p.Buttons[i].LogoLink = fmt.Sprintf("%s/v1/services/%s/logo", publicURL, button.Service)
+	}
+}
+
+// newSharePage describes the result using the first thing which has artwork, and adds a button for each service
+func newSharePage[T model.Thing](res *Result[T], serviceProvider streamingservice.ServiceProvider, describe func(T) *sharePage) *sharePage {
+	if !res.HasResults() {
+		return nil
+	}
+
+	configs := serviceProvider.ListConfigs()
+	serviceName := func(key model.StreamingServiceType) string {
+		if cfg, ok := configs[key]; ok {
+			return cfg.Name()
+		}
+
+		return key.String()
+	}
+
+	items := make([]T, len(res.Items))
+	copy(items, res.Items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return serviceName(items[i].GetSource()) < serviceName(items[j].GetSource())
+	})
+
+	var page *sharePage
+	for _, item := range items {
+		if described := describe(item); page == nil || (len(page.ImageLink) == 0 && len(described.ImageLink) > 0) {
+			page = described
+		}
+	}
+
+	var names []string
+	for _, item := range items {
+		name := serviceName(item.GetSource())
+		names = append(names, name)
+		page.Buttons = append(page.Buttons, shareButton{
+			Service: item.GetSource(),
+			Name:    name,
+			Link:    item.GetLink(),
+		})
+	}
+
+	page.Description = fmt.Sprintf("Listen to %s on %s", page.Title, joinNames(names))
+	return page
+}
+
+func sharePageLink(publicURL string, typ model.Type, id string) string {
+	return fmt.Sprintf("%s/share/%s/%s", publicURL, typ, url.PathEscape(id))
+}
+
+func byArtists(name string, artistNames []string) string {
+	if len(artistNames) == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("%s by %s", name, joinNames(artistNames))
+}
+
+// joinNames lists names the way a person would, e.g. "Apple Music, Deezer and Spotify"
+func joinNames(names []string) string {
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+
+	return fmt.Sprintf("%s and %s", strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func testApiConfig() config.API {
+	v := viper.New()
+	v.Set("api.public_url", "https://maestro.test/")
+	return config.NewApiViperConfig(v)
+}
+
+func getSharePage(t *testing.T, f *testFixture, typ string, id string) *httptest.ResponseRecorder {
+	handler := GetSharePageHandler(testApiConfig(), f.provider, f.repo, &singleflight.Group{}, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/share/"+typ+"/"+id, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+	req = mux.SetURLVars(req, map[string]string{"type": typ, "id": id})
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_SharePageForTrack(t *testing.T) {
+	f := newTestFixture()
+
+	// Only one of the services has artwork, that's the one which should be used for the preview
+	track := sstesting.Track(model.SpotifyStreamingService)
+	track.ArtworkLink = "https://images.test/surrender.jpg"
+	f.services[model.SpotifyStreamingService].Remove(track.Link).WithTracks(track)
+
+	rec := getSharePage(t, f, "track", sstesting.Isrc)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `<meta property="og:title" content="Surrender by Cheap Trick">`)
+	assert.Contains(t, body, `<meta property="og:type" content="music.song">`)
+	assert.Contains(t, body, `<meta property="og:image" content="https://images.test/surrender.jpg">`)
+	assert.Contains(t, body, `<meta property="og:url" content="https://maestro.test/share/track/USUM71703861">`)
+	assert.Contains(t, body, `<meta property="og:description" content="Listen to Surrender by Cheap Trick on apple_music, deezer and spotify">`)
+	assert.Contains(t, body, `<meta property="music:musician" content="Cheap Trick">`)
+	assert.Contains(t, body, `<meta name="twitter:image" content="https://images.test/surrender.jpg">`)
+
+	for _, key := range sstesting.ServiceKeys {
+		assert.Contains(t, body, `href="`+sstesting.Track(key).Link+`"`)
+		assert.Contains(t, body, `src="https://maestro.test/v1/services/`+key.String()+`/logo"`)
+	}
+}
+
+func Test_SharePageForAlbumEscapesNames(t *testing.T) {
+	f := newTestFixture()
+
+	album := sstesting.Album(model.DeezerStreamingService)
+	album.AlbumId = "album-1"
+	album.Name = `<script>alert("hi")</script>`
+	_, err := f.repo.AddAlbum(context.Background(), []*model.Album{album})
+	require.NoError(t, err)
+
+	rec := getSharePage(t, f, "album", "album-1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, `<meta property="og:type" content="music.album">`)
+	assert.NotContains(t, body, `og:image`)
+}
+
+func Test_SharePageRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name   string
+		typ    string
+		id     string
+		expect int
+	}{
+		{
+			name:   "unknown type",
+			typ:    "playlist",
+			id:     "123",
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown artist",
+			typ:    "artist",
+			id:     "does-not-exist",
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := getSharePage(t, f, testCase.typ, testCase.id)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
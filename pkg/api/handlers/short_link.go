@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	shortCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	shortCodeLength   = 7
+
+	// 62^7 codes means collisions should be rare, if we keep hitting them something else is wrong
+	maxShortCodeAttempts = 5
+)
+
+type ShortLinkRequest struct {
+	Type model.Type
+	Id   string
+}
+
+type ShortLinkResponse struct {
+	Code string
+	Link string
+	Type model.Type
+	Id   string
+}
+
+func PostShortLinkHandler(apiConfig config.API, repo db.Repository, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		var req *ShortLinkRequest
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req)
+		if err != nil || req == nil {
+			responses.BadRequest(w, "request body must be a JSON object with a type and an ID")
+			return
+		}
+
+		if !isShareable(req.Type) {
+			responses.BadRequestf(w, "unknown type %s", req.Type)
+			return
+		}
+
+		if len(strings.TrimSpace(req.Id)) == 0 {
+			responses.BadRequest(w, "missing ID")
+			return
+		}
+
+		exists, err := thingExists(r.Context(), repo, req.Type, req.Id)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if !exists {
+			responses.NotFoundf(w, "could not find any %ss with ID %s", req.Type, req.Id)
+			return
+		}
+
+		// Concurrent requests for the same thing should end up with the same code
+		key := fmt.Sprintf("short_link:%s:%s", req.Type, req.Id)
+		v, err, _ := group.Do(key, func() (interface{}, error) {
+			return findOrCreateShortLink(detach(r.Context()), repo, req.Type, req.Id, reqLogger)
+		})
+
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		created := v.(*createdShortLink)
+		status := http.StatusOK
+		if created.isNew {
+			status = http.StatusCreated
+		}
+
+		responses.Response(w, &ShortLinkResponse{
+			Code: created.link.Code,
+			Link: shortLinkURL(apiConfig.PublicURL(), created.link.Code),
+			Type: created.link.Type,
+			Id:   created.link.Id,
+		}, status)
+	}
+}
+
+// GetShortLinkHandler sends people to the share page for whatever the short link points to
+func GetShortLinkHandler(apiConfig config.API, repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		code, ok := vars["code"]
+		if !ok {
+			responses.BadRequest(w, "missing parameter \"code\"")
+			return
+		}
+
+		// No point asking the database about something which could never be a code
+		if !isShortCode(code) {
+			responses.NotFoundf(w, "could not find short link %s", code)
+			return
+		}
+
+		link, err := repo.GetShortLink(r.Context(), code)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if link == nil {
+			responses.NotFoundf(w, "could not find short link %s", code)
+			return
+		}
+
+		http.Redirect(w, r, sharePageLink(apiConfig.PublicURL(), link.Type, link.Id), http.StatusFound)
+	}
+}
+
+type createdShortLink struct {
+	link  *model.ShortLink
+	isNew bool
+}
+
+// findOrCreateShortLink returns the existing short link for the thing, or creates a new one with an unused code
+func findOrCreateShortLink(ctx context.Context, repo db.Repository, typ model.Type, id string, logger *logrus.Entry) (*createdShortLink, error) {
+	existing, err := repo.GetShortLinkFor(ctx, typ, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return &createdShortLink{existing, false}, nil
+	}
+
+	for attempt := 0; attempt < maxShortCodeAttempts; attempt++ {
+		code, err := newShortCode()
+		if err != nil {
+			return nil, err
+		}
+
+		link := model.NewShortLink(code, typ, id)
+		err = repo.AddShortLink(ctx, link)
+		if err == nil {
+			logger.Infof("created short link %s for %s %s", code, typ, id)
+			return &createdShortLink{link, true}, nil
+		}
+
+		// Someone else has just made one for the same thing, so we can use theirs
+		if errors.Is(err, db.ErrShortLinkExists) {
+			return findExistingShortLink(ctx, repo, typ, id)
+		}
+
+		if !errors.Is(err, db.ErrShortCodeTaken) {
+			return nil, err
+		}
+
+		logger.Warnf("short code %s is already taken", code)
+	}
+
+	return nil, fmt.Errorf("couldn't find an unused short code after %d attempts", maxShortCodeAttempts)
+}
+
+func findExistingShortLink(ctx context.Context, repo db.Repository, typ model.Type, id string) (*createdShortLink, error) {
+	existing, err := repo.GetShortLinkFor(ctx, typ, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return nil, fmt.Errorf("couldn't find the existing short link for %s %s", typ, id)
+	}
+
+	return &createdShortLink{existing, false}, nil
+}
+
+// thingExists returns true if we know about the artist, album, or track with the given ID
+func thingExists(ctx context.Context, repo db.Repository, typ model.Type, id string) (bool, error) {
+	things, err := findThings(ctx, repo, typ, id)
+	return len(things) > 0, err
+}
+
+func newShortCode() (string, error) {
+	max := big.NewInt(int64(len(shortCodeAlphabet)))
+
+	var sb strings.Builder
+	for i := 0; i < shortCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteByte(shortCodeAlphabet[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+func isShortCode(code string) bool {
+	if len(code) != shortCodeLength {
+		return false
+	}
+
+	for _, c := range code {
+		if !strings.ContainsRune(shortCodeAlphabet, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func shortLinkURL(publicURL string, code string) string {
+	return fmt.Sprintf("%s/s/%s", publicURL, code)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func postShortLink(t *testing.T, repo db.Repository, body string) *httptest.ResponseRecorder {
+	handler := PostShortLinkHandler(testApiConfig(), repo, &singleflight.Group{}, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/s", strings.NewReader(body))
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func getShortLink(t *testing.T, repo db.Repository, code string) *httptest.ResponseRecorder {
+	handler := GetShortLinkHandler(testApiConfig(), repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/s/"+code, nil)
+	req = mux.SetURLVars(req, map[string]string{"code": code})
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_ShortLinkRedirectsToSharePage(t *testing.T) {
+	f := newTestFixture()
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{sstesting.Track(model.SpotifyStreamingService)})
+	require.NoError(t, err)
+
+	rec := postShortLink(t, f.repo, `{"Type": "track", "Id": "`+sstesting.Isrc+`"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var res *ShortLinkResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.True(t, isShortCode(res.Code))
+	assert.Equal(t, "https://maestro.test/s/"+res.Code, res.Link)
+	assert.Equal(t, model.TrackType, res.Type)
+	assert.Equal(t, sstesting.Isrc, res.Id)
+
+	rec = getShortLink(t, f.repo, res.Code)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://maestro.test/share/track/"+sstesting.Isrc, rec.Header().Get("Location"))
+}
+
+func Test_ShortLinkIsStable(t *testing.T) {
+	f := newTestFixture()
+	_, err := f.repo.AddTracks(context.Background(), []*model.Track{sstesting.Track(model.SpotifyStreamingService)})
+	require.NoError(t, err)
+
+	rec := postShortLink(t, f.repo, `{"Type": "track", "Id": "`+sstesting.Isrc+`"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var first *ShortLinkResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&first))
+
+	// Finding the track on another service shouldn't change the code
+	_, err = f.repo.AddTracks(context.Background(), []*model.Track{sstesting.Track(model.DeezerStreamingService)})
+	require.NoError(t, err)
+
+	rec = postShortLink(t, f.repo, `{"Type": "track", "Id": "`+sstesting.Isrc+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var second *ShortLinkResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&second))
+	assert.Equal(t, first.Code, second.Code)
+}
+
+func Test_ShortLinkRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name   string
+		body   string
+		expect int
+	}{
+		{
+			name:   "not JSON",
+			body:   `track/USUM71703861`,
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown type",
+			body:   `{"Type": "playlist", "Id": "123"}`,
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "missing ID",
+			body:   `{"Type": "track"}`,
+			expect: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown track",
+			body:   `{"Type": "track", "Id": "does-not-exist"}`,
+			expect: http.StatusNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newTestFixture()
+
+			rec := postShortLink(t, f.repo, testCase.body)
+			assert.Equal(t, testCase.expect, rec.Code)
+			assert.Equal(t, 0, f.totalCalls())
+		})
+	}
+}
+
+func Test_UnknownShortLink(t *testing.T) {
+	for _, code := range []string{"abcdefg", "not-a-code"} {
+		rec := getShortLink(t, db.NewInMemoryRepository(), code)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+// takenCodesRepository pretends the first few codes it's given are already taken
+type takenCodesRepository struct {
+	db.Repository
+	taken int
+}
+
+func (r *takenCodesRepository) AddShortLink(ctx context.Context, link *model.ShortLink) error {
+	if r.taken > 0 {
+		r.taken--
+		return db.ErrShortCodeTaken
+	}
+
+	return r.Repository.AddShortLink(ctx, link)
+}
+
+func Test_ShortLinkRetriesTakenCodes(t *testing.T) {
+	repo := &takenCodesRepository{db.NewInMemoryRepository(), 2}
+
+	created, err := findOrCreateShortLink(context.Background(), repo, model.TrackType, sstesting.Isrc, testLogger())
+	require.NoError(t, err)
+	assert.True(t, created.isNew)
+
+	found, err := repo.GetShortLinkFor(context.Background(), model.TrackType, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Equal(t, created.link.Code, found.Code)
+
+	repo = &takenCodesRepository{db.NewInMemoryRepository(), maxShortCodeAttempts}
+	_, err = findOrCreateShortLink(context.Background(), repo, model.TrackType, sstesting.Isrc, testLogger())
+	assert.Error(t, err)
+}
+
+// racingRepository creates a short link for the same thing just before ours is added, like another instance would
+type racingRepository struct {
+	db.Repository
+	code string
+}
+
+func (r *racingRepository) AddShortLink(ctx context.Context, link *model.ShortLink) error {
+	if len(r.code) > 0 {
+		err := r.Repository.AddShortLink(ctx, model.NewShortLink(r.code, link.Type, link.Id))
+		if err != nil {
+			return err
+		}
+
+		r.code = ""
+	}
+
+	return r.Repository.AddShortLink(ctx, link)
+}
+
+func Test_ShortLinkUsesExistingCodeWhenItLosesARace(t *testing.T) {
+	repo := &racingRepository{db.NewInMemoryRepository(), "AAAAAAA"}
+
+	created, err := findOrCreateShortLink(context.Background(), repo, model.TrackType, sstesting.Isrc, testLogger())
+	require.NoError(t, err)
+	assert.False(t, created.isNew)
+	assert.Equal(t, "AAAAAAA", created.link.Code)
+
+	again, err := findOrCreateShortLink(context.Background(), repo, model.TrackType, sstesting.Isrc, testLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "AAAAAAA", again.link.Code)
+}
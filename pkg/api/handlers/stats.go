@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yukitsune/maestro/pkg/analytics"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+const (
+	defaultStatsDays  = 7
+	maxStatsDays      = 90
+	defaultStatsLimit = 10
+	maxStatsLimit     = 100
+)
+
+// GetTopStatsHandler lists the things which have been clicked through to (or looked up) the most over the last few days
+func GetTopStatsHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		kind := model.ClickEvent
+		if k := q.Get("kind"); len(k) > 0 {
+			kind = model.AnalyticsEventKind(k)
+		}
+
+		if kind != model.ClickEvent && kind != model.ResolveEvent {
+			responses.BadRequestf(w, "unknown kind %s, expected one of click or resolve", kind)
+			return
+		}
+
+		days, err := statsParameter(q.Get("days"), defaultStatsDays, maxStatsDays)
+		if err != nil {
+			responses.BadRequestf(w, "invalid days: %s", err.Error())
+			return
+		}
+
+		limit, err := statsParameter(q.Get("limit"), defaultStatsLimit, maxStatsLimit)
+		if err != nil {
+			responses.BadRequestf(w, "invalid limit: %s", err.Error())
+			return
+		}
+
+		stats, err := repo.GetTopThings(r.Context(), kind, statsSince(days), limit)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if stats == nil {
+			stats = []*model.ThingStats{}
+		}
+
+		responses.Response(w, stats, http.StatusOK)
+	}
+}
+
+// GetServiceStatsHandler shows how often each service is clicked through to, and how often links from it are looked up
+func GetServiceStatsHandler(repo db.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days, err := statsParameter(r.URL.Query().Get("days"), defaultStatsDays, maxStatsDays)
+		if err != nil {
+			responses.BadRequestf(w, "invalid days: %s", err.Error())
+			return
+		}
+
+		stats, err := repo.GetServiceStats(r.Context(), statsSince(days))
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		if stats == nil {
+			stats = []*model.ServiceStats{}
+		}
+
+		responses.Response(w, stats, http.StatusOK)
+	}
+}
+
+// statsParameter returns the default if nothing was given, and caps whatever was given at the maximum
+func statsParameter(value string, def int, max int) (int, error) {
+	if len(value) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("must be greater than 0")
+	}
+
+	if n > max {
+		return max, nil
+	}
+
+	return n, nil
+}
+
+// statsSince is the first day included when looking at the given number of days, including today
+func statsSince(days int) time.Time {
+	return model.Day(time.Now()).AddDate(0, 0, -(days - 1))
+}
+
+// recordResolution counts a successful lookup against the thing which was found,
+// and the service which the input belongs to (if it was a link).
+// The parsed input is used so that service URIs and short links are counted against their service too.
+func recordResolution(analyticsRec analytics.Recorder, r *http.Request, input *streamingservice.Input, res any) {
+	typ, id, ok := resultId(res)
+	if !ok {
+		return
+	}
+
+	// ISRCs and UPCs don't belong to any service
+	var source model.StreamingServiceType
+	if input.Type == streamingservice.LinkInput {
+		if info, ok := streamingservice.ParseLink(input.Value); ok {
+			source = info.Source
+		}
+	}
+
+	analyticsRec.RecordResolution(r, typ, id, source)
+}
+
+// resultId finds the type and ID shared by everything in the result
+func resultId(res any) (model.Type, string, bool) {
+	switch res := res.(type) {
+	case *Result[*model.Artist]:
+		if res.HasResults() {
+			return model.ArtistType, res.Items[0].ArtistId, true
+		}
+
+	case *Result[*model.Album]:
+		if res.HasResults() {
+			return model.AlbumType, res.Items[0].AlbumId, true
+		}
+
+	case *Result[*model.Track]:
+		if res.HasResults() {
+			return model.TrackType, res.Items[0].Isrc, true
+		}
+	}
+
+	return model.UnknownType, "", false
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+)
+
+// testAnalyticsRecorder keeps everything it's given, without the time, so that tests can check what was recorded
+type testAnalyticsRecorder struct {
+	mu     sync.Mutex
+	events []*model.AnalyticsEvent
+}
+
+func (rec *testAnalyticsRecorder) RecordClick(_ *http.Request, typ model.Type, id string, target model.StreamingServiceType) {
+	rec.add(&model.AnalyticsEvent{Kind: model.ClickEvent, Type: typ, Id: id, Target: target})
+}
+
+func (rec *testAnalyticsRecorder) RecordResolution(_ *http.Request, typ model.Type, id string, source model.StreamingServiceType) {
+	rec.add(&model.AnalyticsEvent{Kind: model.ResolveEvent, Type: typ, Id: id, Source: source})
+}
+
+func (rec *testAnalyticsRecorder) add(event *model.AnalyticsEvent) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.events = append(rec.events, event)
+}
+
+func (rec *testAnalyticsRecorder) Events() []*model.AnalyticsEvent {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.events
+}
+
+func recordTestEvents(t *testing.T, repo db.Repository) {
+	now := time.Now()
+	events := []*model.AnalyticsEvent{
+		{Kind: model.ClickEvent, Type: model.TrackType, Id: sstesting.Isrc, Target: model.SpotifyStreamingService, Time: now},
+		{Kind: model.ClickEvent, Type: model.TrackType, Id: sstesting.Isrc, Target: model.SpotifyStreamingService, Time: now},
+		{Kind: model.ClickEvent, Type: model.TrackType, Id: sstesting.Isrc, Target: model.DeezerStreamingService, Time: now},
+		{Kind: model.ClickEvent, Type: model.AlbumType, Id: "album-1", Target: model.DeezerStreamingService, Time: now},
+		{Kind: model.ResolveEvent, Type: model.AlbumType, Id: "album-1", Source: model.AppleMusicStreamingService, Time: now},
+
+		// Too long ago to count
+		{Kind: model.ClickEvent, Type: model.ArtistType, Id: "artist-1", Target: model.SpotifyStreamingService, Time: now.AddDate(0, 0, -30)},
+	}
+
+	for _, event := range events {
+		require.NoError(t, repo.RecordEvent(context.Background(), event))
+	}
+}
+
+func Test_TopStats(t *testing.T) {
+	repo := db.NewInMemoryRepository()
+	recordTestEvents(t, repo)
+
+	rec := httptest.NewRecorder()
+	GetTopStatsHandler(repo)(rec, httptest.NewRequest(http.MethodGet, "/stats/top?days=7", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats []*model.ThingStats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Equal(t, []*model.ThingStats{
+		{Type: model.TrackType, Id: sstesting.Isrc, Count: 3},
+		{Type: model.AlbumType, Id: "album-1", Count: 1},
+	}, stats)
+
+	rec = httptest.NewRecorder()
+	GetTopStatsHandler(repo)(rec, httptest.NewRequest(http.MethodGet, "/stats/top?kind=resolve&limit=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	stats = nil
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Equal(t, []*model.ThingStats{
+		{Type: model.AlbumType, Id: "album-1", Count: 1},
+	}, stats)
+}
+
+func Test_ServiceStats(t *testing.T) {
+	repo := db.NewInMemoryRepository()
+	recordTestEvents(t, repo)
+
+	rec := httptest.NewRecorder()
+	GetServiceStatsHandler(repo)(rec, httptest.NewRequest(http.MethodGet, "/stats/services", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats []*model.ServiceStats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Equal(t, []*model.ServiceStats{
+		{Service: model.AppleMusicStreamingService, Resolutions: 1},
+		{Service: model.DeezerStreamingService, Clicks: 2},
+		{Service: model.SpotifyStreamingService, Clicks: 2},
+	}, stats)
+}
+
+func Test_StatsRejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{
+			name:    "unknown kind",
+			handler: GetTopStatsHandler(db.NewInMemoryRepository()),
+			target:  "/stats/top?kind=view",
+		},
+		{
+			name:    "invalid limit",
+			handler: GetTopStatsHandler(db.NewInMemoryRepository()),
+			target:  "/stats/top?limit=-1",
+		},
+		{
+			name:    "invalid days",
+			handler: GetServiceStatsHandler(db.NewInMemoryRepository()),
+			target:  "/stats/services?days=lots",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			testCase.handler(rec, httptest.NewRequest(http.MethodGet, testCase.target, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func Test_RecordResolutionUsesTheParsedInput(t *testing.T) {
+	res := NewResult[*model.Track](model.TrackType)
+	res.Add(sstesting.Track(model.SpotifyStreamingService))
+
+	testCases := []struct {
+		name   string
+		input  string
+		expect model.StreamingServiceType
+	}{
+		{
+			name:   "service URI",
+			input:  "spotify:track:4cOdK2wGLETKBW3PvgPWqT",
+			expect: model.SpotifyStreamingService,
+		},
+		{
+			name:   "link",
+			input:  "https://www.deezer.com/track/3135556",
+			expect: model.DeezerStreamingService,
+		},
+		{
+			name:  "ISRC",
+			input: sstesting.Isrc,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			input, err := testParser().Parse(context.Background(), testCase.input)
+			require.NoError(t, err)
+
+			rec := &testAnalyticsRecorder{}
+			recordResolution(rec, httptest.NewRequest(http.MethodGet, "/link", nil), input, res)
+
+			assert.Equal(t, []*model.AnalyticsEvent{
+				{Kind: model.ResolveEvent, Type: model.TrackType, Id: sstesting.Isrc, Source: testCase.expect},
+			}, rec.Events())
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -9,10 +10,11 @@ import (
 	"github.com/yukitsune/maestro/pkg/log"
 	"github.com/yukitsune/maestro/pkg/model"
 	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
 	"net/http"
 )
 
-func GetTrackByIsrcHandler(repo db.Repository, serviceProvider streamingservice.ServiceProvider, logger *logrus.Logger) http.HandlerFunc {
+func GetTrackByIsrcHandler(repo db.Repository, serviceProvider streamingservice.ServiceProvider, group *singleflight.Group, logger *logrus.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		reqLogger, err := log.ForRequest(logger, r)
@@ -28,87 +30,119 @@ func GetTrackByIsrcHandler(repo db.Repository, serviceProvider streamingservice.
 			return
 		}
 
-		foundTracks, err := repo.GetTracksByIsrc(r.Context(), isrc)
+		res, err := findForIsrc(r.Context(), isrc, serviceProvider, repo, group, reqLogger)
 		if err != nil {
 			responses.Error(w, err)
 			return
 		}
 
-		// ISRCs weren't always used here, need this for backwards compatibility
-		legacyTracks, err := repo.GetTracksByLegacyId(r.Context(), isrc)
-		if err != nil {
-			responses.Error(w, err)
+		if !res.HasResults() {
+			responses.NotFoundf(w, "could not find any tracks with ISRC code %s", isrc)
 			return
 		}
 
-		for _, legacyTrack := range legacyTracks {
-			foundTracks = append(foundTracks, legacyTrack)
+		responses.Response(w, res, http.StatusOK)
+	}
+}
+
+// findForIsrc is the only way tracks are added, so that lookups for the same ISRC can't add the same tracks twice,
+// no matter whether they started from an ISRC or a link.
+// Any tracks we've already been given, but haven't stored yet, can be passed along to save looking them up again.
+func findForIsrc(ctx context.Context, isrc string, serviceProvider streamingservice.ServiceProvider, repo db.Repository, group *singleflight.Group, logger *logrus.Entry, known ...*model.Track) (*Result[*model.Track], error) {
+
+	// Concurrent lookups for the same ISRC can share the same result
+	key := fmt.Sprintf("isrc:%s", isrc)
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		return lookupIsrc(detach(ctx), isrc, known, serviceProvider, repo, logger)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Result[*model.Track]), nil
+}
+
+func lookupIsrc(ctx context.Context, isrc string, known []*model.Track, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Entry) (*Result[*model.Track], error) {
+
+	foundTracks, err := repo.GetTracksByIsrc(ctx, isrc)
+	if err != nil {
+		return nil, err
+	}
+
+	// ISRCs weren't always used here, need this for backwards compatibility
+	legacyTracks, err := repo.GetTracksByLegacyId(ctx, isrc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, legacyTrack := range legacyTracks {
+		foundTracks = append(foundTracks, legacyTrack)
+	}
+
+	svcs, err := serviceProvider.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize services: %s", err.Error())
+	}
+
+	res := NewResult[*model.Track](model.TrackType)
+	res.AddAll(foundTracks)
+
+	// Someone else may have stored what we were given while we were waiting
+	var newTracks []*model.Track
+	for _, track := range known {
+		if res.HasResultFor(track.Source) || res.IsDeadLink(track.Link) {
+			continue
 		}
 
-		svcs, err := serviceProvider.ListServices()
+		res.Add(track)
+		newTracks = append(newTracks, track)
+	}
+
+	if len(res.Items) != len(svcs) {
+		tracks, err := getNewTrackByIsrc(isrc, res, svcs, logger)
 		if err != nil {
-			responses.Error(w, fmt.Errorf("failed to initialize services: %s", err.Error()))
+			return nil, err
 		}
 
-		if len(foundTracks) != len(svcs) {
-			newTracks, err := getNewTrackByIsrc(isrc, foundTracks, svcs, reqLogger)
-			if err != nil {
-				responses.Error(w, err)
-				return
-			}
-
-			if len(newTracks) > 0 {
-				n, err := repo.AddTracks(r.Context(), newTracks)
-				if err != nil {
-					responses.Error(w, err)
-					return
-				}
-
-				reqLogger.Infof("%d new tracks added", n)
-
-				for _, newTrack := range newTracks {
-					foundTracks = append(foundTracks, newTrack)
-				}
-			}
-		}
+		res.AddAll(tracks)
+		newTracks = append(newTracks, tracks...)
+	}
 
-		if len(foundTracks) == 0 {
-			responses.NotFoundf(w, "could not find any tracks with ISRC code %s", isrc)
-			return
+	// Only the tracks we found are added, so any services which failed will be tried again next time
+	if len(newTracks) > 0 {
+		n, err := repo.AddTracks(ctx, newTracks)
+		if err != nil {
+			return nil, err
 		}
 
-		res := NewResult[*model.Track](model.TrackType)
-		res.AddAll(foundTracks)
-
-		responses.Response(w, res, http.StatusOK)
+		logger.Infof("%d new tracks added", n)
 	}
+
+	setDisabled(res, serviceProvider)
+	return res, nil
 }
 
-func getNewTrackByIsrc(isrc string, knownTracks []*model.Track, svcs streamingservice.StreamingServices, logger *logrus.Entry) ([]*model.Track, error) {
+func getNewTrackByIsrc(isrc string, res *Result[*model.Track], svcs streamingservice.StreamingServices, logger *logrus.Entry) ([]*model.Track, error) {
 
 	var tracks []*model.Track
 
 	for key, svc := range svcs {
 
 		// Skip if we know about this track
-		trackIsKnown := false
-		for _, knownTrack := range knownTracks {
-			if knownTrack.Source == key {
-				trackIsKnown = true
-			}
-		}
-
-		if trackIsKnown {
+		if res.HasResultFor(key) {
 			continue
 		}
 
 		track, found, err := svc.GetTrackByIsrc(isrc)
 		if err != nil {
-			logger.Errorf("%s: %s", key, err.Error())
+			logServiceError(logger, key, err)
+			res.SetError(key, err)
 			continue
 		}
 
-		if !found {
+		if !found || res.IsDeadLink(track.Link) {
+			res.SetNotFound(key)
 			continue
 		}
 
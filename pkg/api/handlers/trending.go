@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/log"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+const (
+	defaultTrendingLimit = 10
+	maxTrendingLimit     = 50
+)
+
+// TrendingItem is something which has been looked up a lot recently, along with where it can be found
+type TrendingItem struct {
+	Type        model.Type
+	Id          string
+	Score       float64
+	Name        string
+	ArtistNames []string `json:",omitempty"`
+	ArtworkLink string   `json:",omitempty"`
+	ShareLink   string
+	Links       map[model.StreamingServiceType]string
+}
+
+// GetTrendingHandler lists what's trending. The scores are kept up to date by the TrendingWorker,
+// so all this has to do is fill in the details.
+func GetTrendingHandler(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		reqLogger, err := log.ForRequest(logger, r)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		q := r.URL.Query()
+
+		typ := model.Type(q.Get("type"))
+		if len(typ) > 0 && !isShareable(typ) {
+			responses.BadRequestf(w, "unknown type %s, expected one of artist, album, or track", typ)
+			return
+		}
+
+		window := model.TrendingDay
+		if wnd := q.Get("window"); len(wnd) > 0 {
+			window = model.TrendingWindow(wnd)
+		}
+
+		if window.Duration() == 0 {
+			responses.BadRequestf(w, "unknown window %s, expected one of 24h or 7d", window)
+			return
+		}
+
+		limit, err := statsParameter(q.Get("limit"), defaultTrendingLimit, maxTrendingLimit)
+		if err != nil {
+			responses.BadRequestf(w, "invalid limit: %s", err.Error())
+			return
+		}
+
+		scores, err := repo.GetTrending(r.Context(), window, typ, limit)
+		if err != nil {
+			responses.Error(w, err)
+			return
+		}
+
+		items := []*TrendingItem{}
+		for _, score := range scores {
+			page, err := storedSharePage(r.Context(), score.Type, score.Id, serviceProvider, repo)
+			if err != nil {
+				responses.Error(w, err)
+				return
+			}
+
+			// Everything could have gone dead since the scores were worked out
+			if page == nil {
+				reqLogger.Debugf("nothing left for trending %s %s", score.Type, score.Id)
+				continue
+			}
+
+			page.setLinks(apiConfig.PublicURL())
+			items = append(items, newTrendingItem(score, page))
+		}
+
+		responses.Response(w, items, http.StatusOK)
+	}
+}
+
+func newTrendingItem(score *model.TrendingScore, page *sharePage) *TrendingItem {
+	item := &TrendingItem{
+		Type:        score.Type,
+		Id:          score.Id,
+		Score:       score.Score,
+		Name:        page.Name,
+		ArtistNames: page.ArtistNames,
+		ArtworkLink: page.ImageLink,
+		ShareLink:   page.PageLink,
+		Links:       make(map[model.StreamingServiceType]string),
+	}
+
+	for _, button := range page.Buttons {
+		item.Links[button.Service] = button.Link
+	}
+
+	return item
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mcontext "github.com/yukitsune/maestro/pkg/api/context"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+)
+
+func getTrending(t *testing.T, f *testFixture, query string) *httptest.ResponseRecorder {
+	handler := GetTrendingHandler(testApiConfig(), f.provider, f.repo, testLogger().Logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/trending"+query, nil)
+	req = req.WithContext(mcontext.WithRequestID(req.Context(), "test-request"))
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func Test_Trending(t *testing.T) {
+	f := newTestFixture()
+	ctx := context.Background()
+
+	track := sstesting.Track(model.SpotifyStreamingService)
+	track.ArtworkLink = "https://images.test/surrender.jpg"
+	addTestTracks(t, f, model.AppleMusicStreamingService)
+	_, err := f.repo.AddTracks(ctx, []*model.Track{track})
+	require.NoError(t, err)
+
+	album := sstesting.Album(model.DeezerStreamingService)
+	album.AlbumId = "album-1"
+	_, err = f.repo.AddAlbum(ctx, []*model.Album{album})
+	require.NoError(t, err)
+
+	require.NoError(t, f.repo.ReplaceTrending(ctx, model.TrendingWeek, []*model.TrendingScore{
+		{Window: model.TrendingWeek, Type: model.TrackType, Id: sstesting.Isrc, Score: 3},
+		{Window: model.TrendingWeek, Type: model.AlbumType, Id: "album-1", Score: 2},
+
+		// Nothing is stored for this one, so it should be skipped
+		{Window: model.TrendingWeek, Type: model.ArtistType, Id: "artist-1", Score: 1},
+	}))
+
+	rec := getTrending(t, f, "?window=7d")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var items []*TrendingItem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Len(t, items, 2)
+
+	assert.Equal(t, &TrendingItem{
+		Type:        model.TrackType,
+		Id:          sstesting.Isrc,
+		Score:       3,
+		Name:        track.Name,
+		ArtistNames: track.ArtistNames,
+		ArtworkLink: "https://images.test/surrender.jpg",
+		ShareLink:   "https://maestro.test/share/track/" + sstesting.Isrc,
+		Links: map[model.StreamingServiceType]string{
+			model.AppleMusicStreamingService: sstesting.Track(model.AppleMusicStreamingService).Link,
+			model.SpotifyStreamingService:    track.Link,
+		},
+	}, items[0])
+
+	assert.Equal(t, model.AlbumType, items[1].Type)
+	assert.Equal(t, "album-1", items[1].Id)
+
+	// Trending is worked out ahead of time, so nothing should have been looked up
+	assert.Equal(t, 0, f.totalCalls())
+
+	// Filtering by type
+	rec = getTrending(t, f, "?window=7d&type=album")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	items = nil
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "album-1", items[0].Id)
+
+	// Nothing has been worked out for the last day
+	rec = getTrending(t, f, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+func Test_TrendingRejectsBadRequests(t *testing.T) {
+	for _, query := range []string{"?type=playlist", "?window=1y", "?limit=0"} {
+		t.Run(query, func(t *testing.T) {
+			rec := getTrending(t, newTestFixture(), query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -5,16 +5,23 @@ import (
 	"fmt"
 	"github.com/yukitsune/maestro/pkg/db"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro"
+	"github.com/yukitsune/maestro/pkg/analytics"
 	"github.com/yukitsune/maestro/pkg/api/handlers"
 	"github.com/yukitsune/maestro/pkg/api/middleware"
+	v1 "github.com/yukitsune/maestro/pkg/api/v1"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
 	"github.com/yukitsune/maestro/pkg/config"
 	"github.com/yukitsune/maestro/pkg/metrics"
 	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"golang.org/x/sync/singleflight"
 )
 
 type MaestroServer struct {
@@ -23,9 +30,18 @@ type MaestroServer struct {
 	svr    *http.Server
 }
 
-func NewMaestroServer(apiCfg config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, rec metrics.Recorder, logger *logrus.Logger) (*MaestroServer, error) {
+// NewMaestroServer sets up the API. Concurrent lookups for the same thing are shared through the given group,
+// which can be shared with anything else that adds to what we know about, like the ResolveWorker.
+func NewMaestroServer(apiCfg config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, resolver clients.RedirectResolver, group *singleflight.Group, rec metrics.Recorder, analyticsRec analytics.Recorder, logger *logrus.Logger) (*MaestroServer, error) {
 
-	router := setupRouter(apiCfg, serviceProvider, repo, rec, logger)
+	// Resized artwork is kept on disk, there's too much of it to keep in memory
+	artworkCfg := apiCfg.Artwork()
+	artworkCache, err := cache.NewDiskCache(artworkCfg.CacheDirectory(), artworkCfg.CacheSize(), artworkCfg.CacheTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	router := setupRouter(apiCfg, serviceProvider, repo, resolver, group, rec, analyticsRec, artworkCache, logger)
 
 	addr := fmt.Sprintf(":%d", apiCfg.Port())
 	svr := &http.Server{
@@ -54,7 +70,7 @@ func (api *MaestroServer) Shutdown(ctx context.Context) error {
 	return api.svr.Shutdown(ctx)
 }
 
-func setupRouter(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, rec metrics.Recorder, logger *logrus.Logger) *mux.Router {
+func setupRouter(apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, resolver clients.RedirectResolver, group *singleflight.Group, rec metrics.Recorder, analyticsRec analytics.Recorder, artworkCache cache.Cache, logger *logrus.Logger) *mux.Router {
 
 	r := mux.NewRouter()
 
@@ -72,15 +88,58 @@ func setupRouter(apiConfig config.API, serviceProvider streamingservice.ServiceP
 	// Metrics
 	r.Handle("/metrics", promhttp.Handler())
 
+	// Docs
+	r.HandleFunc("/openapi.json", v1.GetSpecHandler()).Methods("GET")
+
+	// Short links are followed to find out what they point to
+	parser := streamingservice.NewInputParser(resolver)
+
+	// API
+	// Everything is served from /v1 in the shapes described by the spec. The unversioned routes are still around
+	// for anyone who hasn't moved over yet, they respond the same way they always have.
+	apiV1 := r.PathPrefix("/v1").Subrouter()
+	apiV1.Use(middleware.Present(v1.Present))
+	addApiRoutes(apiV1, apiConfig, serviceProvider, repo, parser, group, analyticsRec, logger)
+
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(middleware.Deprecated("/v1"))
+	addApiRoutes(legacy, apiConfig, serviceProvider, repo, parser, group, analyticsRec, logger)
+
+	// Pages
+	// Artwork links come from the streaming services, but they're fetched as carefully as any other link
+	artworkClient := &http.Client{Transport: clients.NewPublicTransport(), Timeout: 10 * time.Second}
+	r.HandleFunc("/share/{type}/{id}", handlers.GetSharePageHandler(apiConfig, serviceProvider, repo, group, logger)).Methods("GET")
+	r.HandleFunc("/embed/{type}/{id}", handlers.GetEmbedHandler(apiConfig, serviceProvider, repo, group, logger)).Methods("GET")
+	r.HandleFunc("/s/{code}", handlers.GetShortLinkHandler(apiConfig, repo)).Methods("GET")
+	r.HandleFunc("/go/{type}/{id}", handlers.GetRedirectHandler(apiConfig, serviceProvider, repo, analyticsRec, logger)).Methods("GET")
+	r.HandleFunc("/qr/{type}/{id}.{format:png|svg}", handlers.GetQRCodeHandler(apiConfig, serviceProvider, repo, artworkClient, logger)).Methods("GET")
+	r.HandleFunc("/artwork/{type}/{id}", handlers.GetArtworkHandler(repo, artworkClient, artworkCache, group, logger)).Methods("GET")
+	r.HandleFunc("/oembed", handlers.GetOEmbedHandler(apiConfig, serviceProvider, repo, parser, group, logger)).Methods("GET")
+
+	return r
+}
+
+// addApiRoutes adds the routes which respond with JSON, these are the ones which are versioned
+func addApiRoutes(r *mux.Router, apiConfig config.API, serviceProvider streamingservice.ServiceProvider, repo db.Repository, parser streamingservice.InputParser, group *singleflight.Group, analyticsRec analytics.Recorder, logger *logrus.Logger) {
+
 	// Services
-	r.HandleFunc("/services/{serviceName}/logo", handlers.GetServiceLogoHandler(apiConfig, serviceProvider, logger)).Methods("GET")
+	// Logos are built in, but can be swapped out by putting new ones in the assets directory
+	logos := maestro.Logos(filepath.Join(apiConfig.AssetsDirectory(), "logos"))
+	r.HandleFunc("/services/{serviceName}/logo", handlers.GetServiceLogoHandler(logos, serviceProvider, logger)).Methods("GET")
 	r.HandleFunc("/services", handlers.GetListServicesHandler(serviceProvider)).Methods("GET")
 
 	// Links
-	r.HandleFunc("/link", handlers.GetLinkHandler(serviceProvider, repo, logger)).Methods("GET").Queries("link", "{link}")
+	r.HandleFunc("/link", handlers.GetLinkHandler(serviceProvider, repo, parser, group, analyticsRec, logger)).Methods("GET").Queries("link", "{link}")
+	r.HandleFunc("/links", handlers.PostLinksHandler(apiConfig, serviceProvider, repo, parser, group, analyticsRec, logger)).Methods("POST")
+	r.HandleFunc("/search", handlers.GetSearchHandler(serviceProvider, repo, logger)).Methods("GET")
+	r.HandleFunc("/search", handlers.PostSearchHandler(serviceProvider, repo, logger)).Methods("POST")
 	r.HandleFunc("/artist/{id}", handlers.GetArtistByIdHandler(repo)).Methods("GET")
 	r.HandleFunc("/album/{id}", handlers.GetAlbumByIdHandler(repo)).Methods("GET")
-	r.HandleFunc("/track/{isrc}", handlers.GetTrackByIsrcHandler(repo, serviceProvider, logger)).Methods("GET")
+	r.HandleFunc("/track/{isrc}", handlers.GetTrackByIsrcHandler(repo, serviceProvider, group, logger)).Methods("GET")
+	r.HandleFunc("/s", handlers.PostShortLinkHandler(apiConfig, repo, group, logger)).Methods("POST")
 
-	return r
+	// Stats
+	r.HandleFunc("/stats/top", handlers.GetTopStatsHandler(repo)).Methods("GET")
+	r.HandleFunc("/stats/services", handlers.GetServiceStatsHandler(repo)).Methods("GET")
+	r.HandleFunc("/trending", handlers.GetTrendingHandler(apiConfig, serviceProvider, repo, logger)).Methods("GET")
 }
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/analytics"
+	v1 "github.com/yukitsune/maestro/pkg/api/v1"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"golang.org/x/sync/singleflight"
+)
+
+func testArtist(key model.StreamingServiceType) *model.Artist {
+	artist := sstesting.Artist(key)
+	artist.ArtistId = "artist-1"
+	return artist
+}
+
+func testAlbum(key model.StreamingServiceType) *model.Album {
+	album := sstesting.Album(key)
+	album.AlbumId = "album-1"
+	return album
+}
+
+// newTestRouter sets up the whole API against fake services, with a few of everything already stored
+func newTestRouter(t *testing.T) *mux.Router {
+	ctx := context.Background()
+
+	v := viper.New()
+	v.Set("api.public_url", "https://maestro.test/")
+	v.Set("api.assets_dir", t.TempDir())
+	apiConfig := config.NewApiViperConfig(v)
+
+	services := sstesting.NewFakeStreamingServices()
+	repo := db.NewInMemoryRepository()
+	for _, key := range sstesting.ServiceKeys {
+		_, err := repo.AddArtist(ctx, []*model.Artist{testArtist(key)})
+		require.NoError(t, err)
+
+		_, err = repo.AddAlbum(ctx, []*model.Album{testAlbum(key)})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, repo.RecordEvent(ctx, &model.AnalyticsEvent{
+		Kind:   model.ClickEvent,
+		Type:   model.AlbumType,
+		Id:     "album-1",
+		Target: model.SpotifyStreamingService,
+		Time:   time.Now(),
+	}))
+
+	require.NoError(t, repo.ReplaceTrending(ctx, model.TrendingDay, []*model.TrendingScore{
+		{Window: model.TrendingDay, Type: model.AlbumType, Id: "album-1", Score: 1.5, Updated: time.Now()},
+	}))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	resolver := clients.NewRedirectResolver(streamingservice.ShortLinkHosts, 5, time.Second, cache.NewLRUCache(10, time.Minute), clients.NewRedirectingTransport(nil))
+	return setupRouter(
+		apiConfig,
+		services.Provider(),
+		repo,
+		resolver,
+		&singleflight.Group{},
+		metrics.NewNoopMetricsRecorder(),
+		analytics.NewNoopRecorder(),
+		cache.NewLRUCache(10, time.Minute),
+		logger)
+}
+
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(v1.Spec())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(loader.Context))
+
+	specRouter, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	return doc, specRouter
+}
+
+func Test_SpecIsServed(t *testing.T) {
+	router := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, v1.Spec(), rec.Body.Bytes())
+}
+
+func Test_V1MatchesSpec(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	router := newTestRouter(t)
+
+	// Logos are checked for their content type, there's nothing else to check in them
+	openapi3filter.RegisterBodyDecoder("image/png", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("image/svg+xml", openapi3filter.FileBodyDecoder)
+
+	spotifyTrackLink := sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender")
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		expect int
+
+		// Requests which are meant to be rejected aren't checked against the spec, only what we respond with is
+		invalid bool
+	}{
+		{name: "services", method: http.MethodGet, path: "/v1/services", expect: http.StatusOK},
+		{name: "link", method: http.MethodGet, path: "/v1/link?" + url.Values{"link": {spotifyTrackLink}}.Encode(), expect: http.StatusOK},
+		{name: "invalid link", method: http.MethodGet, path: "/v1/link?link=nonsense", expect: http.StatusBadRequest},
+		{name: "links", method: http.MethodPost, path: "/v1/links", body: map[string]any{"links": []string{spotifyTrackLink, "nonsense"}}, expect: http.StatusOK},
+		{name: "search", method: http.MethodGet, path: "/v1/search?q=surrender&type=track", expect: http.StatusOK},
+		{name: "search without a query", method: http.MethodGet, path: "/v1/search", expect: http.StatusBadRequest, invalid: true},
+		{name: "choose a search result", method: http.MethodPost, path: "/v1/search", body: map[string]any{"type": "track", "links": []string{spotifyTrackLink}}, expect: http.StatusOK},
+		{name: "choose a search result of an unknown type", method: http.MethodPost, path: "/v1/search", body: map[string]any{"type": "playlist", "links": []string{spotifyTrackLink}}, expect: http.StatusBadRequest, invalid: true},
+		{name: "artist", method: http.MethodGet, path: "/v1/artist/artist-1", expect: http.StatusOK},
+		{name: "album", method: http.MethodGet, path: "/v1/album/album-1", expect: http.StatusOK},
+		{name: "unknown album", method: http.MethodGet, path: "/v1/album/does-not-exist", expect: http.StatusNotFound},
+		{name: "track", method: http.MethodGet, path: "/v1/track/" + sstesting.Isrc, expect: http.StatusOK},
+		{name: "short link", method: http.MethodPost, path: "/v1/s", body: map[string]any{"type": "album", "id": "album-1"}, expect: http.StatusCreated},
+		{name: "short link to an unknown type", method: http.MethodPost, path: "/v1/s", body: map[string]any{"type": "playlist", "id": "1"}, expect: http.StatusBadRequest, invalid: true},
+		{name: "top stats", method: http.MethodGet, path: "/v1/stats/top?kind=click&days=7", expect: http.StatusOK},
+		{name: "service stats", method: http.MethodGet, path: "/v1/stats/services", expect: http.StatusOK},
+		{name: "trending", method: http.MethodGet, path: "/v1/trending?window=24h", expect: http.StatusOK},
+		{name: "logo", method: http.MethodGet, path: "/v1/services/spotify/logo?variant=dark", expect: http.StatusOK},
+		{name: "unknown logo format", method: http.MethodGet, path: "/v1/services/spotify/logo?format=gif", expect: http.StatusBadRequest, invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var body []byte
+			if testCase.body != nil {
+				var err error
+				body, err = json.Marshal(testCase.body)
+				require.NoError(t, err)
+			}
+
+			newRequest := func() *http.Request {
+				req := httptest.NewRequest(testCase.method, "http://maestro.test"+testCase.path, bytes.NewReader(body))
+				if body != nil {
+					req.Header.Set("Content-Type", "application/json")
+				}
+
+				return req
+			}
+
+			route, pathParams, err := specRouter.FindRoute(newRequest())
+			require.NoError(t, err)
+
+			reqInput := &openapi3filter.RequestValidationInput{
+				Request:    newRequest(),
+				PathParams: pathParams,
+				Route:      route,
+			}
+
+			if !testCase.invalid {
+				require.NoError(t, openapi3filter.ValidateRequest(context.Background(), reqInput))
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, newRequest())
+			require.Equal(t, testCase.expect, rec.Code, rec.Body.String())
+
+			resInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: reqInput,
+				Status:                 rec.Code,
+				Header:                 rec.Header(),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			}
+
+			resInput.SetBodyBytes(rec.Body.Bytes())
+			assert.NoError(t, openapi3filter.ValidateResponse(context.Background(), resInput))
+		})
+	}
+}
+
+func Test_UnversionedRoutesAreDeprecatedAliases(t *testing.T) {
+	router := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/album/album-1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/album/album-1>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	// Still in the old shape
+	var res map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "album", res["Type"])
+	assert.Len(t, res["Items"], len(sstesting.ServiceKeys))
+
+	// What we keep track of for storage isn't part of it
+	for _, item := range res["Items"].([]any) {
+		assert.NotContains(t, item, "LinkKey")
+		assert.NotContains(t, item, "Aliases")
+		assert.NotContains(t, item, "LastVerified")
+		assert.NotContains(t, item, "Dead")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/album/album-1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	res = nil
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "album", res["type"])
+	assert.Len(t, res["items"], len(sstesting.ServiceKeys))
+}
+
+func Test_PagesAreNotVersioned(t *testing.T) {
+	router := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/share/album/album-1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/share/album/album-1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+)
+
+// Present has every response go through the given Presenter, so that it's written in that version of the API's shape
+func Present(present responses.Presenter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(responses.WithPresenter(w, present), r)
+		})
+	}
+}
+
+// Deprecated marks responses as coming from a deprecated route, and links to the route which replaces it
+func Deprecated(successorPrefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, r.URL.EscapedPath()))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
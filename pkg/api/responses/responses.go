@@ -1,8 +1,10 @@
 package responses
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"net/http"
 )
 
@@ -10,14 +12,42 @@ type ErrorResource struct {
 	Error string
 }
 
+// Presenter changes what's written by Response into the shape used by a particular version of the API
+type Presenter func(res interface{}) (interface{}, error)
+
+type presentingWriter struct {
+	http.ResponseWriter
+	present Presenter
+}
+
+// WithPresenter returns a writer which has everything written by Response go through the given Presenter first
+func WithPresenter(w http.ResponseWriter, present Presenter) http.ResponseWriter {
+	return &presentingWriter{w, present}
+}
+
 func Response(w http.ResponseWriter, res interface{}, status int) {
 
+	if pw, ok := w.(*presentingWriter); ok {
+		presented, err := pw.present(res)
+		if err != nil {
+			// Going through Error would try (and fail) to present the error too
+			Response(pw.ResponseWriter, &ErrorResource{err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		res = presented
+	}
+
 	resBytes, err := json.MarshalIndent(res, "", "\t")
 	if err != nil {
 		Error(w, err)
 		return
 	}
 
+	if len(w.Header().Get("Content-Type")) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
 	w.WriteHeader(status)
 	_, err = w.Write(resBytes)
 	if err != nil {
@@ -26,12 +56,23 @@ func Response(w http.ResponseWriter, res interface{}, status int) {
 	}
 }
 
-func Image(w http.ResponseWriter, bytes []byte) {
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/octet-stream")
-	_, err := w.Write(bytes)
+// Page renders the given template as an HTML page
+func Page(w http.ResponseWriter, tmpl *template.Template, data interface{}, status int) {
+
+	// Render everything up front so that a broken template doesn't leave us with half a page
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
 	if err != nil {
 		Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		Error(w, err)
+		return
 	}
 }
 
@@ -57,6 +98,12 @@ func BadRequestf(w http.ResponseWriter, format string, v ...interface{}) {
 	Response(w, res, http.StatusBadRequest)
 }
 
+func NotImplementedf(w http.ResponseWriter, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	res := &ErrorResource{msg}
+	Response(w, res, http.StatusNotImplemented)
+}
+
 func Error(w http.ResponseWriter, err error) {
 	res := &ErrorResource{err.Error()}
 	Response(w, res, http.StatusInternalServerError)
@@ -0,0 +1,102 @@
+package v1
+
+// These are the shapes used by the /v1 API, described by openapi.json.
+// They're kept separate from pkg/model so that what's stored can change without changing what's returned.
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+type Service struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	State   string `json:"state"`
+}
+
+type Artist struct {
+	ArtistId    string `json:"artist_id"`
+	Name        string `json:"name"`
+	ArtworkLink string `json:"artwork_link,omitempty"`
+	Source      string `json:"source"`
+	Market      string `json:"market"`
+	Link        string `json:"link"`
+	ServiceId   string `json:"service_id,omitempty"`
+	Storefront  string `json:"storefront,omitempty"`
+}
+
+type Album struct {
+	AlbumId     string   `json:"album_id"`
+	Upc         string   `json:"upc,omitempty"`
+	Name        string   `json:"name"`
+	ArtistNames []string `json:"artist_names"`
+	ArtworkLink string   `json:"artwork_link,omitempty"`
+	Source      string   `json:"source"`
+	Market      string   `json:"market"`
+	Link        string   `json:"link"`
+	ServiceId   string   `json:"service_id,omitempty"`
+	Storefront  string   `json:"storefront,omitempty"`
+}
+
+type Track struct {
+	Isrc        string   `json:"isrc"`
+	Name        string   `json:"name"`
+	ArtistNames []string `json:"artist_names"`
+	AlbumName   string   `json:"album_name,omitempty"`
+	ArtworkLink string   `json:"artwork_link,omitempty"`
+	Source      string   `json:"source"`
+	Market      string   `json:"market"`
+	Link        string   `json:"link"`
+	ServiceId   string   `json:"service_id,omitempty"`
+	Storefront  string   `json:"storefront,omitempty"`
+}
+
+// ServiceStatus is what happened when a streaming service was asked about something
+type ServiceStatus struct {
+	Status     string `json:"status"`
+	ErrorClass string `json:"error_class,omitempty"`
+}
+
+// Result is the same thing on each of the streaming services
+type Result[T Artist | Album | Track] struct {
+	Type     string                   `json:"type"`
+	Items    []T                      `json:"items"`
+	Services map[string]ServiceStatus `json:"services,omitempty"`
+}
+
+type BatchLinkResult struct {
+	Link   string `json:"link"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+type ShortLink struct {
+	Code string `json:"code"`
+	Link string `json:"link"`
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type ThingStats struct {
+	Type  string `json:"type"`
+	Id    string `json:"id"`
+	Count int    `json:"count"`
+}
+
+type ServiceStats struct {
+	Service     string `json:"service"`
+	Clicks      int    `json:"clicks"`
+	Resolutions int    `json:"resolutions"`
+}
+
+type TrendingItem struct {
+	Type        string            `json:"type"`
+	Id          string            `json:"id"`
+	Score       float64           `json:"score"`
+	Name        string            `json:"name"`
+	ArtistNames []string          `json:"artist_names,omitempty"`
+	ArtworkLink string            `json:"artwork_link,omitempty"`
+	ShareLink   string            `json:"share_link"`
+	Links       map[string]string `json:"links"`
+}
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec is the OpenAPI 3 document describing the /v1 API
+func Spec() []byte {
+	return spec
+}
+
+// GetSpecHandler serves the OpenAPI 3 document
+func GetSpecHandler() http.HandlerFunc {
+	hash := sha256.Sum256(spec)
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16]))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Header().Set("ETag", etag)
+
+		// Takes care of If-None-Match for us
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(spec))
+	}
+}
@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/yukitsune/maestro/pkg/api/handlers"
+	"github.com/yukitsune/maestro/pkg/api/responses"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// Present turns what the handlers respond with into the /v1 DTOs.
+// Anything without a /v1 shape is an error, so that nothing leaks out in its internal shape by accident.
+func Present(res any) (any, error) {
+	switch res := res.(type) {
+	case *responses.ErrorResource:
+		return &Error{res.Error}, nil
+
+	case []model.StreamingService:
+		return mapAll(res, newService), nil
+
+	case *handlers.Result[*model.Artist]:
+		return newResult(res, newArtist), nil
+	case *handlers.Result[*model.Album]:
+		return newResult(res, newAlbum), nil
+	case *handlers.Result[*model.Track]:
+		return newResult(res, newTrack), nil
+
+	case []*handlers.Result[*model.Artist]:
+		return mapAll(res, func(r *handlers.Result[*model.Artist]) *Result[Artist] { return newResult(r, newArtist) }), nil
+	case []*handlers.Result[*model.Album]:
+		return mapAll(res, func(r *handlers.Result[*model.Album]) *Result[Album] { return newResult(r, newAlbum) }), nil
+	case []*handlers.Result[*model.Track]:
+		return mapAll(res, func(r *handlers.Result[*model.Track]) *Result[Track] { return newResult(r, newTrack) }), nil
+
+	case []*handlers.BatchLinkResult:
+		results := make([]*BatchLinkResult, 0, len(res))
+		for _, r := range res {
+			result, err := newBatchLinkResult(r)
+			if err != nil {
+				return nil, err
+			}
+
+			results = append(results, result)
+		}
+
+		return results, nil
+
+	case *handlers.ShortLinkResponse:
+		return &ShortLink{res.Code, res.Link, string(res.Type), res.Id}, nil
+
+	case []*model.ThingStats:
+		return mapAll(res, func(s *model.ThingStats) *ThingStats { return &ThingStats{string(s.Type), s.Id, s.Count} }), nil
+
+	case []*model.ServiceStats:
+		return mapAll(res, func(s *model.ServiceStats) *ServiceStats {
+			return &ServiceStats{string(s.Service), s.Clicks, s.Resolutions}
+		}), nil
+
+	case []*handlers.TrendingItem:
+		return mapAll(res, newTrendingItem), nil
+	}
+
+	return nil, fmt.Errorf("%T has no v1 representation", res)
+}
+
+func newService(s model.StreamingService) *Service {
+	return &Service{
+		Key:     string(s.Key),
+		Name:    s.Name,
+		Enabled: s.Enabled,
+		State:   string(s.State),
+	}
+}
+
+func newArtist(a *model.Artist) Artist {
+	return Artist{
+		ArtistId:    a.ArtistId,
+		Name:        a.Name,
+		ArtworkLink: a.ArtworkLink,
+		Source:      string(a.Source),
+		Market:      string(a.Market),
+		Link:        a.Link,
+		ServiceId:   a.ServiceId,
+		Storefront:  a.Storefront,
+	}
+}
+
+func newAlbum(a *model.Album) Album {
+	return Album{
+		AlbumId:     a.AlbumId,
+		Upc:         a.Upc,
+		Name:        a.Name,
+		ArtistNames: names(a.ArtistNames),
+		ArtworkLink: a.ArtworkLink,
+		Source:      string(a.Source),
+		Market:      string(a.Market),
+		Link:        a.Link,
+		ServiceId:   a.ServiceId,
+		Storefront:  a.Storefront,
+	}
+}
+
+func newTrack(t *model.Track) Track {
+	return Track{
+		Isrc:        t.Isrc,
+		Name:        t.Name,
+		ArtistNames: names(t.ArtistNames),
+		AlbumName:   t.AlbumName,
+		ArtworkLink: t.ArtworkLink,
+		Source:      string(t.Source),
+		Market:      string(t.Market),
+		Link:        t.Link,
+		ServiceId:   t.ServiceId,
+		Storefront:  t.Storefront,
+	}
+}
+
+func newResult[T model.Thing, D Artist | Album | Track](res *handlers.Result[T], newItem func(T) D) *Result[D] {
+	result := &Result[D]{
+		Type:  string(res.Type),
+		Items: make([]D, 0, len(res.Items)),
+	}
+
+	for _, item := range res.Items {
+		result.Items = append(result.Items, newItem(item))
+	}
+
+	if len(res.Services) > 0 {
+		result.Services = make(map[string]ServiceStatus, len(res.Services))
+		for key, status := range res.Services {
+			result.Services[string(key)] = ServiceStatus{string(status.Status), string(status.ErrorClass)}
+		}
+	}
+
+	return result
+}
+
+func newBatchLinkResult(r *handlers.BatchLinkResult) (*BatchLinkResult, error) {
+	result := &BatchLinkResult{
+		Link:   r.Link,
+		Status: string(r.Status),
+		Error:  r.Error,
+	}
+
+	if r.Result != nil {
+		presented, err := Present(r.Result)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Result = presented
+	}
+
+	return result, nil
+}
+
+func newTrendingItem(item *handlers.TrendingItem) *TrendingItem {
+	links := make(map[string]string, len(item.Links))
+	for key, link := range item.Links {
+		links[string(key)] = link
+	}
+
+	return &TrendingItem{
+		Type:        string(item.Type),
+		Id:          item.Id,
+		Score:       item.Score,
+		Name:        item.Name,
+		ArtistNames: item.ArtistNames,
+		ArtworkLink: item.ArtworkLink,
+		ShareLink:   item.ShareLink,
+		Links:       links,
+	}
+}
+
+func mapAll[T any, D any](items []T, f func(T) D) []D {
+	mapped := make([]D, 0, len(items))
+	for _, item := range items {
+		mapped = append(mapped, f(item))
+	}
+
+	return mapped
+}
+
+// names are always a list, even if there aren't any
+func names(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+
+	return names
+}
@@ -0,0 +1,11 @@
+package cache
+
+import "context"
+
+// Cache is a key-value store for serialized values.
+// Entries may be evicted at any time, so a cache miss should never be treated as an error.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const diskEntryExtension = ".cache"
+
+type diskEntry struct {
+	name    string
+	size    int64
+	expires time.Time
+}
+
+type diskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	order    *list.List
+	entries  map[string]*list.Element
+	now      func() time.Time
+}
+
+// NewDiskCache creates a Cache which keeps each entry in its own file in dir, and holds at most maxBytes.
+// When full, the least recently used entries are evicted. Entries older than ttl are never returned.
+// Anything already in dir from a previous run is kept, oldest first in line for eviction.
+func NewDiskCache(dir string, maxBytes int64, ttl time.Duration) (Cache, error) {
+	return newDiskCache(dir, maxBytes, ttl, time.Now)
+}
+
+func newDiskCache(dir string, maxBytes int64, ttl time.Duration, now func() time.Time) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		now:      now,
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *diskCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[fileName(key)]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*diskEntry)
+	if c.now().After(entry.expires) {
+		return nil, false, c.remove(elem)
+	}
+
+	value, err := os.ReadFile(c.path(entry.name))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Someone's cleaned up the directory underneath us, which is fine
+		c.forget(elem)
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.order.MoveToFront(elem)
+	return value, true, nil
+}
+
+func (c *diskCache) Set(_ context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(value))
+	if size > c.maxBytes {
+		return nil
+	}
+
+	name := fileName(key)
+	if err := c.write(name, value); err != nil {
+		return err
+	}
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[name]; ok {
+		entry := elem.Value.(*diskEntry)
+		c.size += size - entry.size
+		entry.size = size
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[name] = c.order.PushFront(&diskEntry{name, size, expires})
+		c.size += size
+	}
+
+	return c.evict()
+}
+
+func (c *diskCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		if elem, ok := c.entries[fileName(key)]; ok {
+			errs = append(errs, c.remove(elem))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// load picks up the entries left behind by a previous run, the least recently written are evicted first
+func (c *diskCache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	var infos []fs.FileInfo
+	for _, dirEntry := range dirEntries {
+		// Writes which never finished aren't worth keeping
+		if strings.HasSuffix(dirEntry.Name(), ".tmp") {
+			_ = os.Remove(c.path(dirEntry.Name()))
+			continue
+		}
+
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), diskEntryExtension) {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+
+	for _, info := range infos {
+		entry := &diskEntry{info.Name(), info.Size(), info.ModTime().Add(c.ttl)}
+		c.entries[entry.name] = c.order.PushBack(entry)
+		c.size += entry.size
+	}
+
+	return c.evict()
+}
+
+// write replaces the file in one go, so that nothing ever reads half of it
+func (c *diskCache) write(name string, value []byte) error {
+	f, err := os.CreateTemp(c.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(name))
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
+
+func (c *diskCache) evict() error {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		if err := c.remove(c.order.Back()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *diskCache) remove(elem *list.Element) error {
+	entry := c.forget(elem)
+
+	err := os.Remove(c.path(entry.name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *diskCache) forget(elem *list.Element) *diskEntry {
+	entry := c.order.Remove(elem).(*diskEntry)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	return entry
+}
+
+func (c *diskCache) path(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+// fileName keeps keys from choosing where they're written to
+func fileName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:]) + diskEntryExtension
+}
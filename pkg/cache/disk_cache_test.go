@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiskCacheEvictsLeastRecentlyUsedWhenFull(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewDiskCache(t.TempDir(), 6, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("aa")))
+	require.NoError(t, c.Set(ctx, "b", []byte("bb")))
+	require.NoError(t, c.Set(ctx, "c", []byte("cc")))
+
+	// Touch a so that b becomes the least recently used
+	_, _, _ = c.Get(ctx, "a")
+	require.NoError(t, c.Set(ctx, "d", []byte("dd")))
+
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok, "least recently used entry should have been evicted")
+
+	for _, key := range []string{"a", "c", "d"} {
+		value, ok, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok, key)
+		assert.Equal(t, []byte(key+key), value)
+	}
+}
+
+func Test_DiskCacheRemovesEvictedFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := newDiskCache(dir, 4, time.Hour, time.Now)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("aaa")))
+	require.NoError(t, c.Set(ctx, "b", []byte("bbb")))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, int64(3), c.size)
+
+	// Too big to ever fit, so it's not worth writing
+	require.NoError(t, c.Set(ctx, "c", []byte("ccccc")))
+	_, ok, _ := c.Get(ctx, "b")
+	assert.True(t, ok)
+}
+
+func Test_DiskCacheKeepsEntriesBetweenRuns(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := NewDiskCache(dir, 100, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "a", []byte("a")))
+
+	c, err = NewDiskCache(dir, 100, time.Hour)
+	require.NoError(t, err)
+
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), value)
+}
+
+func Test_DiskCacheDoesNotReturnExpiredOrDeletedEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, err := newDiskCache(t.TempDir(), 100, time.Minute, func() time.Time { return now })
+	require.NoError(t, err)
+
+	_ = c.Set(ctx, "a", []byte("a"))
+	_ = c.Set(ctx, "b", []byte("b"))
+	require.NoError(t, c.Delete(ctx, "b", "doesn't exist"))
+
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.order.Len(), "expired entries should be removed")
+	assert.Equal(t, int64(0), c.size)
+}
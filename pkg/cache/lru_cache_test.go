@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LeastRecentlyUsedEntriesAreEvicted(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(2, time.Hour)
+
+	_ = c.Set(ctx, "a", []byte("a"))
+	_ = c.Set(ctx, "b", []byte("b"))
+
+	// Touch a so that b becomes the least recently used
+	_, _, _ = c.Get(ctx, "a")
+	_ = c.Set(ctx, "c", []byte("c"))
+
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok, "least recently used entry should have been evicted")
+
+	value, ok, _ := c.Get(ctx, "c")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("c"), value)
+}
+
+func Test_ExpiredEntriesAreNotReturned(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newLRUCache(10, time.Minute, func() time.Time { return now })
+
+	_ = c.Set(ctx, "a", []byte("a"))
+
+	now = now.Add(30 * time.Second)
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.order.Len(), "expired entries should be removed")
+}
+
+func Test_DeletedEntriesAreNotReturned(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(10, time.Hour)
+
+	_ = c.Set(ctx, "a", []byte("a"))
+	_ = c.Set(ctx, "b", []byte("b"))
+	_ = c.Delete(ctx, "a", "doesn't exist")
+
+	_, ok, _ := c.Get(ctx, "a")
+	assert.False(t, ok)
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.True(t, ok)
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisCache struct {
+	client    *redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewRedisCache creates a Cache backed by a Redis-protocol server, allowing multiple replicas to share entries.
+// Eviction is left up to the server, every entry is written with the given ttl.
+func NewRedisCache(client *redis.Client, keyPrefix string, ttl time.Duration) Cache {
+	return &redisCache{client, keyPrefix, ttl}
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.keyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value []byte) error {
+	return c.client.Set(ctx, c.keyPrefix+key, value, c.ttl).Err()
+}
+
+func (c *redisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		prefixedKeys[i] = c.keyPrefix + key
+	}
+
+	return c.client.Del(ctx, prefixedKeys...).Err()
+}
@@ -0,0 +1,40 @@
+package clients
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// baseURLTransport sends requests to a different base URL than the one they were created with.
+// This is useful for third-party clients which don't let us configure the base URL.
+type baseURLTransport struct {
+	baseURL *url.URL
+	next    http.RoundTripper
+}
+
+func (t *baseURLTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	req := r.Clone(r.Context())
+	req.URL.Scheme = t.baseURL.Scheme
+	req.URL.Host = t.baseURL.Host
+	req.Host = t.baseURL.Host
+
+	basePath := strings.TrimSuffix(t.baseURL.Path, "/")
+	if len(basePath) > 0 {
+		req.URL.Path = basePath + req.URL.Path
+		if len(req.URL.RawPath) > 0 {
+			req.URL.RawPath = basePath + req.URL.RawPath
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+func NewBaseURLTransport(baseURL string, next http.RoundTripper) (http.RoundTripper, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &baseURLTransport{u, next}, nil
+}
@@ -7,14 +7,19 @@ import (
 
 type basicAuthTransport struct {
 	token string
+	next  http.RoundTripper
 }
 
 func (t *basicAuthTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	value := fmt.Sprintf("Basic %s", t.token)
 	r.Header.Set("Authorization", value)
-	return http.DefaultTransport.RoundTrip(r)
+	return t.next.RoundTrip(r)
+}
+
+func NewBasicAuthTransport(token string, next http.RoundTripper) http.RoundTripper {
+	return &basicAuthTransport{token, next}
 }
 
 func NewClientWithBasicAuth(token string) *http.Client {
-	return &http.Client{Transport: &basicAuthTransport{token: token}}
+	return &http.Client{Transport: NewBasicAuthTransport(token, http.DefaultTransport)}
 }
@@ -7,14 +7,19 @@ import (
 
 type bearerAuthTransport struct {
 	token string
+	next  http.RoundTripper
 }
 
 func (t *bearerAuthTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	value := fmt.Sprintf("Bearer %s", t.token)
 	r.Header.Set("Authorization", value)
-	return http.DefaultTransport.RoundTrip(r)
+	return t.next.RoundTrip(r)
+}
+
+func NewBearerAuthTransport(token string, next http.RoundTripper) http.RoundTripper {
+	return &bearerAuthTransport{token, next}
 }
 
 func NewClientWithBearerAuth(token string) *http.Client {
-	return &http.Client{Transport: &bearerAuthTransport{token: token}}
+	return &http.Client{Transport: NewBearerAuthTransport(token, http.DefaultTransport)}
 }
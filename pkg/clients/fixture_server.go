@@ -0,0 +1,29 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+// RecordFixturesEnvKey is the environment variable used to switch fixture servers into recording mode
+const RecordFixturesEnvKey = "MAESTRO_RECORD_FIXTURES"
+
+// NewFixtureServer starts a httptest.Server which replays the fixtures in dir.
+// When the MAESTRO_RECORD_FIXTURES environment variable is set, requests are proxied to upstream instead,
+// and the fixtures in dir are re-recorded from the real responses.
+func NewFixtureServer(dir string, upstream string) (*httptest.Server, error) {
+	var handler http.Handler
+	if len(os.Getenv(RecordFixturesEnvKey)) > 0 {
+		recordingHandler, err := NewRecordingHandler(dir, upstream)
+		if err != nil {
+			return nil, err
+		}
+
+		handler = recordingHandler
+	} else {
+		handler = NewReplayHandler(dir)
+	}
+
+	return httptest.NewServer(handler), nil
+}
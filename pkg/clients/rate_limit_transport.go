@@ -0,0 +1,26 @@
+package clients
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+type rateLimitTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// NewRateLimitTransport makes every request wait for a token from the given limiter before it's sent.
+// The limiter should be shared by every client talking to the same API.
+func NewRateLimitTransport(limiter *rate.Limiter, next http.RoundTripper) http.RoundTripper {
+	return &rateLimitTransport{limiter, next}
+}
+
+func (t *rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(r.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,248 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Only these response headers are recorded, everything else is either irrelevant or sensitive
+var recordedHeaders = []string{"Content-Type", "Location", "Retry-After"}
+
+// Fixture is a recorded response to a single HTTP request
+type Fixture struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     map[string]string `json:",omitempty"`
+
+	// Body holds the response body when it is valid JSON, RawBody holds it otherwise.
+	// Keeping JSON as-is makes the fixture files a lot easier to read and edit by hand.
+	Body    json.RawMessage `json:",omitempty"`
+	RawBody string          `json:",omitempty"`
+}
+
+func (f *Fixture) key() string {
+	return fixtureKey(f.Method, f.URL)
+}
+
+func (f *Fixture) body() []byte {
+	if len(f.Body) > 0 {
+		return f.Body
+	}
+
+	return []byte(f.RawBody)
+}
+
+func (f *Fixture) response(r *http.Request) *http.Response {
+	header := make(http.Header)
+	for key, value := range f.Header {
+		header.Set(key, value)
+	}
+
+	body := f.body()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.StatusCode, http.StatusText(f.StatusCode)),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+func fixtureKey(method string, requestURI string) string {
+	return fmt.Sprintf("%s %s", method, requestURI)
+}
+
+var unsafeFileNameChars = regexp.MustCompile("[^A-Za-z0-9]+")
+
+func fixtureFileName(method string, requestURI string) string {
+	name := strings.Trim(unsafeFileNameChars.ReplaceAllString(requestURI, "_"), "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+
+	return fmt.Sprintf("%s_%s.json", strings.ToLower(method), name)
+}
+
+// recordingTransport sends requests upstream and saves each response as a fixture
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecordingTransport creates a http.RoundTripper which records every response into a fixture file in dir.
+// The fixtures can be played back using NewReplayingTransport or NewReplayHandler.
+func NewRecordingTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	return &recordingTransport{dir, next}
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		Method:     r.Method,
+		URL:        r.URL.RequestURI(),
+		StatusCode: res.StatusCode,
+		Header:     make(map[string]string),
+	}
+
+	for _, key := range recordedHeaders {
+		if value := res.Header.Get(key); len(value) > 0 {
+			fixture.Header[key] = value
+		}
+	}
+
+	if json.Valid(body) {
+		fixture.Body = body
+	} else {
+		fixture.RawBody = string(body)
+	}
+
+	err = writeFixture(t.dir, fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func writeFixture(dir string, fixture *Fixture) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	fixtureBytes, err := json.MarshalIndent(fixture, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, fixtureFileName(fixture.Method, fixture.URL))
+	return os.WriteFile(path, fixtureBytes, 0644)
+}
+
+// replayingTransport responds to requests with previously recorded fixtures, nothing is sent upstream
+type replayingTransport struct {
+	dir      string
+	once     sync.Once
+	fixtures map[string]*Fixture
+	err      error
+}
+
+// NewReplayingTransport creates a http.RoundTripper which responds with the fixtures found in dir.
+// Fixtures are matched on the request method, path and query, the host is ignored.
+func NewReplayingTransport(dir string) http.RoundTripper {
+	return &replayingTransport{dir: dir}
+}
+
+func (t *replayingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.once.Do(func() {
+		t.fixtures, t.err = readFixtures(t.dir)
+	})
+
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	fixture, ok := t.fixtures[fixtureKey(r.Method, r.URL.RequestURI())]
+	if !ok {
+		return nil, fmt.Errorf("no fixture recorded for %s %s", r.Method, r.URL.RequestURI())
+	}
+
+	return fixture.response(r), nil
+}
+
+func readFixtures(dir string) (map[string]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := make(map[string]*Fixture)
+	for _, path := range paths {
+		fixtureBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var fixture *Fixture
+		err = json.Unmarshal(fixtureBytes, &fixture)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+
+		fixtures[fixture.key()] = fixture
+	}
+
+	return fixtures, nil
+}
+
+// NewReplayHandler creates a http.Handler which serves the fixtures found in dir.
+// This is intended to be used with httptest.Server so that clients can be tested without a network connection.
+func NewReplayHandler(dir string) http.Handler {
+	transport := NewReplayingTransport(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := transport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		defer res.Body.Close()
+		for key, values := range res.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	})
+}
+
+// NewRecordingHandler creates a http.Handler which proxies requests to upstream, recording each response into dir.
+// Used in place of NewReplayHandler when the fixtures need to be re-recorded.
+func NewRecordingHandler(dir string, upstream string) (http.Handler, error) {
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	proxy.Transport = NewRecordingTransport(dir, http.DefaultTransport)
+
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = upstreamURL.Host
+
+		// Don't want compressed responses ending up in the fixtures
+		r.Header.Del("Accept-Encoding")
+	}
+
+	return proxy, nil
+}
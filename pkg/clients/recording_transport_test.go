@@ -0,0 +1,63 @@
+package clients_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+)
+
+func Test_RecordedResponsesCanBeReplayed(t *testing.T) {
+
+	// Arrange
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "secret=hunter2")
+			_, _ = w.Write([]byte(`{"name":"Cheap Trick"}`))
+		case "/limited":
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("slow down"))
+		}
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: clients.NewRecordingTransport(dir, http.DefaultTransport)}
+
+	_, err := recorder.Get(upstream.URL + "/json?q=1")
+	require.NoError(t, err)
+
+	_, err = recorder.Get(upstream.URL + "/limited")
+	require.NoError(t, err)
+
+	// Act
+	replayer := &http.Client{Transport: clients.NewReplayingTransport(dir)}
+	jsonRes, err := replayer.Get("https://example.com/json?q=1")
+	require.NoError(t, err)
+
+	limitedRes, err := replayer.Get("https://example.com/limited")
+	require.NoError(t, err)
+
+	_, err = replayer.Get("https://example.com/json?q=2")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, jsonRes.StatusCode)
+	assert.Equal(t, "application/json", jsonRes.Header.Get("Content-Type"))
+	assert.Empty(t, jsonRes.Header.Get("Set-Cookie"), "cookies should never be recorded")
+	jsonBody, _ := io.ReadAll(jsonRes.Body)
+	assert.JSONEq(t, `{"name":"Cheap Trick"}`, string(jsonBody))
+
+	assert.Equal(t, http.StatusTooManyRequests, limitedRes.StatusCode)
+	assert.Equal(t, "5", limitedRes.Header.Get("Retry-After"))
+	limitedBody, _ := io.ReadAll(limitedRes.Body)
+	assert.Equal(t, "slow down", string(limitedBody))
+
+	assert.Error(t, err, "requests which weren't recorded should fail")
+}
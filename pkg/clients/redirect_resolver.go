@@ -0,0 +1,195 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yukitsune/maestro/pkg/cache"
+)
+
+var (
+	// ErrHostNotAllowed is returned when asked to resolve a link which isn't from one of the known short link hosts
+	ErrHostNotAllowed = errors.New("host is not allowed")
+
+	// ErrNoRedirect is returned when a short link doesn't redirect anywhere, usually because it doesn't exist
+	ErrNoRedirect = errors.New("short link didn't redirect")
+
+	// ErrTooManyRedirects is returned when a short link redirects to other short links too many times
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrPrivateAddress is returned when a host resolves to an address we shouldn't be making requests to
+	ErrPrivateAddress = errors.New("refusing to connect to a private address")
+)
+
+// RedirectResolver finds out where short links point to
+type RedirectResolver interface {
+	// Resolve follows the redirects from the given short link, and returns the first link which isn't another short link.
+	// Only the short links themselves are requested, the link they resolve to is never fetched.
+	Resolve(ctx context.Context, link string) (string, error)
+
+	// CanResolve returns true if the link is from one of the short link hosts
+	CanResolve(link string) bool
+}
+
+type redirectResolver struct {
+	hosts   map[string]bool
+	maxHops int
+	timeout time.Duration
+	client  *http.Client
+	cache   cache.Cache
+}
+
+// NewRedirectResolver creates a RedirectResolver which only follows links from the given hosts.
+// The transport should be one which can't reach internal services, see NewPublicTransport.
+func NewRedirectResolver(hosts []string, maxHops int, timeout time.Duration, c cache.Cache, transport http.RoundTripper) RedirectResolver {
+	allowed := make(map[string]bool)
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+
+	client := &http.Client{
+		Transport: transport,
+
+		// Redirects are followed by hand so that every hop can be checked before it's requested
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &redirectResolver{allowed, maxHops, timeout, client, c}
+}
+
+func (r *redirectResolver) CanResolve(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return r.isAllowed(u)
+}
+
+func (r *redirectResolver) Resolve(ctx context.Context, link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	if !r.isAllowed(u) {
+		return "", fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Host)
+	}
+
+	key := fmt.Sprintf("short_link:%s", u)
+	if resolved, ok, _ := r.cache.Get(ctx, key); ok {
+		return string(resolved), nil
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	for hop := 0; hop < r.maxHops; hop++ {
+		next, err := r.next(ctx, u)
+		if err != nil {
+			return "", err
+		}
+
+		// Anything that isn't another short link is where we're going, and we've got no reason to fetch it
+		if !r.isAllowed(next) {
+			_ = r.cache.Set(ctx, key, []byte(next.String()))
+			return next.String(), nil
+		}
+
+		u = next
+	}
+
+	return "", fmt.Errorf("%w: stopped after %d hops", ErrTooManyRedirects, r.maxHops)
+}
+
+// next requests the given link and returns where it redirects to
+func (r *redirectResolver) next(ctx context.Context, u *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s responded with %s", ErrNoRedirect, u, res.Status)
+	}
+
+	return location, nil
+}
+
+func (r *redirectResolver) isAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	// Hosts with a port (or user info) are never legitimate short links
+	if len(u.Port()) > 0 || u.User != nil {
+		return false
+	}
+
+	return r.hosts[strings.ToLower(u.Hostname())]
+}
+
+// NewPublicTransport creates a transport which refuses to connect to loopback, private, or link-local addresses.
+// The check happens after the host is resolved, so DNS can't be used to sneak past it.
+func NewPublicTransport() http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// Carrier-grade NAT addresses aren't covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
@@ -0,0 +1,134 @@
+package clients_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+)
+
+var testShortLinkHosts = []string{"short.test", "other-short.test"}
+
+func Test_RedirectResolver(t *testing.T) {
+	testCases := []struct {
+		name        string
+		link        string
+		expect      string
+		expectErr   error
+		expectCalls int
+	}{
+		{
+			name:        "short link",
+			link:        "https://short.test/abc",
+			expect:      "https://music.test/track/123",
+			expectCalls: 1,
+		},
+		{
+			name:        "short link to another short link",
+			link:        "https://other-short.test/def",
+			expect:      "https://music.test/track/123",
+			expectCalls: 2,
+		},
+		{
+			name:        "relative redirect",
+			link:        "https://short.test/relative",
+			expect:      "https://music.test/track/123",
+			expectCalls: 2,
+		},
+		{
+			name:        "never-ending redirects",
+			link:        "https://short.test/loop",
+			expectErr:   clients.ErrTooManyRedirects,
+			expectCalls: 3,
+		},
+		{
+			name:        "short link which doesn't redirect",
+			link:        "https://short.test/missing",
+			expectErr:   clients.ErrNoRedirect,
+			expectCalls: 1,
+		},
+		{
+			name:      "unknown host",
+			link:      "http://169.254.169.254/latest/meta-data",
+			expectErr: clients.ErrHostNotAllowed,
+		},
+		{
+			name:      "known host mentioned in the query",
+			link:      "http://localhost/?next=https://short.test/abc",
+			expectErr: clients.ErrHostNotAllowed,
+		},
+		{
+			name:      "known host with a port",
+			link:      "https://short.test:8080/abc",
+			expectErr: clients.ErrHostNotAllowed,
+		},
+		{
+			name:      "known host with user info",
+			link:      "https://user@short.test/abc",
+			expectErr: clients.ErrHostNotAllowed,
+		},
+		{
+			name:      "unsupported scheme",
+			link:      "file://short.test/etc/passwd",
+			expectErr: clients.ErrHostNotAllowed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			transport := clients.NewRedirectingTransport(map[string]string{
+				"https://short.test/abc":        "https://music.test/track/123",
+				"https://other-short.test/def":  "https://short.test/abc",
+				"https://short.test/relative":   "/abc",
+				"https://short.test/loop":       "https://other-short.test/loop",
+				"https://other-short.test/loop": "https://short.test/loop",
+			})
+
+			resolver := clients.NewRedirectResolver(testShortLinkHosts, 3, time.Second, cache.NewLRUCache(10, time.Minute), transport)
+
+			res, err := resolver.Resolve(context.Background(), testCase.link)
+			if testCase.expectErr != nil {
+				assert.ErrorIs(t, err, testCase.expectErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expect, res)
+			}
+
+			assert.Equal(t, testCase.expectCalls, transport.Requests())
+		})
+	}
+}
+
+func Test_RedirectResolverCachesResolvedLinks(t *testing.T) {
+	transport := clients.NewRedirectingTransport(map[string]string{
+		"https://short.test/abc": "https://music.test/track/123",
+	})
+
+	resolver := clients.NewRedirectResolver(testShortLinkHosts, 3, time.Second, cache.NewLRUCache(10, time.Minute), transport)
+
+	for i := 0; i < 3; i++ {
+		res, err := resolver.Resolve(context.Background(), "https://short.test/abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://music.test/track/123", res)
+	}
+
+	assert.Equal(t, 1, transport.Requests())
+}
+
+func Test_PublicTransportRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{Transport: clients.NewPublicTransport()}
+
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, clients.ErrPrivateAddress)
+}
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// RedirectingTransport pretends to be a set of short link services for tests, redirecting each of the known links
+// to wherever they point to. Anything else gets an empty 200 response.
+type RedirectingTransport struct {
+	redirects map[string]string
+	requests  atomic.Int32
+}
+
+func NewRedirectingTransport(redirects map[string]string) *RedirectingTransport {
+	return &RedirectingTransport{redirects: redirects}
+}
+
+func (t *RedirectingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    r,
+	}
+
+	if location, ok := t.redirects[r.URL.String()]; ok {
+		res.StatusCode = http.StatusFound
+		res.Status = "302 Found"
+		res.Header.Set("Location", location)
+	}
+
+	return res, nil
+}
+
+// Requests returns how many requests have been made
+func (t *RedirectingTransport) Requests() int {
+	return int(t.requests.Load())
+}
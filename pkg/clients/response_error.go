@@ -0,0 +1,20 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ResponseError is returned when an API responds with an unexpected status code
+type ResponseError struct {
+	StatusCode int
+	Status     string
+}
+
+func NewResponseError(res *http.Response) *ResponseError {
+	return &ResponseError{res.StatusCode, res.Status}
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("api responded with %s", e.Status)
+}
@@ -0,0 +1,143 @@
+package clients
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const baseBackoff = 250 * time.Millisecond
+
+type retryTransport struct {
+	maxRetries int
+	maxBackoff time.Duration
+	onThrottle func(*http.Response)
+	next       http.RoundTripper
+}
+
+// NewRetryTransport retries requests which fail with 429 Too Many Requests or a 5xx status.
+// The Retry-After header is honoured when it's present, otherwise the backoff is exponential. Both have jitter added.
+// If the API asks us to wait longer than maxBackoff the response is returned as-is.
+// onThrottle is called for every 429 response, it may be nil.
+func NewRetryTransport(maxRetries int, maxBackoff time.Duration, onThrottle func(*http.Response), next http.RoundTripper) http.RoundTripper {
+	return &retryTransport{maxRetries, maxBackoff, onThrottle, next}
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(r)
+		if err != nil || !shouldRetry(res) {
+			return res, err
+		}
+
+		if res.StatusCode == http.StatusTooManyRequests && t.onThrottle != nil {
+			t.onThrottle(res)
+		}
+
+		if attempt >= t.maxRetries {
+			return res, nil
+		}
+
+		wait, ok := t.backoff(res, attempt)
+		if !ok {
+			return res, nil
+		}
+
+		// We can only send the request again if we can get a fresh copy of the body
+		next, ok := rewind(r)
+		if !ok {
+			return res, nil
+		}
+
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		case <-timer.C:
+		}
+
+		r = next
+	}
+}
+
+func shouldRetry(res *http.Response) bool {
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+}
+
+func (t *retryTransport) backoff(res *http.Response, attempt int) (time.Duration, bool) {
+	if retryAfter, ok := ParseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		if retryAfter > t.maxBackoff {
+			return 0, false
+		}
+
+		// Add up to 10% so that everyone waiting on the same Retry-After doesn't come back at once
+		return retryAfter + jitter(retryAfter/10), true
+	}
+
+	backoff := baseBackoff << attempt
+	if backoff <= 0 || backoff > t.maxBackoff {
+		backoff = t.maxBackoff
+	}
+
+	return jitter(backoff), true
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func rewind(r *http.Request) (*http.Request, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return r, true
+	}
+
+	if r.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	next := r.Clone(r.Context())
+	next.Body = body
+	return next, true
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which can either be a number of seconds or a HTTP date
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := date.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait, true
+}
@@ -0,0 +1,128 @@
+package clients_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+)
+
+func Test_RetryTransport(t *testing.T) {
+	testCases := []struct {
+		name           string
+		responses      []int
+		retryAfter     string
+		expectStatus   int
+		expectAttempts int32
+		expectThrottle int32
+	}{
+		{
+			name:           "successful requests aren't retried",
+			responses:      []int{http.StatusOK},
+			expectStatus:   http.StatusOK,
+			expectAttempts: 1,
+		},
+		{
+			name:           "client errors aren't retried",
+			responses:      []int{http.StatusNotFound},
+			expectStatus:   http.StatusNotFound,
+			expectAttempts: 1,
+		},
+		{
+			name:           "too many requests is retried",
+			responses:      []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:     "0",
+			expectStatus:   http.StatusOK,
+			expectAttempts: 3,
+			expectThrottle: 2,
+		},
+		{
+			name:           "server errors are retried",
+			responses:      []int{http.StatusBadGateway, http.StatusOK},
+			expectStatus:   http.StatusOK,
+			expectAttempts: 2,
+		},
+		{
+			name:           "gives up after max retries",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			expectStatus:   http.StatusServiceUnavailable,
+			expectAttempts: 3,
+		},
+		{
+			name:           "gives up when retry-after is longer than max backoff",
+			responses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:     "120",
+			expectStatus:   http.StatusTooManyRequests,
+			expectAttempts: 1,
+			expectThrottle: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+
+			// Arrange
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				body, _ := io.ReadAll(r.Body)
+				assert.Equal(t, "grant_type=client_credentials", string(body))
+
+				if len(testCase.retryAfter) > 0 {
+					w.Header().Set("Retry-After", testCase.retryAfter)
+				}
+
+				w.WriteHeader(testCase.responses[n-1])
+			}))
+			defer srv.Close()
+
+			var throttled int32
+			onThrottle := func(*http.Response) {
+				atomic.AddInt32(&throttled, 1)
+			}
+
+			client := &http.Client{Transport: clients.NewRetryTransport(2, 10*time.Millisecond, onThrottle, http.DefaultTransport)}
+
+			// Act
+			res, err := client.Post(srv.URL, "application/x-www-form-urlencoded", strings.NewReader("grant_type=client_credentials"))
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectStatus, res.StatusCode)
+			assert.Equal(t, testCase.expectAttempts, atomic.LoadInt32(&attempts))
+			assert.Equal(t, testCase.expectThrottle, atomic.LoadInt32(&throttled))
+		})
+	}
+}
+
+func Test_ParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expect   time.Duration
+		expectOk bool
+	}{
+		{value: "", expectOk: false},
+		{value: "30", expect: 30 * time.Second, expectOk: true},
+		{value: "-1", expectOk: false},
+		{value: "Thu, 01 Jun 2023 12:00:45 GMT", expect: 45 * time.Second, expectOk: true},
+		{value: "Thu, 01 Jun 2023 11:00:00 GMT", expect: 0, expectOk: true},
+		{value: "soon", expectOk: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.value, func(t *testing.T) {
+			wait, ok := clients.ParseRetryAfter(testCase.value, now)
+			assert.Equal(t, testCase.expectOk, ok)
+			assert.Equal(t, testCase.expect, wait)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type timeoutTransport struct {
+	timeout time.Duration
+	next    http.RoundTripper
+}
+
+// NewTimeoutTransport limits how long a request can take, including reading the response body.
+// This is the same as http.Client.Timeout, but it can be shared by clients which we don't create ourselves.
+func NewTimeoutTransport(timeout time.Duration, next http.RoundTripper) http.RoundTripper {
+	return &timeoutTransport{timeout, next}
+}
+
+func (t *timeoutTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.next.RoundTrip(r)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), t.timeout)
+	res, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The body can't be read after the context is cancelled, so wait for the caller to close it
+	res.Body = &cancelOnClose{res.Body, cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
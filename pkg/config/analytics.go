@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+type Analytics interface {
+	Enabled() bool
+	PrivacyMode() bool
+	QueueSize() int
+}
+
+type analyticsViperConfig struct {
+	v *viper.Viper
+}
+
+func NewAnalyticsViperConfig(v *viper.Viper) Analytics {
+	v.SetDefault("analytics.enabled", true)
+	v.SetDefault("analytics.privacy_mode", true)
+	v.SetDefault("analytics.queue_size", 1000)
+
+	return &analyticsViperConfig{v}
+}
+
+// Enabled is whether clicks and link resolutions are recorded at all
+func (c *analyticsViperConfig) Enabled() bool {
+	return c.v.GetBool("analytics.enabled")
+}
+
+// PrivacyMode stops IP addresses and user agents from being stored, only the daily counts are kept
+func (c *analyticsViperConfig) PrivacyMode() bool {
+	return c.v.GetBool("analytics.privacy_mode")
+}
+
+// QueueSize is how many events can be waiting to be stored, anything past that is dropped
+func (c *analyticsViperConfig) QueueSize() int {
+	return c.v.GetInt("analytics.queue_size")
+}
@@ -1,21 +1,36 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type API interface {
 	Port() int
 	AssetsDirectory() string
+	PublicURL() string
+	MaxBatchLinks() int
+	BatchConcurrency() int
+	BatchTimeout() time.Duration
+	Artwork() Artwork
 }
 
 type apiViperConfig struct {
-	v *viper.Viper
+	v       *viper.Viper
+	artwork Artwork
 }
 
 func NewApiViperConfig(v *viper.Viper) API {
 	v.SetDefault("api.port", 8182)
 	v.SetDefault("api.assets_dir", "/assets")
+	v.SetDefault("api.public_url", "http://localhost:8182")
+	v.SetDefault("api.batch.max_links", 50)
+	v.SetDefault("api.batch.concurrency", 4)
+	v.SetDefault("api.batch.timeout", 10*time.Second)
 
-	return &apiViperConfig{v}
+	return &apiViperConfig{v, NewArtworkViperConfig(v)}
 }
 
 func (c *apiViperConfig) Port() int {
@@ -25,3 +40,28 @@ func (c *apiViperConfig) Port() int {
 func (c *apiViperConfig) AssetsDirectory() string {
 	return c.v.GetString("api.assets_dir")
 }
+
+// PublicURL is where the API can be reached from the outside, it's used to build links back to the API
+func (c *apiViperConfig) PublicURL() string {
+	return strings.TrimSuffix(c.v.GetString("api.public_url"), "/")
+}
+
+// MaxBatchLinks is the most links which can be resolved in a single batch request
+func (c *apiViperConfig) MaxBatchLinks() int {
+	return c.v.GetInt("api.batch.max_links")
+}
+
+// BatchConcurrency is how many links from a single batch request are resolved at once
+func (c *apiViperConfig) BatchConcurrency() int {
+	return c.v.GetInt("api.batch.concurrency")
+}
+
+// BatchTimeout is how long a single batch request can take, links which haven't been looked up by then are failed.
+// It needs to be shorter than the server's write timeout, otherwise nobody will be around to hear about it.
+func (c *apiViperConfig) BatchTimeout() time.Duration {
+	return c.v.GetDuration("api.batch.timeout")
+}
+
+func (c *apiViperConfig) Artwork() Artwork {
+	return c.artwork
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 	"github.com/yukitsune/maestro/pkg/model"
 )
@@ -11,17 +13,30 @@ type AppleMusic interface {
 	Enabled() bool
 	LogoFileName() string
 	Token() string
+	ApiUrl() string
+	Timeout() time.Duration
+	RateLimit() RateLimit
+	CircuitBreaker() CircuitBreaker
 }
 
 type appleMusicViperConfig struct {
-	v *viper.Viper
+	v              *viper.Viper
+	rateLimit      RateLimit
+	circuitBreaker CircuitBreaker
 }
 
 func NewAppleMusicViperConfig(v *viper.Viper) AppleMusic {
 	v.SetDefault("services.apple_music.enabled", true)
 	v.SetDefault("services.apple_music.logo_file_name", "apple_music.png")
+	v.SetDefault("services.apple_music.api_url", "https://api.music.apple.com")
+	v.SetDefault("services.apple_music.timeout", 15*time.Second)
 
-	return &appleMusicViperConfig{v}
+	return &appleMusicViperConfig{
+		v,
+		// Todo: Update this to use sub once viper bug is fixed
+		NewRateLimitViperConfig(v, "apple_music", 20, 20),
+		NewCircuitBreakerViperConfig(v, "apple_music"),
+	}
 }
 
 func (c *appleMusicViperConfig) Type() model.StreamingServiceType {
@@ -47,3 +62,20 @@ func (c *appleMusicViperConfig) Token() string {
 
 	return c.v.GetString("services.apple_music.token")
 }
+
+func (c *appleMusicViperConfig) ApiUrl() string {
+	return c.v.GetString("services.apple_music.api_url")
+}
+
+// Timeout is how long a request to the service can take, including any retries
+func (c *appleMusicViperConfig) Timeout() time.Duration {
+	return c.v.GetDuration("services.apple_music.timeout")
+}
+
+func (c *appleMusicViperConfig) RateLimit() RateLimit {
+	return c.rateLimit
+}
+
+func (c *appleMusicViperConfig) CircuitBreaker() CircuitBreaker {
+	return c.circuitBreaker
+}
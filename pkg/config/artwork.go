@@ -0,0 +1,40 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Artwork interface {
+	CacheDirectory() string
+	CacheSize() int64
+	CacheTTL() time.Duration
+}
+
+type artworkViperConfig struct {
+	v *viper.Viper
+}
+
+func NewArtworkViperConfig(v *viper.Viper) Artwork {
+	v.SetDefault("api.artwork.cache_dir", "/var/cache/maestro/artwork")
+	v.SetDefault("api.artwork.cache_size", 512<<20)
+	v.SetDefault("api.artwork.cache_ttl", 7*24*time.Hour)
+
+	return &artworkViperConfig{v}
+}
+
+// CacheDirectory is where resized artwork is kept on disk
+func (c *artworkViperConfig) CacheDirectory() string {
+	return c.v.GetString("api.artwork.cache_dir")
+}
+
+// CacheSize is how many bytes of resized artwork are kept before the least recently used is evicted
+func (c *artworkViperConfig) CacheSize() int64 {
+	return c.v.GetInt64("api.artwork.cache_size")
+}
+
+// CacheTTL is how long resized artwork is kept for, in case the services change it
+func (c *artworkViperConfig) CacheTTL() time.Duration {
+	return c.v.GetDuration("api.artwork.cache_ttl")
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type CircuitBreaker interface {
+	FailureRatio() float64
+	MinRequests() int
+	Interval() time.Duration
+	CoolDown() time.Duration
+}
+
+type circuitBreakerViperConfig struct {
+	v      *viper.Viper
+	prefix string
+}
+
+// NewCircuitBreakerViperConfig reads the circuit breaker settings for the service with the given config key,
+// e.g. "deezer" reads from services.deezer.circuit_breaker
+func NewCircuitBreakerViperConfig(v *viper.Viper, serviceKey string) CircuitBreaker {
+	prefix := fmt.Sprintf("services.%s.circuit_breaker", serviceKey)
+	v.SetDefault(prefix+".failure_ratio", 0.5)
+	v.SetDefault(prefix+".min_requests", 5)
+	v.SetDefault(prefix+".interval", time.Minute)
+	v.SetDefault(prefix+".cool_down", 30*time.Second)
+
+	return &circuitBreakerViperConfig{v, prefix}
+}
+
+func (c *circuitBreakerViperConfig) FailureRatio() float64 {
+	return c.v.GetFloat64(c.prefix + ".failure_ratio")
+}
+
+func (c *circuitBreakerViperConfig) MinRequests() int {
+	return c.v.GetInt(c.prefix + ".min_requests")
+}
+
+func (c *circuitBreakerViperConfig) Interval() time.Duration {
+	return c.v.GetDuration(c.prefix + ".interval")
+}
+
+func (c *circuitBreakerViperConfig) CoolDown() time.Duration {
+	return c.v.GetDuration(c.prefix + ".cool_down")
+}
@@ -11,25 +11,31 @@ type Config interface {
 	Database() Database
 	Logging() Logging
 	Services() Services
+	Workers() Workers
+	Analytics() Analytics
 	Debug() string
 }
 
 type viperConfig struct {
-	v        *viper.Viper
-	api      API
-	database Database
-	logging  Logging
-	services Services
+	v         *viper.Viper
+	api       API
+	database  Database
+	logging   Logging
+	services  Services
+	workers   Workers
+	analytics Analytics
 }
 
 func NewViperConfig(v *viper.Viper) Config {
 	return &viperConfig{
 		v: v,
 		// Todo: Update this to use sub once viper bug is fixed
-		api:      NewApiViperConfig(v),
-		database: NewDatabaseViperConfig(v),
-		logging:  NewLoggingViperConfig(v),
-		services: NewServicesViperConfig(v)}
+		api:       NewApiViperConfig(v),
+		database:  NewDatabaseViperConfig(v),
+		logging:   NewLoggingViperConfig(v),
+		services:  NewServicesViperConfig(v),
+		workers:   NewWorkersViperConfig(v),
+		analytics: NewAnalyticsViperConfig(v)}
 }
 
 func (c *viperConfig) API() API {
@@ -48,6 +54,14 @@ func (c *viperConfig) Services() Services {
 	return c.services
 }
 
+func (c *viperConfig) Workers() Workers {
+	return c.workers
+}
+
+func (c *viperConfig) Analytics() Analytics {
+	return c.analytics
+}
+
 func (c *viperConfig) Debug() string {
 	return fmt.Sprintf("%#v", c.v.AllSettings())
 }
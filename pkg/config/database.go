@@ -1,18 +1,43 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Database interface {
 	Uri() string
 	Name() string
+	Cache() Cache
+}
+
+type Cache interface {
+	Enabled() bool
+	Size() int
+	TTL() time.Duration
+	Redis() Redis
+}
+
+type Redis interface {
+	Enabled() bool
+	Address() string
+	Password() string
+	Database() int
+	KeyPrefix() string
 }
 
 type databaseViperConfig struct {
-	v *viper.Viper
+	v     *viper.Viper
+	cache Cache
 }
 
 func NewDatabaseViperConfig(v *viper.Viper) Database {
-	return &databaseViperConfig{v}
+	return &databaseViperConfig{
+		v,
+		// Todo: Update this to use sub once viper bug is fixed
+		NewCacheViperConfig(v),
+	}
 }
 
 func (c *databaseViperConfig) Uri() string {
@@ -30,3 +55,75 @@ func (c *databaseViperConfig) Name() string {
 
 	return c.v.GetString("database.name")
 }
+
+func (c *databaseViperConfig) Cache() Cache {
+	return c.cache
+}
+
+type cacheViperConfig struct {
+	v     *viper.Viper
+	redis Redis
+}
+
+func NewCacheViperConfig(v *viper.Viper) Cache {
+	v.SetDefault("database.cache.enabled", true)
+	v.SetDefault("database.cache.size", 10000)
+	v.SetDefault("database.cache.ttl", time.Hour)
+
+	return &cacheViperConfig{
+		v,
+		// Todo: Update this to use sub once viper bug is fixed
+		NewRedisViperConfig(v),
+	}
+}
+
+func (c *cacheViperConfig) Enabled() bool {
+	return c.v.GetBool("database.cache.enabled")
+}
+
+func (c *cacheViperConfig) Size() int {
+	return c.v.GetInt("database.cache.size")
+}
+
+func (c *cacheViperConfig) TTL() time.Duration {
+	return c.v.GetDuration("database.cache.ttl")
+}
+
+func (c *cacheViperConfig) Redis() Redis {
+	return c.redis
+}
+
+type redisViperConfig struct {
+	v *viper.Viper
+}
+
+func NewRedisViperConfig(v *viper.Viper) Redis {
+	v.SetDefault("database.cache.redis.db", 0)
+	v.SetDefault("database.cache.redis.key_prefix", "maestro:")
+
+	return &redisViperConfig{v}
+}
+
+func (c *redisViperConfig) Enabled() bool {
+	return c.v.IsSet("database.cache.redis.address")
+}
+
+func (c *redisViperConfig) Address() string {
+	if !c.Enabled() {
+		panic("redis not enabled")
+	}
+
+	return c.v.GetString("database.cache.redis.address")
+}
+
+func (c *redisViperConfig) Password() string {
+	return c.v.GetString("database.cache.redis.password")
+}
+
+func (c *redisViperConfig) Database() int {
+	return c.v.GetInt("database.cache.redis.db")
+}
+
+func (c *redisViperConfig) KeyPrefix() string {
+	return c.v.GetString("database.cache.redis.key_prefix")
+}
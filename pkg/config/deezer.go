@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 	"github.com/yukitsune/maestro/pkg/model"
 )
@@ -10,17 +12,30 @@ type Deezer interface {
 	Name() string
 	Enabled() bool
 	LogoFileName() string
+	ApiUrl() string
+	Timeout() time.Duration
+	RateLimit() RateLimit
+	CircuitBreaker() CircuitBreaker
 }
 
 type deezerViperConfig struct {
-	v *viper.Viper
+	v              *viper.Viper
+	rateLimit      RateLimit
+	circuitBreaker CircuitBreaker
 }
 
 func NewDeezerViperConfig(v *viper.Viper) Deezer {
 	v.SetDefault("services.deezer.enabled", true)
 	v.SetDefault("services.deezer.logo_file_name", "deezer.png")
+	v.SetDefault("services.deezer.api_url", "https://api.deezer.com")
+	v.SetDefault("services.deezer.timeout", 15*time.Second)
 
-	return &deezerViperConfig{v}
+	return &deezerViperConfig{
+		v,
+		// Todo: Update this to use sub once viper bug is fixed
+		NewRateLimitViperConfig(v, "deezer", 10, 10),
+		NewCircuitBreakerViperConfig(v, "deezer"),
+	}
 }
 
 func (c *deezerViperConfig) Type() model.StreamingServiceType {
@@ -38,3 +53,20 @@ func (c *deezerViperConfig) Enabled() bool {
 func (c *deezerViperConfig) LogoFileName() string {
 	return c.v.GetString("services.deezer.logo_file_name")
 }
+
+func (c *deezerViperConfig) ApiUrl() string {
+	return c.v.GetString("services.deezer.api_url")
+}
+
+// Timeout is how long a request to the service can take, including any retries
+func (c *deezerViperConfig) Timeout() time.Duration {
+	return c.v.GetDuration("services.deezer.timeout")
+}
+
+func (c *deezerViperConfig) RateLimit() RateLimit {
+	return c.rateLimit
+}
+
+func (c *deezerViperConfig) CircuitBreaker() CircuitBreaker {
+	return c.circuitBreaker
+}
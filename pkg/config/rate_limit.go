@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type RateLimit interface {
+	RequestsPerSecond() float64
+	Burst() int
+	MaxRetries() int
+	MaxBackoff() time.Duration
+}
+
+type rateLimitViperConfig struct {
+	v      *viper.Viper
+	prefix string
+}
+
+// NewRateLimitViperConfig reads the rate limit settings for the service with the given config key,
+// e.g. "deezer" reads from services.deezer.rate_limit
+func NewRateLimitViperConfig(v *viper.Viper, serviceKey string, requestsPerSecond float64, burst int) RateLimit {
+	prefix := fmt.Sprintf("services.%s.rate_limit", serviceKey)
+	v.SetDefault(prefix+".requests_per_second", requestsPerSecond)
+	v.SetDefault(prefix+".burst", burst)
+	v.SetDefault(prefix+".max_retries", 3)
+	v.SetDefault(prefix+".max_backoff", 10*time.Second)
+
+	return &rateLimitViperConfig{v, prefix}
+}
+
+func (c *rateLimitViperConfig) RequestsPerSecond() float64 {
+	return c.v.GetFloat64(c.prefix + ".requests_per_second")
+}
+
+func (c *rateLimitViperConfig) Burst() int {
+	return c.v.GetInt(c.prefix + ".burst")
+}
+
+func (c *rateLimitViperConfig) MaxRetries() int {
+	return c.v.GetInt(c.prefix + ".max_retries")
+}
+
+func (c *rateLimitViperConfig) MaxBackoff() time.Duration {
+	return c.v.GetDuration(c.prefix + ".max_backoff")
+}
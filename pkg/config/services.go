@@ -16,6 +16,7 @@ type Services interface {
 	AppleMusic() AppleMusic
 	Spotify() Spotify
 	Deezer() Deezer
+	ShortLinks() ShortLinks
 	AsMap() map[model.StreamingServiceType]Service
 }
 
@@ -23,6 +24,7 @@ type servicesViperConfig struct {
 	appleMusic AppleMusic
 	spotify    Spotify
 	deezer     Deezer
+	shortLinks ShortLinks
 }
 
 func NewServicesViperConfig(v *viper.Viper) Services {
@@ -31,6 +33,7 @@ func NewServicesViperConfig(v *viper.Viper) Services {
 		appleMusic: NewAppleMusicViperConfig(v),
 		spotify:    NewSpotifyViperConfig(v),
 		deezer:     NewDeezerViperConfig(v),
+		shortLinks: NewShortLinksViperConfig(v),
 	}
 }
 
@@ -46,6 +49,10 @@ func (s *servicesViperConfig) Deezer() Deezer {
 	return s.deezer
 }
 
+func (s *servicesViperConfig) ShortLinks() ShortLinks {
+	return s.shortLinks
+}
+
 func (s *servicesViperConfig) AsMap() map[model.StreamingServiceType]Service {
 	configs := make(map[model.StreamingServiceType]Service)
 	configs[model.AppleMusicStreamingService] = s.AppleMusic()
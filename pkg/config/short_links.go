@@ -0,0 +1,47 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type ShortLinks interface {
+	MaxHops() int
+	Timeout() time.Duration
+	CacheSize() int
+	CacheTTL() time.Duration
+}
+
+type shortLinksViperConfig struct {
+	v *viper.Viper
+}
+
+func NewShortLinksViperConfig(v *viper.Viper) ShortLinks {
+	v.SetDefault("services.short_links.max_hops", 5)
+	v.SetDefault("services.short_links.timeout", 5*time.Second)
+	v.SetDefault("services.short_links.cache_size", 10000)
+	v.SetDefault("services.short_links.cache_ttl", 24*time.Hour)
+
+	return &shortLinksViperConfig{v}
+}
+
+// MaxHops is the most redirects we'll follow from a short link before giving up
+func (c *shortLinksViperConfig) MaxHops() int {
+	return c.v.GetInt("services.short_links.max_hops")
+}
+
+// Timeout is how long we'll spend following a short link, including every redirect
+func (c *shortLinksViperConfig) Timeout() time.Duration {
+	return c.v.GetDuration("services.short_links.timeout")
+}
+
+// CacheSize is how many resolved short links are remembered
+func (c *shortLinksViperConfig) CacheSize() int {
+	return c.v.GetInt("services.short_links.cache_size")
+}
+
+// CacheTTL is how long a resolved short link is remembered for
+func (c *shortLinksViperConfig) CacheTTL() time.Duration {
+	return c.v.GetDuration("services.short_links.cache_ttl")
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 	"github.com/yukitsune/maestro/pkg/model"
 )
@@ -12,17 +14,32 @@ type Spotify interface {
 	LogoFileName() string
 	ClientId() string
 	ClientSecret() string
+	ApiUrl() string
+	AccountsUrl() string
+	Timeout() time.Duration
+	RateLimit() RateLimit
+	CircuitBreaker() CircuitBreaker
 }
 
 type spotifyViperConfig struct {
-	v *viper.Viper
+	v              *viper.Viper
+	rateLimit      RateLimit
+	circuitBreaker CircuitBreaker
 }
 
 func NewSpotifyViperConfig(v *viper.Viper) Spotify {
 	v.SetDefault("services.spotify.enabled", true)
 	v.SetDefault("services.spotify.logo_file_name", "spotify.png")
+	v.SetDefault("services.spotify.api_url", "https://api.spotify.com")
+	v.SetDefault("services.spotify.timeout", 15*time.Second)
+	v.SetDefault("services.spotify.accounts_url", "https://accounts.spotify.com")
 
-	return &spotifyViperConfig{v}
+	return &spotifyViperConfig{
+		v,
+		// Todo: Update this to use sub once viper bug is fixed
+		NewRateLimitViperConfig(v, "spotify", 10, 10),
+		NewCircuitBreakerViperConfig(v, "spotify"),
+	}
 }
 
 func (c *spotifyViperConfig) Type() model.StreamingServiceType {
@@ -56,3 +73,24 @@ func (c *spotifyViperConfig) ClientSecret() string {
 
 	return c.v.GetString("services.spotify.client_secret")
 }
+
+func (c *spotifyViperConfig) ApiUrl() string {
+	return c.v.GetString("services.spotify.api_url")
+}
+
+func (c *spotifyViperConfig) AccountsUrl() string {
+	return c.v.GetString("services.spotify.accounts_url")
+}
+
+// Timeout is how long a request to the service can take, including any retries
+func (c *spotifyViperConfig) Timeout() time.Duration {
+	return c.v.GetDuration("services.spotify.timeout")
+}
+
+func (c *spotifyViperConfig) RateLimit() RateLimit {
+	return c.rateLimit
+}
+
+func (c *spotifyViperConfig) CircuitBreaker() CircuitBreaker {
+	return c.circuitBreaker
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Workers interface {
+	Resolve() ResolveWorker
+	LinkHealth() LinkHealthWorker
+	Trending() TrendingWorker
+}
+
+type ResolveWorker interface {
+	Enabled() bool
+	Interval() time.Duration
+	Concurrency() int
+	BatchSize() int
+	RetryInterval() time.Duration
+}
+
+type LinkHealthWorker interface {
+	Enabled() bool
+	Interval() time.Duration
+	Concurrency() int
+	BatchSize() int
+	VerifyInterval() time.Duration
+}
+
+type TrendingWorker interface {
+	Enabled() bool
+	Interval() time.Duration
+	Limit() int
+}
+
+type workersViperConfig struct {
+	resolve    ResolveWorker
+	linkHealth LinkHealthWorker
+	trending   TrendingWorker
+}
+
+func NewWorkersViperConfig(v *viper.Viper) Workers {
+	return &workersViperConfig{
+		// Todo: Update this to use sub once viper bug is fixed
+		NewResolveWorkerViperConfig(v),
+		NewLinkHealthWorkerViperConfig(v),
+		NewTrendingWorkerViperConfig(v),
+	}
+}
+
+func (c *workersViperConfig) Resolve() ResolveWorker {
+	return c.resolve
+}
+
+func (c *workersViperConfig) LinkHealth() LinkHealthWorker {
+	return c.linkHealth
+}
+
+func (c *workersViperConfig) Trending() TrendingWorker {
+	return c.trending
+}
+
+type resolveWorkerViperConfig struct {
+	v *viper.Viper
+}
+
+func NewResolveWorkerViperConfig(v *viper.Viper) ResolveWorker {
+	v.SetDefault("workers.resolve.enabled", true)
+	v.SetDefault("workers.resolve.interval", time.Hour)
+	v.SetDefault("workers.resolve.concurrency", 2)
+	v.SetDefault("workers.resolve.batch_size", 100)
+	v.SetDefault("workers.resolve.retry_interval", 24*time.Hour)
+
+	return &resolveWorkerViperConfig{v}
+}
+
+func (c *resolveWorkerViperConfig) Enabled() bool {
+	return c.v.GetBool("workers.resolve.enabled")
+}
+
+// Interval is how often the worker looks for things which are missing from some services
+func (c *resolveWorkerViperConfig) Interval() time.Duration {
+	return c.v.GetDuration("workers.resolve.interval")
+}
+
+// Concurrency is how many things can be resolved at once
+func (c *resolveWorkerViperConfig) Concurrency() int {
+	return c.v.GetInt("workers.resolve.concurrency")
+}
+
+// BatchSize is how many things are read from the database at once
+func (c *resolveWorkerViperConfig) BatchSize() int {
+	return c.v.GetInt("workers.resolve.batch_size")
+}
+
+// RetryInterval is how long to wait before trying to resolve the same thing again.
+// Some things really don't exist on some services, so there's no point in checking for them every time.
+func (c *resolveWorkerViperConfig) RetryInterval() time.Duration {
+	return c.v.GetDuration("workers.resolve.retry_interval")
+}
+
+type linkHealthWorkerViperConfig struct {
+	v *viper.Viper
+}
+
+func NewLinkHealthWorkerViperConfig(v *viper.Viper) LinkHealthWorker {
+	v.SetDefault("workers.link_health.enabled", true)
+	v.SetDefault("workers.link_health.interval", time.Hour)
+	v.SetDefault("workers.link_health.concurrency", 2)
+	v.SetDefault("workers.link_health.batch_size", 100)
+	v.SetDefault("workers.link_health.verify_interval", 7*24*time.Hour)
+
+	return &linkHealthWorkerViperConfig{v}
+}
+
+func (c *linkHealthWorkerViperConfig) Enabled() bool {
+	return c.v.GetBool("workers.link_health.enabled")
+}
+
+// Interval is how often the worker looks for links which need to be verified
+func (c *linkHealthWorkerViperConfig) Interval() time.Duration {
+	return c.v.GetDuration("workers.link_health.interval")
+}
+
+// Concurrency is how many links can be verified at once
+func (c *linkHealthWorkerViperConfig) Concurrency() int {
+	return c.v.GetInt("workers.link_health.concurrency")
+}
+
+// BatchSize is the most links of each type which are verified each interval
+func (c *linkHealthWorkerViperConfig) BatchSize() int {
+	return c.v.GetInt("workers.link_health.batch_size")
+}
+
+// VerifyInterval is how long a link is trusted for before it's verified again
+func (c *linkHealthWorkerViperConfig) VerifyInterval() time.Duration {
+	return c.v.GetDuration("workers.link_health.verify_interval")
+}
+
+type trendingWorkerViperConfig struct {
+	v *viper.Viper
+}
+
+func NewTrendingWorkerViperConfig(v *viper.Viper) TrendingWorker {
+	v.SetDefault("workers.trending.enabled", true)
+	v.SetDefault("workers.trending.interval", 15*time.Minute)
+	v.SetDefault("workers.trending.limit", 100)
+
+	return &trendingWorkerViperConfig{v}
+}
+
+func (c *trendingWorkerViperConfig) Enabled() bool {
+	return c.v.GetBool("workers.trending.enabled")
+}
+
+// Interval is how often the trending scores are worked out again
+func (c *trendingWorkerViperConfig) Interval() time.Duration {
+	return c.v.GetDuration("workers.trending.interval")
+}
+
+// Limit is how many artists, albums, and tracks are kept for each window
+func (c *trendingWorkerViperConfig) Limit() int {
+	return c.v.GetInt("workers.trending.limit")
+}
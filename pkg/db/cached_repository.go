@@ -0,0 +1,427 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// cachedLink is what we store in the cache for a link, the link can belong to an artist, album, or track
+type cachedLink struct {
+	Type   model.Type
+	Artist *model.Artist `bson:",omitempty"`
+	Album  *model.Album  `bson:",omitempty"`
+	Track  *model.Track  `bson:",omitempty"`
+}
+
+type cachedRepository struct {
+	repo   Repository
+	cache  cache.Cache
+	rec    metrics.Recorder
+	logger *logrus.Logger
+}
+
+// NewCachedRepository wraps the given Repository with a read-through cache.
+// Lookups by link, artist ID, album ID, and ISRC are cached. Anything which isn't found is never cached,
+// and adding new things invalidates any entries they could affect.
+// The cache is best-effort, if it fails the lookup goes straight to the underlying Repository.
+func NewCachedRepository(repo Repository, c cache.Cache, rec metrics.Recorder, logger *logrus.Logger) Repository {
+	return &cachedRepository{repo, c, rec, logger}
+}
+
+func linkKey(link string) string {
+	// Links which point to the same thing share the same entry
+	if key, ok := streamingservice.LinkKey(link); ok {
+		return fmt.Sprintf("link:%s", key)
+	}
+
+	return fmt.Sprintf("link:%s", link)
+}
+
+func artistIdKey(id string) string {
+	return fmt.Sprintf("artist:id:%s", id)
+}
+
+func albumIdKey(id string) string {
+	return fmt.Sprintf("album:id:%s", id)
+}
+
+func isrcKey(isrc string) string {
+	return fmt.Sprintf("track:isrc:%s", isrc)
+}
+
+func shortCodeKey(code string) string {
+	return fmt.Sprintf("short_link:code:%s", code)
+}
+
+func (c *cachedRepository) AddArtist(ctx context.Context, artists []*model.Artist) (int, error) {
+	n, err := c.repo.AddArtist(ctx, artists)
+	if err != nil {
+		return n, err
+	}
+
+	var keys []string
+	for _, artist := range artists {
+		keys = append(keys, artistIdKey(artist.ArtistId), linkKey(artist.Link))
+	}
+
+	c.invalidate(ctx, keys...)
+	return n, nil
+}
+
+func (c *cachedRepository) GetArtistsById(ctx context.Context, id string) ([]*model.Artist, error) {
+	key := artistIdKey(id)
+
+	var artists []*model.Artist
+	if c.get(ctx, key, &artists) {
+		return artists, nil
+	}
+
+	artists, err := c.repo.GetArtistsById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(artists) > 0 {
+		c.set(ctx, key, artists)
+	}
+
+	return artists, nil
+}
+
+func (c *cachedRepository) GetArtistByLink(ctx context.Context, link string) (*model.Artist, error) {
+	var cached *cachedLink
+	if c.get(ctx, linkKey(link), &cached) {
+		return cached.Artist, nil
+	}
+
+	artist, err := c.repo.GetArtistByLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if artist != nil {
+		c.set(ctx, linkKey(link), &cachedLink{Type: model.ArtistType, Artist: artist})
+	}
+
+	return artist, nil
+}
+
+func (c *cachedRepository) AddAlbum(ctx context.Context, albums []*model.Album) (int, error) {
+	n, err := c.repo.AddAlbum(ctx, albums)
+	if err != nil {
+		return n, err
+	}
+
+	var keys []string
+	for _, album := range albums {
+		keys = append(keys, albumIdKey(album.AlbumId), linkKey(album.Link))
+	}
+
+	c.invalidate(ctx, keys...)
+	return n, nil
+}
+
+func (c *cachedRepository) GetAlbumsById(ctx context.Context, id string) ([]*model.Album, error) {
+	key := albumIdKey(id)
+
+	var albums []*model.Album
+	if c.get(ctx, key, &albums) {
+		return albums, nil
+	}
+
+	albums, err := c.repo.GetAlbumsById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(albums) > 0 {
+		c.set(ctx, key, albums)
+	}
+
+	return albums, nil
+}
+
+func (c *cachedRepository) GetAlbumByLink(ctx context.Context, link string) (*model.Album, error) {
+	var cached *cachedLink
+	if c.get(ctx, linkKey(link), &cached) {
+		return cached.Album, nil
+	}
+
+	album, err := c.repo.GetAlbumByLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if album != nil {
+		c.set(ctx, linkKey(link), &cachedLink{Type: model.AlbumType, Album: album})
+	}
+
+	return album, nil
+}
+
+// GetAlbumByUpc isn't cached, since an album's UPC can't be invalidated when only its link is known
+func (c *cachedRepository) GetAlbumByUpc(ctx context.Context, upc string) (*model.Album, error) {
+	return c.repo.GetAlbumByUpc(ctx, upc)
+}
+
+func (c *cachedRepository) AddTracks(ctx context.Context, tracks []*model.Track) (int, error) {
+	n, err := c.repo.AddTracks(ctx, tracks)
+	if err != nil {
+		return n, err
+	}
+
+	var keys []string
+	for _, track := range tracks {
+		keys = append(keys, isrcKey(track.Isrc), linkKey(track.Link))
+	}
+
+	c.invalidate(ctx, keys...)
+	return n, nil
+}
+
+func (c *cachedRepository) GetTracksByLegacyId(ctx context.Context, id string) ([]*model.Track, error) {
+	// Legacy IDs are rare enough that they're not worth caching
+	return c.repo.GetTracksByLegacyId(ctx, id)
+}
+
+func (c *cachedRepository) GetTracksByIsrc(ctx context.Context, isrc string) ([]*model.Track, error) {
+	key := isrcKey(isrc)
+
+	var tracks []*model.Track
+	if c.get(ctx, key, &tracks) {
+		return tracks, nil
+	}
+
+	tracks, err := c.repo.GetTracksByIsrc(ctx, isrc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tracks) > 0 {
+		c.set(ctx, key, tracks)
+	}
+
+	return tracks, nil
+}
+
+func (c *cachedRepository) GetTrackByLink(ctx context.Context, link string) (*model.Track, error) {
+	var cached *cachedLink
+	if c.get(ctx, linkKey(link), &cached) {
+		return cached.Track, nil
+	}
+
+	track, err := c.repo.GetTrackByLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if track != nil {
+		c.set(ctx, linkKey(link), &cachedLink{Type: model.TrackType, Track: track})
+	}
+
+	return track, nil
+}
+
+func (c *cachedRepository) GetByLink(ctx context.Context, link string) (model.Type, any, error) {
+	var cached *cachedLink
+	if c.get(ctx, linkKey(link), &cached) {
+		switch cached.Type {
+		case model.ArtistType:
+			return cached.Type, cached.Artist, nil
+		case model.AlbumType:
+			return cached.Type, cached.Album, nil
+		case model.TrackType:
+			return cached.Type, cached.Track, nil
+		}
+	}
+
+	typ, res, err := c.repo.GetByLink(ctx, link)
+	if err != nil {
+		return typ, res, err
+	}
+
+	switch typ {
+	case model.ArtistType:
+		c.set(ctx, linkKey(link), &cachedLink{Type: typ, Artist: res.(*model.Artist)})
+	case model.AlbumType:
+		c.set(ctx, linkKey(link), &cachedLink{Type: typ, Album: res.(*model.Album)})
+	case model.TrackType:
+		c.set(ctx, linkKey(link), &cachedLink{Type: typ, Track: res.(*model.Track)})
+	}
+
+	return typ, res, nil
+}
+
+// Scans are only used by background workers, so they always go to the underlying Repository
+
+func (c *cachedRepository) GetIncompleteArtistIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return c.repo.GetIncompleteArtistIds(ctx, services, after, limit)
+}
+
+func (c *cachedRepository) GetIncompleteAlbumIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return c.repo.GetIncompleteAlbumIds(ctx, services, after, limit)
+}
+
+func (c *cachedRepository) GetIncompleteIsrcs(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return c.repo.GetIncompleteIsrcs(ctx, services, after, limit)
+}
+
+func (c *cachedRepository) GetArtistsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Artist, error) {
+	return c.repo.GetArtistsToVerify(ctx, services, checkedBefore, limit)
+}
+
+func (c *cachedRepository) GetAlbumsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Album, error) {
+	return c.repo.GetAlbumsToVerify(ctx, services, checkedBefore, limit)
+}
+
+func (c *cachedRepository) GetTracksToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Track, error) {
+	return c.repo.GetTracksToVerify(ctx, services, checkedBefore, limit)
+}
+
+func (c *cachedRepository) UpdateArtistHealth(ctx context.Context, artist *model.Artist) error {
+	err := c.repo.UpdateArtistHealth(ctx, artist)
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, artistIdKey(artist.ArtistId), linkKey(artist.Link))
+	return nil
+}
+
+func (c *cachedRepository) UpdateAlbumHealth(ctx context.Context, album *model.Album) error {
+	err := c.repo.UpdateAlbumHealth(ctx, album)
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, albumIdKey(album.AlbumId), linkKey(album.Link))
+	return nil
+}
+
+func (c *cachedRepository) UpdateAlbumId(ctx context.Context, album *model.Album, id string) error {
+	err := c.repo.UpdateAlbumId(ctx, album, id)
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, albumIdKey(album.AlbumId), albumIdKey(id), linkKey(album.Link))
+	return nil
+}
+
+func (c *cachedRepository) UpdateTrackHealth(ctx context.Context, track *model.Track) error {
+	err := c.repo.UpdateTrackHealth(ctx, track)
+	if err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, isrcKey(track.Isrc), linkKey(track.Link))
+	return nil
+}
+
+func (c *cachedRepository) AddShortLink(ctx context.Context, link *model.ShortLink) error {
+	return c.repo.AddShortLink(ctx, link)
+}
+
+// GetShortLink is cached without ever being invalidated, short links never change once they've been created
+func (c *cachedRepository) GetShortLink(ctx context.Context, code string) (*model.ShortLink, error) {
+	key := shortCodeKey(code)
+
+	var link *model.ShortLink
+	if c.get(ctx, key, &link) {
+		return link, nil
+	}
+
+	link, err := c.repo.GetShortLink(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if link != nil {
+		c.set(ctx, key, link)
+	}
+
+	return link, nil
+}
+
+func (c *cachedRepository) GetShortLinkFor(ctx context.Context, typ model.Type, id string) (*model.ShortLink, error) {
+	return c.repo.GetShortLinkFor(ctx, typ, id)
+}
+
+func (c *cachedRepository) RecordEvent(ctx context.Context, event *model.AnalyticsEvent) error {
+	return c.repo.RecordEvent(ctx, event)
+}
+
+func (c *cachedRepository) GetTopThings(ctx context.Context, kind model.AnalyticsEventKind, since time.Time, limit int) ([]*model.ThingStats, error) {
+	return c.repo.GetTopThings(ctx, kind, since, limit)
+}
+
+func (c *cachedRepository) GetServiceStats(ctx context.Context, since time.Time) ([]*model.ServiceStats, error) {
+	return c.repo.GetServiceStats(ctx, since)
+}
+
+func (c *cachedRepository) GetDailyCounts(ctx context.Context, kind model.AnalyticsEventKind, since time.Time) ([]*model.AnalyticsCount, error) {
+	return c.repo.GetDailyCounts(ctx, kind, since)
+}
+
+func (c *cachedRepository) ReplaceTrending(ctx context.Context, window model.TrendingWindow, scores []*model.TrendingScore) error {
+	return c.repo.ReplaceTrending(ctx, window, scores)
+}
+
+func (c *cachedRepository) GetTrending(ctx context.Context, window model.TrendingWindow, typ model.Type, limit int) ([]*model.TrendingScore, error) {
+	return c.repo.GetTrending(ctx, window, typ, limit)
+}
+
+func (c *cachedRepository) get(ctx context.Context, key string, v any) bool {
+	value, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		c.logger.Errorf("failed to read %s from cache: %s", key, err.Error())
+	}
+
+	if !ok || err != nil {
+		go c.rec.CountCacheMiss()
+		return false
+	}
+
+	raw, err := bson.Raw(value).LookupErr("value")
+	if err == nil {
+		err = raw.Unmarshal(v)
+	}
+
+	if err != nil {
+		c.logger.Errorf("failed to unmarshal %s from cache: %s", key, err.Error())
+		go c.rec.CountCacheMiss()
+		return false
+	}
+
+	go c.rec.CountCacheHit()
+	return true
+}
+
+// set caches v as BSON, the same as it's stored. The JSON for things leaves out what we only need for storing them.
+func (c *cachedRepository) set(ctx context.Context, key string, v any) {
+	value, err := bson.Marshal(bson.D{{Key: "value", Value: v}})
+	if err != nil {
+		c.logger.Errorf("failed to marshal %s for cache: %s", key, err.Error())
+		return
+	}
+
+	err = c.cache.Set(ctx, key, value)
+	if err != nil {
+		c.logger.Errorf("failed to write %s to cache: %s", key, err.Error())
+	}
+}
+
+func (c *cachedRepository) invalidate(ctx context.Context, keys ...string) {
+	err := c.cache.Delete(ctx, keys...)
+	if err != nil {
+		c.logger.Errorf("failed to invalidate cache: %s", err.Error())
+	}
+}
@@ -0,0 +1,171 @@
+package db_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// countingRepository counts the lookups which make it through to the underlying repository
+type countingRepository struct {
+	db.Repository
+	lookups int
+}
+
+func (r *countingRepository) GetArtistsById(ctx context.Context, id string) ([]*model.Artist, error) {
+	r.lookups++
+	return r.Repository.GetArtistsById(ctx, id)
+}
+
+func (r *countingRepository) GetTracksByIsrc(ctx context.Context, isrc string) ([]*model.Track, error) {
+	r.lookups++
+	return r.Repository.GetTracksByIsrc(ctx, isrc)
+}
+
+func (r *countingRepository) GetByLink(ctx context.Context, link string) (model.Type, any, error) {
+	r.lookups++
+	return r.Repository.GetByLink(ctx, link)
+}
+
+func newCachedRepository() (db.Repository, *countingRepository) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	inner := &countingRepository{Repository: db.NewInMemoryRepository()}
+	repo := db.NewCachedRepository(inner, cache.NewLRUCache(100, time.Hour), metrics.NewNoopMetricsRecorder(), logger)
+	return repo, inner
+}
+
+func Test_CachedRepositoryServesRepeatedLookupsFromCache(t *testing.T) {
+	ctx := context.Background()
+	repo, inner := newCachedRepository()
+
+	track := model.NewTrack("USSM17800845", "Surrender", []string{"Cheap Trick"}, "Heaven Tonight", "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb")
+	_, err := repo.AddTracks(ctx, []*model.Track{track})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		typ, res, err := repo.GetByLink(ctx, track.Link)
+		require.NoError(t, err)
+		assert.Equal(t, model.TrackType, typ)
+		assert.Equal(t, track, res)
+
+		tracks, err := repo.GetTracksByIsrc(ctx, track.Isrc)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Track{track}, tracks)
+	}
+
+	assert.Equal(t, 2, inner.lookups)
+}
+
+func Test_CachedRepositoryDoesNotCacheMisses(t *testing.T) {
+	ctx := context.Background()
+	repo, inner := newCachedRepository()
+
+	link := "https://open.spotify.com/artist/1LB8qB5BPb3MHQrfkvifXU"
+	typ, _, err := repo.GetByLink(ctx, link)
+	require.NoError(t, err)
+	assert.Equal(t, model.UnknownType, typ)
+
+	artist := model.NewArtist("Cheap Trick", "", model.SpotifyStreamingService, model.DefaultMarket, link)
+	artist.ArtistId = "artist-id"
+	_, err = repo.AddArtist(ctx, []*model.Artist{artist})
+	require.NoError(t, err)
+
+	typ, _, err = repo.GetByLink(ctx, link)
+	require.NoError(t, err)
+	assert.Equal(t, model.ArtistType, typ)
+	assert.Equal(t, 2, inner.lookups)
+}
+
+func Test_CachedRepositoryInvalidatesOnAdd(t *testing.T) {
+	ctx := context.Background()
+	repo, inner := newCachedRepository()
+
+	spotifyArtist := model.NewArtist("Cheap Trick", "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/artist/1LB8qB5BPb3MHQrfkvifXU")
+	spotifyArtist.ArtistId = "artist-id"
+	_, err := repo.AddArtist(ctx, []*model.Artist{spotifyArtist})
+	require.NoError(t, err)
+
+	artists, err := repo.GetArtistsById(ctx, "artist-id")
+	require.NoError(t, err)
+	assert.Len(t, artists, 1)
+
+	deezerArtist := model.NewArtist("Cheap Trick", "", model.DeezerStreamingService, model.DefaultMarket, "https://www.deezer.com/artist/1143")
+	deezerArtist.ArtistId = "artist-id"
+	_, err = repo.AddArtist(ctx, []*model.Artist{deezerArtist})
+	require.NoError(t, err)
+
+	artists, err = repo.GetArtistsById(ctx, "artist-id")
+	require.NoError(t, err)
+	assert.Len(t, artists, 2, "adding an artist should invalidate the cached group")
+	assert.Equal(t, 2, inner.lookups)
+}
+
+func Test_CachedRepositoryInvalidatesMovedAlbums(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newCachedRepository()
+
+	album := model.NewAlbum("Heaven Tonight", []string{"Cheap Trick"}, "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/album/2TzB2x5VvBk1MR5dx6pkLH")
+	album.AlbumId = "album-1"
+	_, err := repo.AddAlbum(ctx, []*model.Album{album})
+	require.NoError(t, err)
+
+	// Both groups are cached before the album moves
+	albums, err := repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	require.Len(t, albums, 1)
+
+	albums, err = repo.GetAlbumsById(ctx, "album-2")
+	require.NoError(t, err)
+	require.Empty(t, albums)
+
+	require.NoError(t, repo.UpdateAlbumId(ctx, album, "album-2"))
+
+	albums, err = repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Empty(t, albums)
+
+	albums, err = repo.GetAlbumsById(ctx, "album-2")
+	require.NoError(t, err)
+	assert.Len(t, albums, 1)
+
+	moved, err := repo.GetAlbumByLink(ctx, album.Link)
+	require.NoError(t, err)
+	assert.Equal(t, "album-2", moved.AlbumId)
+}
+
+func Test_CachedRepositoryKeepsStorageFields(t *testing.T) {
+	ctx := context.Background()
+	repo, inner := newCachedRepository()
+
+	album := model.NewAlbum("Heaven Tonight", []string{"Cheap Trick"}, "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/album/2TzB2x5VvBk1MR5dx6pkLH")
+	album.AlbumId = "album-1"
+	album.Aliases = []string{"https://open.spotify.com/album/2TzB2x5VvBk1MR5dx6pkLH?si=abc"}
+	album.Dead = true
+	_, err := repo.AddAlbum(ctx, []*model.Album{album})
+	require.NoError(t, err)
+
+	// They're left out of the JSON, but a dead album mustn't come back from the cache alive
+	for i := 0; i < 2; i++ {
+		typ, res, err := repo.GetByLink(ctx, album.Link)
+		require.NoError(t, err)
+		require.Equal(t, model.AlbumType, typ)
+
+		cached := res.(*model.Album)
+		assert.True(t, cached.Dead)
+		assert.Equal(t, album.Aliases, cached.Aliases)
+		assert.Equal(t, album.LinkKey, cached.LinkKey)
+	}
+
+	assert.Equal(t, 1, inner.lookups)
+}
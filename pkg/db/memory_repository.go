@@ -0,0 +1,587 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+type inMemoryRepository struct {
+	mu         sync.RWMutex
+	artists    []*model.Artist
+	albums     []*model.Album
+	tracks     []*model.Track
+	shortLinks []*model.ShortLink
+	counts     []*model.AnalyticsCount
+	events     []*model.AnalyticsEvent
+	trending   map[model.TrendingWindow][]*model.TrendingScore
+}
+
+// NewInMemoryRepository creates a Repository which keeps everything in memory.
+// Nothing is persisted, so it's only really useful for tests and local development.
+func NewInMemoryRepository() Repository {
+	return &inMemoryRepository{}
+}
+
+func (m *inMemoryRepository) AddArtist(_ context.Context, artists []*model.Artist) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := 0
+	for _, artist := range artists {
+		artist.LinkKey = linkKeyOf(artist)
+
+		var ok bool
+		if m.artists, ok = addOrAlias(m.artists, artist); ok {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+func (m *inMemoryRepository) GetArtistsById(_ context.Context, id string) ([]*model.Artist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAll(m.artists, func(a *model.Artist) bool {
+		return a.ArtistId == id
+	}), nil
+}
+
+func (m *inMemoryRepository) GetArtistByLink(_ context.Context, link string) (*model.Artist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findByLink(m.artists, link), nil
+}
+
+func (m *inMemoryRepository) AddAlbum(_ context.Context, albums []*model.Album) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := 0
+	for _, album := range albums {
+		album.LinkKey = linkKeyOf(album)
+
+		var ok bool
+		if m.albums, ok = addOrAlias(m.albums, album); ok {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+func (m *inMemoryRepository) GetAlbumsById(_ context.Context, id string) ([]*model.Album, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAll(m.albums, func(a *model.Album) bool {
+		return a.AlbumId == id
+	}), nil
+}
+
+func (m *inMemoryRepository) GetAlbumByLink(_ context.Context, link string) (*model.Album, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findByLink(m.albums, link), nil
+}
+
+func (m *inMemoryRepository) GetAlbumByUpc(_ context.Context, upc string) (*model.Album, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findFirst(m.albums, func(a *model.Album) bool {
+		return a.Upc == upc && !a.Dead
+	}), nil
+}
+
+func (m *inMemoryRepository) AddTracks(_ context.Context, tracks []*model.Track) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	added := 0
+	for _, track := range tracks {
+		track.LinkKey = linkKeyOf(track)
+
+		var ok bool
+		if m.tracks, ok = addOrAlias(m.tracks, track); ok {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+func (m *inMemoryRepository) GetTracksByLegacyId(_ context.Context, _ string) ([]*model.Track, error) {
+	// Legacy IDs only exist in databases which pre-date ISRCs, nothing in memory can have one
+	return nil, nil
+}
+
+func (m *inMemoryRepository) GetTracksByIsrc(_ context.Context, isrc string) ([]*model.Track, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findAll(m.tracks, func(t *model.Track) bool {
+		return t.Isrc == isrc
+	}), nil
+}
+
+func (m *inMemoryRepository) GetTrackByLink(_ context.Context, link string) (*model.Track, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findByLink(m.tracks, link), nil
+}
+
+func (m *inMemoryRepository) GetByLink(ctx context.Context, link string) (model.Type, any, error) {
+
+	artist, _ := m.GetArtistByLink(ctx, link)
+	if artist != nil {
+		return model.ArtistType, artist, nil
+	}
+
+	album, _ := m.GetAlbumByLink(ctx, link)
+	if album != nil {
+		return model.AlbumType, album, nil
+	}
+
+	track, _ := m.GetTrackByLink(ctx, link)
+	if track != nil {
+		return model.TrackType, track, nil
+	}
+
+	return model.UnknownType, nil, nil
+}
+
+func (m *inMemoryRepository) GetIncompleteArtistIds(_ context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return incompleteIds(m.artists, func(a *model.Artist) string { return a.ArtistId }, services, after, limit), nil
+}
+
+func (m *inMemoryRepository) GetIncompleteAlbumIds(_ context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return incompleteIds(m.albums, func(a *model.Album) string { return a.AlbumId }, services, after, limit), nil
+}
+
+func (m *inMemoryRepository) GetIncompleteIsrcs(_ context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return incompleteIds(m.tracks, func(t *model.Track) string { return t.Isrc }, services, after, limit), nil
+}
+
+func (m *inMemoryRepository) GetArtistsToVerify(_ context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Artist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return toVerify(m.artists, func(a *model.Artist) *time.Time { return a.LastChecked }, services, checkedBefore, limit), nil
+}
+
+func (m *inMemoryRepository) GetAlbumsToVerify(_ context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Album, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return toVerify(m.albums, func(a *model.Album) *time.Time { return a.LastChecked }, services, checkedBefore, limit), nil
+}
+
+func (m *inMemoryRepository) GetTracksToVerify(_ context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Track, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return toVerify(m.tracks, func(t *model.Track) *time.Time { return t.LastChecked }, services, checkedBefore, limit), nil
+}
+
+func (m *inMemoryRepository) UpdateArtistHealth(_ context.Context, artist *model.Artist) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range m.artists {
+		if a.Link == artist.Link {
+			a.LastChecked = artist.LastChecked
+			a.LastVerified = artist.LastVerified
+			a.Dead = artist.Dead
+		}
+	}
+
+	return nil
+}
+
+func (m *inMemoryRepository) UpdateAlbumId(_ context.Context, album *model.Album, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range m.albums {
+		if a.Link == album.Link {
+			a.AlbumId = id
+		}
+	}
+
+	return nil
+}
+
+func (m *inMemoryRepository) UpdateAlbumHealth(_ context.Context, album *model.Album) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range m.albums {
+		if a.Link == album.Link {
+			a.LastChecked = album.LastChecked
+			a.LastVerified = album.LastVerified
+			a.Dead = album.Dead
+		}
+	}
+
+	return nil
+}
+
+func (m *inMemoryRepository) UpdateTrackHealth(_ context.Context, track *model.Track) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tracks {
+		if t.Link == track.Link {
+			t.LastChecked = track.LastChecked
+			t.LastVerified = track.LastVerified
+			t.Dead = track.Dead
+		}
+	}
+
+	return nil
+}
+
+func (m *inMemoryRepository) AddShortLink(_ context.Context, link *model.ShortLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := findFirst(m.shortLinks, func(l *model.ShortLink) bool {
+		return l.Code == link.Code
+	})
+
+	if existing != nil {
+		return ErrShortCodeTaken
+	}
+
+	existing = findFirst(m.shortLinks, func(l *model.ShortLink) bool {
+		return l.Type == link.Type && l.Id == link.Id
+	})
+
+	if existing != nil {
+		return ErrShortLinkExists
+	}
+
+	m.shortLinks = append(m.shortLinks, copyOf(link))
+	return nil
+}
+
+func (m *inMemoryRepository) GetShortLink(_ context.Context, code string) (*model.ShortLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findFirst(m.shortLinks, func(l *model.ShortLink) bool {
+		return l.Code == code
+	}), nil
+}
+
+func (m *inMemoryRepository) GetShortLinkFor(_ context.Context, typ model.Type, id string) (*model.ShortLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return findFirst(m.shortLinks, func(l *model.ShortLink) bool {
+		return l.Type == typ && l.Id == id
+	}), nil
+}
+
+func (m *inMemoryRepository) RecordEvent(_ context.Context, event *model.AnalyticsEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	day := model.Day(event.Time)
+	var count *model.AnalyticsCount
+	for _, c := range m.counts {
+		if c.Day.Equal(day) && c.Kind == event.Kind && c.Type == event.Type && c.Id == event.Id &&
+			c.Source == event.Source && c.Target == event.Target {
+			count = c
+			break
+		}
+	}
+
+	if count == nil {
+		count = &model.AnalyticsCount{
+			Day:    day,
+			Kind:   event.Kind,
+			Type:   event.Type,
+			Id:     event.Id,
+			Source: event.Source,
+			Target: event.Target,
+		}
+
+		m.counts = append(m.counts, count)
+	}
+
+	count.Count++
+
+	if len(event.IPAddress) > 0 || len(event.UserAgent) > 0 {
+		m.events = append(m.events, copyOf(event))
+	}
+
+	return nil
+}
+
+func (m *inMemoryRepository) GetTopThings(_ context.Context, kind model.AnalyticsEventKind, since time.Time, limit int) ([]*model.ThingStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stats []*model.ThingStats
+	byThing := make(map[string]*model.ThingStats)
+	for _, count := range m.counts {
+		if count.Kind != kind || count.Day.Before(model.Day(since)) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s:%s", count.Type, count.Id)
+		if _, ok := byThing[key]; !ok {
+			byThing[key] = &model.ThingStats{Type: count.Type, Id: count.Id}
+			stats = append(stats, byThing[key])
+		}
+
+		byThing[key].Count += count.Count
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+
+		if stats[i].Type != stats[j].Type {
+			return stats[i].Type < stats[j].Type
+		}
+
+		return stats[i].Id < stats[j].Id
+	})
+
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+
+	return stats, nil
+}
+
+func (m *inMemoryRepository) GetServiceStats(_ context.Context, since time.Time) ([]*model.ServiceStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := findAll(m.counts, func(c *model.AnalyticsCount) bool {
+		return !c.Day.Before(model.Day(since))
+	})
+
+	return serviceStats(counts), nil
+}
+
+func (m *inMemoryRepository) GetDailyCounts(_ context.Context, kind model.AnalyticsEventKind, since time.Time) ([]*model.AnalyticsCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var counts []*model.AnalyticsCount
+	byDay := make(map[string]*model.AnalyticsCount)
+	for _, count := range m.counts {
+		if count.Kind != kind || count.Day.Before(model.Day(since)) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s:%s:%s", count.Day.Format(time.DateOnly), count.Type, count.Id)
+		if _, ok := byDay[key]; !ok {
+			byDay[key] = &model.AnalyticsCount{Day: count.Day, Kind: kind, Type: count.Type, Id: count.Id}
+			counts = append(counts, byDay[key])
+		}
+
+		byDay[key].Count += count.Count
+	}
+
+	return counts, nil
+}
+
+func (m *inMemoryRepository) ReplaceTrending(_ context.Context, window model.TrendingWindow, scores []*model.TrendingScore) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.trending == nil {
+		m.trending = make(map[model.TrendingWindow][]*model.TrendingScore)
+	}
+
+	var copied []*model.TrendingScore
+	for _, score := range scores {
+		copied = append(copied, copyOf(score))
+	}
+
+	m.trending[window] = copied
+	return nil
+}
+
+func (m *inMemoryRepository) GetTrending(_ context.Context, window model.TrendingWindow, typ model.Type, limit int) ([]*model.TrendingScore, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := findAll(m.trending[window], func(s *model.TrendingScore) bool {
+		return len(typ) == 0 || s.Type == typ
+	})
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+
+	return scores, nil
+}
+
+func toVerify[T any, PT interface {
+	*T
+	model.Thing
+}](items []*T, lastCheckedOf func(*T) *time.Time, services []model.StreamingServiceType, checkedBefore time.Time, limit int) []*T {
+	res := findAll(items, func(item *T) bool {
+		if !containsService(services, PT(item).GetSource()) {
+			return false
+		}
+
+		lastChecked := lastCheckedOf(item)
+		return lastChecked == nil || lastChecked.Before(checkedBefore)
+	})
+
+	// Never checked first, then the least recently checked
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := lastCheckedOf(res[i]), lastCheckedOf(res[j])
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+
+		return a.Before(*b)
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+func containsService(services []model.StreamingServiceType, key model.StreamingServiceType) bool {
+	for _, service := range services {
+		if service == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func incompleteIds[T model.Thing](items []T, idOf func(T) string, services []model.StreamingServiceType, after string, limit int) []string {
+	sources := make(map[string]map[model.StreamingServiceType]bool)
+	for _, item := range items {
+		id := idOf(item)
+		if id <= after {
+			continue
+		}
+
+		// Dead links don't count, the thing needs to be found again on that service
+		if item.IsDead() {
+			continue
+		}
+
+		if _, ok := sources[id]; !ok {
+			sources[id] = make(map[model.StreamingServiceType]bool)
+		}
+
+		sources[id][item.GetSource()] = true
+	}
+
+	var ids []string
+	for id, found := range sources {
+		for _, service := range services {
+			if !found[service] {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
+
+// addOrAlias stores the item, unless something with the same link key has already been stored.
+// In that case, the item's link is remembered as an alias of the existing thing instead, and the item takes the
+// existing thing's ID so that the caller knows what it's stored as.
+func addOrAlias[T any, PT interface {
+	*T
+	model.Thing
+	AddAlias(link string)
+	AdoptIdOf(existing *T)
+}](items []*T, item *T) ([]*T, bool) {
+	if key := PT(item).GetLinkKey(); len(key) > 0 {
+		for _, existing := range items {
+			if PT(existing).GetLinkKey() == key {
+				PT(existing).AddAlias(PT(item).GetLink())
+				PT(item).AdoptIdOf(existing)
+				return items, false
+			}
+		}
+	}
+
+	return append(items, copyOf(item)), true
+}
+
+// findByLink finds the first item with the given link, either as its link, one of its aliases, or by the link's key
+func findByLink[T any, PT interface {
+	*T
+	model.Thing
+}](items []*T, link string) *T {
+	key, _ := streamingservice.LinkKey(link)
+	return findFirst(items, func(item *T) bool {
+		return PT(item).HasLink(link) || (len(key) > 0 && PT(item).GetLinkKey() == key)
+	})
+}
+
+// copyOf returns a shallow copy of v so that callers can't modify what's been stored
+func copyOf[T any](v *T) *T {
+	c := *v
+	return &c
+}
+
+func findAll[T any](items []*T, predicate func(*T) bool) []*T {
+	var res []*T
+	for _, item := range items {
+		if predicate(item) {
+			res = append(res, copyOf(item))
+		}
+	}
+
+	return res
+}
+
+func findFirst[T any](items []*T, predicate func(*T) bool) *T {
+	for _, item := range items {
+		if predicate(item) {
+			return copyOf(item)
+		}
+	}
+
+	return nil
+}
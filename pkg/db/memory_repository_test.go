@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+func Test_LinksWrittenDifferentlyFindTheSameThing(t *testing.T) {
+	ctx := context.Background()
+	repo := db.NewInMemoryRepository()
+
+	usLink := "https://music.apple.com/us/album/surrender/1585865534?i=1585865535"
+	gbLink := "https://music.apple.com/gb/song/surrender/1585865535"
+
+	track := model.NewTrack("USSM17800845", "Surrender", []string{"Cheap Trick"}, "Heaven Tonight", "", model.AppleMusicStreamingService, model.DefaultMarket, usLink)
+	n, err := repo.AddTracks(ctx, []*model.Track{track})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	typ, found, err := repo.GetByLink(ctx, "https://music.apple.com/gb/album/surrender/1585865534?i=1585865535")
+	require.NoError(t, err)
+	require.Equal(t, model.TrackType, typ)
+	assert.Equal(t, usLink, found.(*model.Track).Link)
+	assert.Equal(t, "apple_music:track:1585865535", found.(*model.Track).LinkKey)
+
+	// Adding the same track under a different link shouldn't create a duplicate
+	duplicate := model.NewTrack(track.Isrc, track.Name, track.ArtistNames, track.AlbumName, "", model.AppleMusicStreamingService, model.DefaultMarket, gbLink)
+	n, err = repo.AddTracks(ctx, []*model.Track{duplicate})
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	tracks, err := repo.GetTracksByIsrc(ctx, track.Isrc)
+	require.NoError(t, err)
+	require.Len(t, tracks, 1)
+	assert.Equal(t, []string{gbLink}, tracks[0].Aliases)
+
+	byAlias, err := repo.GetTrackByLink(ctx, gbLink)
+	require.NoError(t, err)
+	require.NotNil(t, byAlias)
+	assert.Equal(t, usLink, byAlias.Link)
+}
+
+func Test_LinksWithoutKeysAreMatchedExactly(t *testing.T) {
+	ctx := context.Background()
+	repo := db.NewInMemoryRepository()
+
+	artist := model.NewArtist("Cheap Trick", "", model.SpotifyStreamingService, model.DefaultMarket, "https://example.com/artist/cheap-trick")
+	_, err := repo.AddArtist(ctx, []*model.Artist{artist})
+	require.NoError(t, err)
+
+	found, err := repo.GetArtistByLink(ctx, artist.Link)
+	require.NoError(t, err)
+	assert.NotNil(t, found)
+
+	found, err = repo.GetArtistByLink(ctx, "https://example.com/artist/cheap-trick?utm_source=test")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func Test_AliasesTakeTheExistingId(t *testing.T) {
+	ctx := context.Background()
+	repo := db.NewInMemoryRepository()
+
+	album := model.NewAlbum("Heaven Tonight", []string{"Cheap Trick"}, "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/album/3zdqsvfpFnQyNyTSqFMwzI")
+	album.AlbumId = "album-1"
+	_, err := repo.AddAlbum(ctx, []*model.Album{album})
+	require.NoError(t, err)
+
+	duplicate := model.NewAlbum(album.Name, album.ArtistNames, "", model.SpotifyStreamingService, model.DefaultMarket, "https://open.spotify.com/intl-de/album/3zdqsvfpFnQyNyTSqFMwzI")
+	duplicate.AlbumId = "album-2"
+	n, err := repo.AddAlbum(ctx, []*model.Album{duplicate})
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "album-1", duplicate.AlbumId)
+
+	albums, err := repo.GetAlbumsById(ctx, "album-2")
+	require.NoError(t, err)
+	assert.Empty(t, albums)
+}
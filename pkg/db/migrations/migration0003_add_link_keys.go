@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration0003AddLinkKeys works out the link key of everything which was stored before link keys existed,
+// so that they can be found using links which are written differently.
+// Everything is looked up by its link, aliases, or link key, so those are indexed too.
+type Migration0003AddLinkKeys struct {
+}
+
+func (m *Migration0003AddLinkKeys) Execute(ctx context.Context, db *mongo.Database) error {
+	for _, collectionName := range []string{"artists", "albums", "tracks"} {
+		err := addLinkKeys(ctx, db.Collection(collectionName))
+		if err != nil {
+			return err
+		}
+
+		err = addLinkIndexes(ctx, db.Collection(collectionName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addLinkIndexes(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "link", Value: 1}}},
+		{Keys: bson.D{{Key: "aliases", Value: 1}}},
+		{Keys: bson.D{{Key: "linkkey", Value: 1}}},
+	})
+
+	return err
+}
+
+func addLinkKeys(ctx context.Context, coll *mongo.Collection) error {
+	cur, err := coll.Find(ctx, bson.D{{Key: "linkkey", Value: bson.D{{Key: "$exists", Value: false}}}})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var thing bson.M
+		if err := bson.Unmarshal(cur.Current, &thing); err != nil {
+			return err
+		}
+
+		link, _ := thing["link"].(string)
+		key, ok := streamingservice.LinkKey(link)
+		if !ok {
+			continue
+		}
+
+		_, err := coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: thing["_id"]}}, bson.M{"$set": bson.M{"linkkey": key}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func (m *Migration0003AddLinkKeys) Version() int {
+	return 3
+}
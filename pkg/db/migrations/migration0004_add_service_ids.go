@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration0004AddServiceIds fills in the service ID (and the storefront for Apple Music) of everything which
+// was stored before they were kept alongside the link
+type Migration0004AddServiceIds struct {
+}
+
+func (m *Migration0004AddServiceIds) Execute(ctx context.Context, db *mongo.Database) error {
+	for _, collectionName := range []string{"artists", "albums", "tracks"} {
+		err := addServiceIds(ctx, db.Collection(collectionName))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addServiceIds(ctx context.Context, coll *mongo.Collection) error {
+	cur, err := coll.Find(ctx, bson.D{{Key: "serviceid", Value: bson.D{{Key: "$exists", Value: false}}}})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var thing bson.M
+		if err := bson.Unmarshal(cur.Current, &thing); err != nil {
+			return err
+		}
+
+		link, _ := thing["link"].(string)
+		info, ok := streamingservice.ParseLink(link)
+		if !ok {
+			continue
+		}
+
+		set := bson.M{"serviceid": info.Id}
+		if len(info.Storefront) > 0 {
+			set["storefront"] = info.Storefront
+		}
+
+		_, err := coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: thing["_id"]}}, bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func (m *Migration0004AddServiceIds) Version() int {
+	return 4
+}
@@ -0,0 +1,77 @@
+package migrations_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yukitsune/maestro/pkg/db/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func Test_Migration0004ExecutesCorrectly(t *testing.T) {
+	withTestDb(t, func(db *mongo.Database) {
+
+		// Seed the database with some data
+		err := setupDataForMigration0004(db)
+		assert.NoError(t, err)
+
+		// Execute the migration
+		m := &migrations.Migration0004AddServiceIds{}
+		err = m.Execute(context.Background(), db)
+		assert.NoError(t, err)
+
+		// Ensure the database is in the expected state
+		err = assertStateIsCorrectForMigration0004(t, db)
+		assert.NoError(t, err)
+	})
+}
+
+func setupDataForMigration0004(db *mongo.Database) error {
+	artistsColl := db.Collection("artists")
+	_, err := artistsColl.InsertOne(context.Background(), bson.D{{Key: "link", Value: "https://open.spotify.com/artist/3d2iR5kC4BWcvUyuoxz8Ra"}})
+	if err != nil {
+		return err
+	}
+
+	albumsColl := db.Collection("albums")
+	_, err = albumsColl.InsertOne(context.Background(), bson.D{{Key: "link", Value: "https://music.apple.com/au/album/heaven-tonight/1585865534"}})
+	if err != nil {
+		return err
+	}
+
+	tracksColl := db.Collection("tracks")
+	_, err = tracksColl.InsertMany(context.Background(), []interface{}{
+		bson.D{{Key: "link", Value: "https://www.deezer.com/track/606334862"}},
+		bson.D{{Key: "link", Value: "https://example.com/not-a-streaming-service"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func assertStateIsCorrectForMigration0004(t *testing.T, db *mongo.Database) error {
+
+	artistColl := db.Collection("artists")
+	c, err := artistColl.CountDocuments(context.Background(), bson.D{{Key: "serviceid", Value: "3d2iR5kC4BWcvUyuoxz8Ra"}, {Key: "storefront", Value: bson.D{{Key: "$exists", Value: false}}}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "artists should have a service ID")
+
+	albumColl := db.Collection("albums")
+	c, err = albumColl.CountDocuments(context.Background(), bson.D{{Key: "serviceid", Value: "1585865534"}, {Key: "storefront", Value: "au"}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "apple music albums should have a service ID and storefront")
+
+	trackColl := db.Collection("tracks")
+	c, err = trackColl.CountDocuments(context.Background(), bson.D{{Key: "serviceid", Value: "606334862"}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "tracks should have a service ID")
+
+	c, err = trackColl.CountDocuments(context.Background(), bson.D{{Key: "serviceid", Value: bson.D{{Key: "$exists", Value: false}}}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "links from unknown services shouldn't have a service ID")
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ShortLinkTargetIndexName is the name of the index which makes sure each thing only has one short link
+const ShortLinkTargetIndexName = "type_1_id_1"
+
+// Migration0005AddShortLinkIndex indexes short links by what they point to, and makes sure each thing only has one.
+// Concurrent requests could give the same thing more than one code before, only the oldest of them is kept.
+type Migration0005AddShortLinkIndex struct {
+}
+
+func (m *Migration0005AddShortLinkIndex) Execute(ctx context.Context, db *mongo.Database) error {
+	coll := db.Collection("short_links")
+
+	err := removeDuplicateShortLinks(ctx, coll)
+	if err != nil {
+		return err
+	}
+
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "type", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetName(ShortLinkTargetIndexName).SetUnique(true),
+	})
+
+	return err
+}
+
+func removeDuplicateShortLinks(ctx context.Context, coll *mongo.Collection) error {
+	cur, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "created", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "type", Value: "$type"}, {Key: "id", Value: "$id"}}},
+			{Key: "codes", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "codes.1", Value: bson.D{{Key: "$exists", Value: true}}}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var group struct {
+			Codes []string `bson:"codes"`
+		}
+
+		if err := cur.Decode(&group); err != nil {
+			return err
+		}
+
+		_, err := coll.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: group.Codes[1:]}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func (m *Migration0005AddShortLinkIndex) Version() int {
+	return 5
+}
@@ -0,0 +1,64 @@
+package migrations_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/yukitsune/maestro/pkg/db/migrations"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+func Test_Migration0005ExecutesCorrectly(t *testing.T) {
+	withTestDb(t, func(db *mongo.Database) {
+
+		// Seed the database with some data
+		err := setupDataForMigration0005(db)
+		assert.NoError(t, err)
+
+		// Execute the migration
+		m := &migrations.Migration0005AddShortLinkIndex{}
+		err = m.Execute(context.Background(), db)
+		assert.NoError(t, err)
+
+		// Ensure the database is in the expected state
+		err = assertStateIsCorrectForMigration0005(t, db)
+		assert.NoError(t, err)
+	})
+}
+
+func setupDataForMigration0005(db *mongo.Database) error {
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	coll := db.Collection("short_links")
+	_, err := coll.InsertMany(context.Background(), []interface{}{
+		bson.D{{Key: "_id", Value: "AAAAAAA"}, {Key: "type", Value: "album"}, {Key: "id", Value: "album-1"}, {Key: "created", Value: created}},
+		bson.D{{Key: "_id", Value: "BBBBBBB"}, {Key: "type", Value: "album"}, {Key: "id", Value: "album-1"}, {Key: "created", Value: created.Add(time.Second)}},
+		bson.D{{Key: "_id", Value: "CCCCCCC"}, {Key: "type", Value: "artist"}, {Key: "id", Value: "album-1"}, {Key: "created", Value: created}},
+	})
+
+	return err
+}
+
+func assertStateIsCorrectForMigration0005(t *testing.T, db *mongo.Database) error {
+	ctx := context.Background()
+	coll := db.Collection("short_links")
+
+	c, err := coll.CountDocuments(ctx, bson.D{{Key: "_id", Value: "AAAAAAA"}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "the oldest short link should be kept")
+
+	c, err = coll.CountDocuments(ctx, bson.D{{Key: "_id", Value: "BBBBBBB"}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(0), c, "newer short links for the same thing should be removed")
+
+	c, err = coll.CountDocuments(ctx, bson.D{{Key: "_id", Value: "CCCCCCC"}})
+	assert.NoError(t, err)
+	assert.Equalf(t, int64(1), c, "short links for other things should be left alone")
+
+	_, err = coll.InsertOne(ctx, bson.D{{Key: "_id", Value: "DDDDDDD"}, {Key: "type", Value: "album"}, {Key: "id", Value: "album-1"}})
+	assert.Truef(t, mongo.IsDuplicateKeyError(err), "each thing should only have one short link")
+
+	return nil
+}
@@ -14,5 +14,8 @@ func NewMongoMigrationProvider() MigrationProvider {
 func (mp *mongoMigrationProvider) Migrations() []Migration {
 	return []Migration{
 		&Migration0001SplitThings{},
+		&Migration0003AddLinkKeys{},
+		&Migration0004AddServiceIds{},
+		&Migration0005AddShortLinkIndex{},
 	}
 }
@@ -2,12 +2,17 @@ package db
 
 import (
 	"context"
+	"errors"
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/yukitsune/maestro/pkg/db/migrations"
 	"github.com/yukitsune/maestro/pkg/metrics"
 	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoRepository struct {
@@ -25,12 +30,11 @@ func (m *mongoRepository) AddArtist(ctx context.Context, artists []*model.Artist
 	m.ensureMigrationsHaveExecuted(ctx)
 
 	coll := m.db.Collection(model.ArtistCollectionName)
-	insertRes, err := coll.InsertMany(ctx, artistsToInterfaces(artists))
-	if err != nil {
-		return 0, err
+	for _, artist := range artists {
+		artist.LinkKey = linkKeyOf(artist)
 	}
 
-	return len(insertRes.InsertedIDs), nil
+	return insertOrAlias(ctx, coll, artists)
 }
 
 func (m *mongoRepository) GetArtistsById(ctx context.Context, id string) ([]*model.Artist, error) {
@@ -57,7 +61,7 @@ func (m *mongoRepository) GetArtistByLink(ctx context.Context, link string) (*mo
 	// Find an artist with a matching link
 	var foundArtist *model.Artist
 	coll := m.db.Collection(model.ArtistCollectionName)
-	res := coll.FindOne(ctx, bson.D{{"link", link}})
+	res := coll.FindOne(ctx, linkFilter(link))
 	err := res.Err()
 
 	// No matches? Error time
@@ -86,12 +90,11 @@ func (m *mongoRepository) AddAlbum(ctx context.Context, albums []*model.Album) (
 	m.ensureMigrationsHaveExecuted(ctx)
 
 	coll := m.db.Collection(model.AlbumCollectionName)
-	insertRes, err := coll.InsertMany(ctx, albumsToInterfaces(albums))
-	if err != nil {
-		return 0, err
+	for _, album := range albums {
+		album.LinkKey = linkKeyOf(album)
 	}
 
-	return len(insertRes.InsertedIDs), nil
+	return insertOrAlias(ctx, coll, albums)
 }
 
 func (m *mongoRepository) GetAlbumsById(ctx context.Context, id string) ([]*model.Album, error) {
@@ -118,7 +121,7 @@ func (m *mongoRepository) GetAlbumByLink(ctx context.Context, link string) (*mod
 	// Find an album with a matching link
 	var foundAlbum *model.Album
 	coll := m.db.Collection(model.AlbumCollectionName)
-	res := coll.FindOne(ctx, bson.D{{"link", link}})
+	res := coll.FindOne(ctx, linkFilter(link))
 	err := res.Err()
 
 	// No matches? Error time
@@ -142,17 +145,43 @@ func (m *mongoRepository) GetAlbumByLink(ctx context.Context, link string) (*mod
 	return foundAlbum, nil
 }
 
+func (m *mongoRepository) GetAlbumByUpc(ctx context.Context, upc string) (*model.Album, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.AlbumCollectionName)
+	res := coll.FindOne(ctx, bson.D{
+		{Key: "upc", Value: upc},
+		{Key: "dead", Value: bson.D{{Key: "$ne", Value: true}}},
+	})
+
+	err := res.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	raw, err := res.DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshal[model.Album](raw)
+}
+
 func (m *mongoRepository) AddTracks(ctx context.Context, tracks []*model.Track) (int, error) {
 	go m.rec.CountDatabaseCall()
 	m.ensureMigrationsHaveExecuted(ctx)
 
 	coll := m.db.Collection(model.TrackCollectionName)
-	insertRes, err := coll.InsertMany(ctx, tracksToInterfaces(tracks))
-	if err != nil {
-		return 0, err
+	for _, track := range tracks {
+		track.LinkKey = linkKeyOf(track)
 	}
 
-	return len(insertRes.InsertedIDs), nil
+	return insertOrAlias(ctx, coll, tracks)
 }
 
 func (m *mongoRepository) GetTracksByLegacyId(ctx context.Context, id string) ([]*model.Track, error) {
@@ -196,7 +225,7 @@ func (m *mongoRepository) GetTrackByLink(ctx context.Context, link string) (*mod
 	// Find a track with a matching link
 	var foundTrack *model.Track
 	coll := m.db.Collection(model.TrackCollectionName)
-	res := coll.FindOne(ctx, bson.D{{"link", link}})
+	res := coll.FindOne(ctx, linkFilter(link))
 	err := res.Err()
 
 	// No matches? Error time
@@ -252,31 +281,431 @@ func (m *mongoRepository) GetByLink(ctx context.Context, link string) (model.Typ
 	return model.UnknownType, nil, nil
 }
 
-func artistsToInterfaces(artists []*model.Artist) []interface{} {
-	var s []interface{}
-	for _, artist := range artists {
-		s = append(s, artist)
+func (m *mongoRepository) GetIncompleteArtistIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return m.getIncompleteIds(ctx, model.ArtistCollectionName, "artistid", services, after, limit)
+}
+
+func (m *mongoRepository) GetIncompleteAlbumIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return m.getIncompleteIds(ctx, model.AlbumCollectionName, "albumid", services, after, limit)
+}
+
+func (m *mongoRepository) GetIncompleteIsrcs(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	return m.getIncompleteIds(ctx, model.TrackCollectionName, "isrc", services, after, limit)
+}
+
+// getIncompleteIds groups the collection by the given ID field, and finds the groups which don't have
+// a document for every service
+func (m *mongoRepository) getIncompleteIds(ctx context.Context, collectionName string, idField string, services []model.StreamingServiceType, after string, limit int) ([]string, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: idField, Value: bson.D{{Key: "$gt", Value: after}}},
+
+			// Dead links don't count, the thing needs to be found again on that service
+			{Key: "dead", Value: bson.D{{Key: "$ne", Value: true}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$" + idField},
+			{Key: "sources", Value: bson.D{{Key: "$addToSet", Value: "$source"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "sources", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$all", Value: services}}}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
 	}
 
-	return s
+	coll := m.db.Collection(collectionName)
+	cur, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		Id string `bson:"_id"`
+	}
+
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, group := range groups {
+		ids = append(ids, group.Id)
+	}
+
+	return ids, nil
 }
 
-func albumsToInterfaces(albums []*model.Album) []interface{} {
-	var s []interface{}
-	for _, album := range albums {
-		s = append(s, album)
+func (m *mongoRepository) GetArtistsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Artist, error) {
+	return findToVerify[model.Artist](ctx, m, model.ArtistCollectionName, services, checkedBefore, limit)
+}
+
+func (m *mongoRepository) GetAlbumsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Album, error) {
+	return findToVerify[model.Album](ctx, m, model.AlbumCollectionName, services, checkedBefore, limit)
+}
+
+func (m *mongoRepository) GetTracksToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Track, error) {
+	return findToVerify[model.Track](ctx, m, model.TrackCollectionName, services, checkedBefore, limit)
+}
+
+// findToVerify finds documents which were last checked before the given time, or never at all.
+// Missing and null values sort first in Mongo, so the documents which have never been checked come first.
+func findToVerify[T any](ctx context.Context, m *mongoRepository, collectionName string, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*T, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	filter := bson.D{
+		{Key: "source", Value: bson.D{{Key: "$in", Value: services}}},
+		{Key: "lastchecked", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: checkedBefore}}}}},
 	}
 
-	return s
+	opts := options.Find().
+		SetSort(bson.D{{Key: "lastchecked", Value: 1}}).
+		SetLimit(int64(limit))
+
+	coll := m.db.Collection(collectionName)
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalFromCursor[T](ctx, cur)
 }
 
-func tracksToInterfaces(tracks []*model.Track) []interface{} {
-	var s []interface{}
-	for _, track := range tracks {
-		s = append(s, track)
+func (m *mongoRepository) UpdateArtistHealth(ctx context.Context, artist *model.Artist) error {
+	return m.updateHealth(ctx, model.ArtistCollectionName, artist.Link, artist.LastChecked, artist.LastVerified, artist.Dead)
+}
+
+func (m *mongoRepository) UpdateAlbumHealth(ctx context.Context, album *model.Album) error {
+	return m.updateHealth(ctx, model.AlbumCollectionName, album.Link, album.LastChecked, album.LastVerified, album.Dead)
+}
+
+func (m *mongoRepository) UpdateAlbumId(ctx context.Context, album *model.Album, id string) error {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.AlbumCollectionName)
+	_, err := coll.UpdateMany(ctx, bson.D{{Key: "link", Value: album.Link}}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "albumid", Value: id}}},
+	})
+
+	return err
+}
+
+func (m *mongoRepository) UpdateTrackHealth(ctx context.Context, track *model.Track) error {
+	return m.updateHealth(ctx, model.TrackCollectionName, track.Link, track.LastChecked, track.LastVerified, track.Dead)
+}
+
+func (m *mongoRepository) updateHealth(ctx context.Context, collectionName string, link string, lastChecked *time.Time, lastVerified *time.Time, dead bool) error {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(collectionName)
+	_, err := coll.UpdateMany(ctx, bson.D{{Key: "link", Value: link}}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "lastchecked", Value: lastChecked},
+			{Key: "lastverified", Value: lastVerified},
+			{Key: "dead", Value: dead},
+		}},
+	})
+
+	return err
+}
+
+func (m *mongoRepository) AddShortLink(ctx context.Context, link *model.ShortLink) error {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	// Codes are stored as the document ID, and what they point to is uniquely indexed, so Mongo makes sure
+	// that neither is used twice
+	coll := m.db.Collection(model.ShortLinkCollectionName)
+	_, err := coll.InsertOne(ctx, link)
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) && writeErr.HasErrorMessage(migrations.ShortLinkTargetIndexName) {
+		return ErrShortLinkExists
+	}
+
+	return ErrShortCodeTaken
+}
+
+func (m *mongoRepository) GetShortLink(ctx context.Context, code string) (*model.ShortLink, error) {
+	return m.findShortLink(ctx, bson.D{{Key: "_id", Value: code}})
+}
+
+func (m *mongoRepository) GetShortLinkFor(ctx context.Context, typ model.Type, id string) (*model.ShortLink, error) {
+	return m.findShortLink(ctx, bson.D{
+		{Key: "type", Value: typ},
+		{Key: "id", Value: id},
+	})
+}
+
+func (m *mongoRepository) findShortLink(ctx context.Context, filter bson.D) (*model.ShortLink, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.ShortLinkCollectionName)
+	res := coll.FindOne(ctx, filter)
+
+	err := res.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	raw, err := res.DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshal[model.ShortLink](raw)
+}
+
+func (m *mongoRepository) RecordEvent(ctx context.Context, event *model.AnalyticsEvent) error {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	// Each day's events are rolled up into a single count, so there's no need to keep them all
+	coll := m.db.Collection(model.AnalyticsCollectionName)
+	_, err := coll.UpdateOne(ctx,
+		bson.D{
+			{Key: "day", Value: model.Day(event.Time)},
+			{Key: "kind", Value: event.Kind},
+			{Key: "type", Value: event.Type},
+			{Key: "id", Value: event.Id},
+			{Key: "source", Value: event.Source},
+			{Key: "target", Value: event.Target},
+		},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}}},
+		options.Update().SetUpsert(true))
+
+	if err != nil {
+		return err
+	}
+
+	if len(event.IPAddress) == 0 && len(event.UserAgent) == 0 {
+		return nil
+	}
+
+	_, err = m.db.Collection(model.AnalyticsEventCollectionName).InsertOne(ctx, event)
+	return err
+}
+
+func (m *mongoRepository) GetTopThings(ctx context.Context, kind model.AnalyticsEventKind, since time.Time, limit int) ([]*model.ThingStats, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.AnalyticsCollectionName)
+	cur, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "kind", Value: kind},
+			{Key: "day", Value: bson.D{{Key: "$gte", Value: model.Day(since)}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "type", Value: "$type"}, {Key: "id", Value: "$id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id.type", Value: 1}, {Key: "_id.id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "type", Value: "$_id.type"},
+			{Key: "id", Value: "$_id.id"},
+			{Key: "count", Value: 1},
+		}}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalFromCursor[model.ThingStats](ctx, cur)
+}
+
+func (m *mongoRepository) GetServiceStats(ctx context.Context, since time.Time) ([]*model.ServiceStats, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.AnalyticsCollectionName)
+	cur, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "day", Value: bson.D{{Key: "$gte", Value: model.Day(since)}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "kind", Value: "$kind"}, {Key: "source", Value: "$source"}, {Key: "target", Value: "$target"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "kind", Value: "$_id.kind"},
+			{Key: "source", Value: "$_id.source"},
+			{Key: "target", Value: "$_id.target"},
+			{Key: "count", Value: 1},
+		}}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := unmarshalFromCursor[model.AnalyticsCount](ctx, cur)
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceStats(counts), nil
+}
+
+func (m *mongoRepository) GetDailyCounts(ctx context.Context, kind model.AnalyticsEventKind, since time.Time) ([]*model.AnalyticsCount, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	coll := m.db.Collection(model.AnalyticsCollectionName)
+	cur, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "kind", Value: kind},
+			{Key: "day", Value: bson.D{{Key: "$gte", Value: model.Day(since)}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "day", Value: "$day"}, {Key: "type", Value: "$type"}, {Key: "id", Value: "$id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "day", Value: "$_id.day"},
+			{Key: "kind", Value: bson.D{{Key: "$literal", Value: kind}}},
+			{Key: "type", Value: "$_id.type"},
+			{Key: "id", Value: "$_id.id"},
+			{Key: "count", Value: 1},
+		}}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalFromCursor[model.AnalyticsCount](ctx, cur)
+}
+
+func (m *mongoRepository) ReplaceTrending(ctx context.Context, window model.TrendingWindow, scores []*model.TrendingScore) error {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	// The window is briefly empty between these, which is fine for something which is only shown on the landing page
+	coll := m.db.Collection(model.TrendingCollectionName)
+	_, err := coll.DeleteMany(ctx, bson.D{{Key: "window", Value: window}})
+	if err != nil {
+		return err
+	}
+
+	if len(scores) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(scores))
+	for _, score := range scores {
+		docs = append(docs, score)
+	}
+
+	_, err = coll.InsertMany(ctx, docs)
+	return err
+}
+
+func (m *mongoRepository) GetTrending(ctx context.Context, window model.TrendingWindow, typ model.Type, limit int) ([]*model.TrendingScore, error) {
+	go m.rec.CountDatabaseCall()
+	m.ensureMigrationsHaveExecuted(ctx)
+
+	filter := bson.D{{Key: "window", Value: window}}
+	if len(typ) > 0 {
+		filter = append(filter, bson.E{Key: "type", Value: typ})
+	}
+
+	coll := m.db.Collection(model.TrendingCollectionName)
+	cur, err := coll.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "score", Value: -1}}).
+		SetLimit(int64(limit)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalFromCursor[model.TrendingScore](ctx, cur)
+}
+
+// insertOrAlias inserts the given things, except for those with the same link key as something which has
+// already been stored. Their links are added as aliases of the existing things instead, and they take the existing
+// things' IDs so that the caller knows what they're stored as.
+func insertOrAlias[T any, PT interface {
+	*T
+	model.Thing
+	AdoptIdOf(existing *T)
+}](ctx context.Context, coll *mongo.Collection, things []*T) (int, error) {
+	var toInsert []interface{}
+	for _, thing := range things {
+		key := PT(thing).GetLinkKey()
+		if len(key) == 0 {
+			toInsert = append(toInsert, thing)
+			continue
+		}
+
+		raw, err := coll.FindOne(ctx, bson.D{{Key: "linkkey", Value: key}}).DecodeBytes()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			toInsert = append(toInsert, thing)
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		existing, err := unmarshal[T](raw)
+		if err != nil {
+			return 0, err
+		}
+
+		PT(thing).AdoptIdOf(existing)
+
+		link := PT(thing).GetLink()
+		_, err = coll.UpdateMany(ctx, bson.D{
+			{Key: "linkkey", Value: key},
+			{Key: "link", Value: bson.D{{Key: "$ne", Value: link}}},
+		}, bson.D{
+			{Key: "$addToSet", Value: bson.D{{Key: "aliases", Value: link}}},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(toInsert) == 0 {
+		return 0, nil
+	}
+
+	insertRes, err := coll.InsertMany(ctx, toInsert)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(insertRes.InsertedIDs), nil
+}
+
+// linkFilter matches things with the given link, either as their link, one of their aliases, or by the link's key
+func linkFilter(link string) bson.D {
+	matches := bson.A{
+		bson.D{{Key: "link", Value: link}},
+		bson.D{{Key: "aliases", Value: link}},
+	}
+
+	if key, ok := streamingservice.LinkKey(link); ok {
+		matches = append(matches, bson.D{{Key: "linkkey", Value: key}})
 	}
 
-	return s
+	return bson.D{{Key: "$or", Value: matches}}
 }
 
 func (m *mongoRepository) ensureMigrationsHaveExecuted(ctx context.Context) {
@@ -2,10 +2,23 @@ package db
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"time"
+
 	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
 )
 
+// ErrShortCodeTaken is returned when adding a short link with a code which is already in use
+var ErrShortCodeTaken = errors.New("short code is already taken")
+
+// ErrShortLinkExists is returned when adding a short link for something which already has one
+var ErrShortLinkExists = errors.New("short link already exists")
+
 type Repository interface {
+	// AddArtist, AddAlbum, and AddTracks store new things, and return how many were stored.
+	// Anything with the same link key as something already stored is added as an alias of it instead.
 	AddArtist(ctx context.Context, artists []*model.Artist) (int, error)
 	GetArtistsById(ctx context.Context, id string) ([]*model.Artist, error)
 	GetArtistByLink(ctx context.Context, link string) (*model.Artist, error)
@@ -14,10 +27,112 @@ type Repository interface {
 	GetAlbumsById(ctx context.Context, id string) ([]*model.Album, error)
 	GetAlbumByLink(ctx context.Context, link string) (*model.Album, error)
 
+	// UpdateAlbumId moves the album with the same link into the group with the given ID, for when it turns out to be
+	// the same as albums it wasn't matched up with before
+	UpdateAlbumId(ctx context.Context, album *model.Album, id string) error
+
+	// GetAlbumByUpc finds any live album with the given UPC. Albums with the same UPC share the same ID,
+	// so GetAlbumsById can be used to find it on the other services.
+	GetAlbumByUpc(ctx context.Context, upc string) (*model.Album, error)
+
 	AddTracks(ctx context.Context, tracks []*model.Track) (int, error)
 	GetTracksByLegacyId(ctx context.Context, id string) ([]*model.Track, error)
 	GetTracksByIsrc(ctx context.Context, isrc string) ([]*model.Track, error)
 	GetTrackByLink(ctx context.Context, link string) (*model.Track, error)
 
+	// GetByLink finds whatever the given link points to. Links are matched exactly, against any aliases,
+	// and by their link key, so links written differently can still find the same thing.
 	GetByLink(ctx context.Context, link string) (model.Type, any, error)
+
+	// GetIncompleteArtistIds, GetIncompleteAlbumIds, and GetIncompleteIsrcs find things which are missing from
+	// at least one of the given services. Results are sorted, and only those after the given ID are returned
+	// so that callers can page through them.
+	GetIncompleteArtistIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error)
+	GetIncompleteAlbumIds(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error)
+	GetIncompleteIsrcs(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error)
+
+	// GetArtistsToVerify, GetAlbumsToVerify, and GetTracksToVerify find things from the given services whose links
+	// haven't been checked since the given time. Things which have never been checked come first,
+	// followed by the least recently checked.
+	GetArtistsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Artist, error)
+	GetAlbumsToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Album, error)
+	GetTracksToVerify(ctx context.Context, services []model.StreamingServiceType, checkedBefore time.Time, limit int) ([]*model.Track, error)
+
+	// UpdateArtistHealth, UpdateAlbumHealth, and UpdateTrackHealth save the LastChecked, LastVerified, and Dead fields
+	// of the thing with the same link
+	UpdateArtistHealth(ctx context.Context, artist *model.Artist) error
+	UpdateAlbumHealth(ctx context.Context, album *model.Album) error
+	UpdateTrackHealth(ctx context.Context, track *model.Track) error
+
+	// AddShortLink stores a new short link, returning ErrShortCodeTaken if its code is already in use,
+	// or ErrShortLinkExists if what it points to already has a short link
+	AddShortLink(ctx context.Context, link *model.ShortLink) error
+
+	// GetShortLink and GetShortLinkFor find short links by their code, or by what they point to.
+	// Both return nil if there's no such short link.
+	GetShortLink(ctx context.Context, code string) (*model.ShortLink, error)
+	GetShortLinkFor(ctx context.Context, typ model.Type, id string) (*model.ShortLink, error)
+
+	// RecordEvent adds the event to the daily count for its thing and services.
+	// The event itself is only kept if it has an IP address or user agent, i.e. when privacy mode is off.
+	RecordEvent(ctx context.Context, event *model.AnalyticsEvent) error
+
+	// GetTopThings finds the things with the most events of the given kind since the given day, most popular first
+	GetTopThings(ctx context.Context, kind model.AnalyticsEventKind, since time.Time, limit int) ([]*model.ThingStats, error)
+
+	// GetServiceStats counts the clicks to, and resolutions from, each service since the given day
+	GetServiceStats(ctx context.Context, since time.Time) ([]*model.ServiceStats, error)
+
+	// GetDailyCounts finds how many events of the given kind each thing has had each day since the given day.
+	// Counts for different services on the same day are added together.
+	GetDailyCounts(ctx context.Context, kind model.AnalyticsEventKind, since time.Time) ([]*model.AnalyticsCount, error)
+
+	// ReplaceTrending swaps the trending scores for the given window with the given ones
+	ReplaceTrending(ctx context.Context, window model.TrendingWindow, scores []*model.TrendingScore) error
+
+	// GetTrending finds the highest trending scores for the given window, optionally only for the given type
+	GetTrending(ctx context.Context, window model.TrendingWindow, typ model.Type, limit int) ([]*model.TrendingScore, error)
+}
+
+// linkKeyOf returns the thing's link key, working it out from the link if it hasn't been set
+func linkKeyOf(thing model.Thing) string {
+	if key := thing.GetLinkKey(); len(key) > 0 {
+		return key
+	}
+
+	key, _ := streamingservice.LinkKey(thing.GetLink())
+	return key
+}
+
+// serviceStats adds up the clicks and resolutions for each service
+func serviceStats(counts []*model.AnalyticsCount) []*model.ServiceStats {
+	byService := make(map[model.StreamingServiceType]*model.ServiceStats)
+	statsFor := func(service model.StreamingServiceType) *model.ServiceStats {
+		if _, ok := byService[service]; !ok {
+			byService[service] = &model.ServiceStats{Service: service}
+		}
+
+		return byService[service]
+	}
+
+	for _, count := range counts {
+		switch {
+		case count.Kind == model.ClickEvent && len(count.Target) > 0:
+			statsFor(count.Target).Clicks += count.Count
+
+		case count.Kind == model.ResolveEvent && len(count.Source) > 0:
+			statsFor(count.Source).Resolutions += count.Count
+		}
+	}
+
+	stats := make([]*model.ServiceStats, 0, len(byService))
+	for _, s := range byService {
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Service < stats[j].Service
+	})
+
+	return stats
 }
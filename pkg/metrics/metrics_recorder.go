@@ -1,13 +1,19 @@
 package metrics
 
+import "github.com/yukitsune/maestro/pkg/model"
+
 type Recorder interface {
 	ReportRequestDuration(path string, fn func())
 
 	CountServerError()
 
 	CountDatabaseCall()
+	CountCacheHit()
+	CountCacheMiss()
 
 	CountAppleMusicRequest()
 	CountSpotifyRequest()
 	CountDeezerRequest()
+	CountThrottledRequest(service string)
+	ReportCircuitState(service string, state model.CircuitState)
 }
@@ -0,0 +1,32 @@
+package metrics
+
+import "github.com/yukitsune/maestro/pkg/model"
+
+type noopMetricsRecorder struct{}
+
+// NewNoopMetricsRecorder creates a Recorder which discards everything it's given
+func NewNoopMetricsRecorder() Recorder {
+	return &noopMetricsRecorder{}
+}
+
+func (n *noopMetricsRecorder) ReportRequestDuration(_ string, fn func()) {
+	fn()
+}
+
+func (n *noopMetricsRecorder) CountServerError() {}
+
+func (n *noopMetricsRecorder) CountDatabaseCall() {}
+
+func (n *noopMetricsRecorder) CountCacheHit() {}
+
+func (n *noopMetricsRecorder) CountCacheMiss() {}
+
+func (n *noopMetricsRecorder) CountAppleMusicRequest() {}
+
+func (n *noopMetricsRecorder) CountSpotifyRequest() {}
+
+func (n *noopMetricsRecorder) CountDeezerRequest() {}
+
+func (n *noopMetricsRecorder) CountThrottledRequest(_ string) {}
+
+func (n *noopMetricsRecorder) ReportCircuitState(_ string, _ model.CircuitState) {}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yukitsune/maestro/pkg/model"
 	"time"
 )
 
@@ -9,6 +10,8 @@ type prometheusMetricsRecorder struct {
 	requestDurationHistogram *prometheus.HistogramVec
 
 	databaseCallCounter prometheus.Counter
+	cacheHitCounter     prometheus.Counter
+	cacheMissCounter    prometheus.Counter
 
 	serverErrorCounter prometheus.Counter
 	clientErrorCounter prometheus.Counter
@@ -16,6 +19,9 @@ type prometheusMetricsRecorder struct {
 	appleMusicRequestCounter prometheus.Counter
 	spotifyRequestCounter    prometheus.Counter
 	deezerRequestCounter     prometheus.Counter
+
+	throttledRequestCounter *prometheus.CounterVec
+	circuitStateGauge       *prometheus.GaugeVec
 }
 
 func NewPrometheusMetricsRecorder() (Recorder, error) {
@@ -57,6 +63,24 @@ func NewPrometheusMetricsRecorder() (Recorder, error) {
 		return nil, err
 	}
 
+	cacheHitCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "maestro_cache_hit_count",
+		Help: "The total number of repository lookups served from the cache",
+	})
+
+	if err := prometheus.Register(cacheHitCounter); err != nil {
+		return nil, err
+	}
+
+	cacheMissCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "maestro_cache_miss_count",
+		Help: "The total number of repository lookups which missed the cache",
+	})
+
+	if err := prometheus.Register(cacheMissCounter); err != nil {
+		return nil, err
+	}
+
 	amCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "maestro_apple_music_request_count",
 		Help: "The total number of requests sent to the Apple Music API",
@@ -84,14 +108,36 @@ func NewPrometheusMetricsRecorder() (Recorder, error) {
 		return nil, err
 	}
 
+	throttledCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "maestro_throttled_request_count",
+		Help: "The total number of requests which were throttled by a streaming service API",
+	}, []string{"service"})
+
+	if err := prometheus.Register(throttledCounter); err != nil {
+		return nil, err
+	}
+
+	circuitStateGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "maestro_circuit_breaker_state",
+		Help: "The state of each streaming service's circuit breaker (0 = closed, 1 = half-open, 2 = open)",
+	}, []string{"service"})
+
+	if err := prometheus.Register(circuitStateGauge); err != nil {
+		return nil, err
+	}
+
 	rec := &prometheusMetricsRecorder{
 		reqDur,
 		dbCounter,
+		cacheHitCounter,
+		cacheMissCounter,
 		serverErrorCounter,
 		clientErrorCounter,
 		amCounter,
 		spCounter,
 		dzCounter,
+		throttledCounter,
+		circuitStateGauge,
 	}
 
 	return rec, nil
@@ -101,6 +147,14 @@ func (p prometheusMetricsRecorder) CountDatabaseCall() {
 	p.databaseCallCounter.Inc()
 }
 
+func (p prometheusMetricsRecorder) CountCacheHit() {
+	p.cacheHitCounter.Inc()
+}
+
+func (p prometheusMetricsRecorder) CountCacheMiss() {
+	p.cacheMissCounter.Inc()
+}
+
 func (p prometheusMetricsRecorder) CountServerError() {
 	p.serverErrorCounter.Inc()
 }
@@ -123,3 +177,21 @@ func (p prometheusMetricsRecorder) CountSpotifyRequest() {
 func (p prometheusMetricsRecorder) CountDeezerRequest() {
 	p.deezerRequestCounter.Inc()
 }
+
+func (p prometheusMetricsRecorder) CountThrottledRequest(service string) {
+	p.throttledRequestCounter.WithLabelValues(service).Inc()
+}
+
+func (p prometheusMetricsRecorder) ReportCircuitState(service string, state model.CircuitState) {
+	var value float64
+	switch state {
+	case model.CircuitClosed:
+		value = 0
+	case model.CircuitHalfOpen:
+		value = 1
+	case model.CircuitOpen:
+		value = 2
+	}
+
+	p.circuitStateGauge.WithLabelValues(service).Set(value)
+}
@@ -1,9 +1,12 @@
 package model
 
+import "time"
+
 const AlbumCollectionName = "albums"
 
 type Album struct {
 	AlbumId     string
+	Upc         string
 	Name        string
 	ArtistNames []string
 	ArtworkLink string
@@ -11,6 +14,29 @@ type Album struct {
 	Source StreamingServiceType
 	Market Market
 	Link   string
+
+	// ServiceId is the ID of the thing on the streaming service it's from
+	ServiceId string `json:",omitempty"`
+
+	// Storefront is the Apple Music storefront the thing was found in, other services don't have storefronts
+	Storefront string `json:",omitempty"`
+
+	// Everything from here on is only for keeping track of what we've stored, so it's left out of responses
+
+	// LinkKey identifies what Link points to, so the same thing can be found no matter how its link is written
+	LinkKey string `json:"-"`
+
+	// Aliases are the other links which have been seen for the same thing
+	Aliases []string `json:"-"`
+
+	// LastVerified is when the service last told us whether the link still exists
+	LastVerified *time.Time `json:"-"`
+
+	// LastChecked is when we last asked the service about the link, even if it didn't give us an answer
+	LastChecked *time.Time `json:"-"`
+
+	// Dead is set when the service no longer knows about the link
+	Dead bool `json:"-"`
 }
 
 func NewAlbum(name string, artistNames []string, artworkLink string, source StreamingServiceType, market Market, link string) *Album {
@@ -27,3 +53,34 @@ func NewAlbum(name string, artistNames []string, artworkLink string, source Stre
 func (a *Album) GetSource() StreamingServiceType {
 	return a.Source
 }
+
+func (a *Album) GetLink() string {
+	return a.Link
+}
+
+func (a *Album) GetArtworkLink() string {
+	return a.ArtworkLink
+}
+
+func (a *Album) IsDead() bool {
+	return a.Dead
+}
+
+func (a *Album) GetLinkKey() string {
+	return a.LinkKey
+}
+
+func (a *Album) HasLink(link string) bool {
+	return hasLink(link, a.Link, a.Aliases)
+}
+
+func (a *Album) AddAlias(link string) {
+	if !a.HasLink(link) {
+		a.Aliases = append(a.Aliases, link)
+	}
+}
+
+// AdoptIdOf gives the album the same ID as an existing one, for when they turn out to be the same album
+func (a *Album) AdoptIdOf(existing *Album) {
+	a.AlbumId = existing.AlbumId
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+const (
+	AnalyticsCollectionName      = "analytics"
+	AnalyticsEventCollectionName = "analytics_events"
+)
+
+type AnalyticsEventKind string
+
+const (
+	// ClickEvent is someone following a link out to a streaming service
+	ClickEvent AnalyticsEventKind = "click"
+
+	// ResolveEvent is someone looking up a link to find the same thing on the other services
+	ResolveEvent AnalyticsEventKind = "resolve"
+)
+
+// AnalyticsEvent is a single click or resolution.
+// Source is the service of the link which was looked up, and Target is the service which was clicked through to.
+type AnalyticsEvent struct {
+	Kind   AnalyticsEventKind
+	Type   Type
+	Id     string
+	Source StreamingServiceType `json:",omitempty"`
+	Target StreamingServiceType `json:",omitempty"`
+	Time   time.Time
+
+	// IPAddress and UserAgent are left empty in privacy mode
+	IPAddress string `json:",omitempty"`
+	UserAgent string `json:",omitempty"`
+}
+
+// AnalyticsCount is the number of events for a thing on a given day
+type AnalyticsCount struct {
+	Day    time.Time
+	Kind   AnalyticsEventKind
+	Type   Type
+	Id     string
+	Source StreamingServiceType
+	Target StreamingServiceType
+	Count  int
+}
+
+// ThingStats is how often a thing has been clicked through to, or looked up
+type ThingStats struct {
+	Type  Type
+	Id    string
+	Count int
+}
+
+// ServiceStats is how often a service has been clicked through to, and how often links from it have been looked up
+type ServiceStats struct {
+	Service     StreamingServiceType
+	Clicks      int
+	Resolutions int
+}
+
+// Day is the start of the day (in UTC) the given time is in
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 const ArtistCollectionName = "artists"
 
 type Artist struct {
@@ -10,6 +12,29 @@ type Artist struct {
 	Source StreamingServiceType
 	Market Market
 	Link   string
+
+	// ServiceId is the ID of the thing on the streaming service it's from
+	ServiceId string `json:",omitempty"`
+
+	// Storefront is the Apple Music storefront the thing was found in, other services don't have storefronts
+	Storefront string `json:",omitempty"`
+
+	// Everything from here on is only for keeping track of what we've stored, so it's left out of responses
+
+	// LinkKey identifies what Link points to, so the same thing can be found no matter how its link is written
+	LinkKey string `json:"-"`
+
+	// Aliases are the other links which have been seen for the same thing
+	Aliases []string `json:"-"`
+
+	// LastVerified is when the service last told us whether the link still exists
+	LastVerified *time.Time `json:"-"`
+
+	// LastChecked is when we last asked the service about the link, even if it didn't give us an answer
+	LastChecked *time.Time `json:"-"`
+
+	// Dead is set when the service no longer knows about the link
+	Dead bool `json:"-"`
 }
 
 func NewArtist(name string, artworkLink string, source StreamingServiceType, market Market, link string) *Artist {
@@ -25,3 +50,34 @@ func NewArtist(name string, artworkLink string, source StreamingServiceType, mar
 func (a *Artist) GetSource() StreamingServiceType {
 	return a.Source
 }
+
+func (a *Artist) GetLink() string {
+	return a.Link
+}
+
+func (a *Artist) GetArtworkLink() string {
+	return a.ArtworkLink
+}
+
+func (a *Artist) IsDead() bool {
+	return a.Dead
+}
+
+func (a *Artist) GetLinkKey() string {
+	return a.LinkKey
+}
+
+func (a *Artist) HasLink(link string) bool {
+	return hasLink(link, a.Link, a.Aliases)
+}
+
+func (a *Artist) AddAlias(link string) {
+	if !a.HasLink(link) {
+		a.Aliases = append(a.Aliases, link)
+	}
+}
+
+// AdoptIdOf gives the artist the same ID as an existing one, for when they turn out to be the same artist
+func (a *Artist) AdoptIdOf(existing *Artist) {
+	a.ArtistId = existing.ArtistId
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const ShortLinkCollectionName = "short_links"
+
+// ShortLink is a short code which points to an artist, album, or track.
+// It points to the ID rather than any particular link, so it keeps working as links are found on more services.
+type ShortLink struct {
+	Code string `bson:"_id"`
+	Type Type
+	Id   string
+
+	Created time.Time
+}
+
+func NewShortLink(code string, typ Type, id string) *ShortLink {
+	return &ShortLink{
+		Code:    code,
+		Type:    typ,
+		Id:      id,
+		Created: time.Now().UTC(),
+	}
+}
@@ -12,8 +12,26 @@ func (s StreamingServiceType) String() string {
 	return string(s)
 }
 
+type CircuitState string
+
+const (
+	// CircuitClosed means the service is healthy and requests are sent to it
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen means the service has been failing and requests to it are skipped
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen means the service has been failing, but we're checking if it's recovered
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+func (s CircuitState) String() string {
+	return string(s)
+}
+
 type StreamingService struct {
 	Key     StreamingServiceType
 	Name    string
 	Enabled bool
+	State   CircuitState
 }
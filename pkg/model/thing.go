@@ -2,4 +2,27 @@ package model
 
 type Thing interface {
 	GetSource() StreamingServiceType
+	GetLink() string
+	GetArtworkLink() string
+	IsDead() bool
+
+	// GetLinkKey returns the key which identifies what the link points to, if it's known
+	GetLinkKey() string
+
+	// HasLink returns true if the given link is the thing's link, or one of its aliases
+	HasLink(link string) bool
+}
+
+func hasLink(link string, primary string, aliases []string) bool {
+	if link == primary {
+		return true
+	}
+
+	for _, alias := range aliases {
+		if link == alias {
+			return true
+		}
+	}
+
+	return false
 }
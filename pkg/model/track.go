@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 const TrackCollectionName = "tracks"
 
 type Track struct {
@@ -12,6 +14,29 @@ type Track struct {
 	Source StreamingServiceType
 	Market Market
 	Link   string
+
+	// ServiceId is the ID of the thing on the streaming service it's from
+	ServiceId string `json:",omitempty"`
+
+	// Storefront is the Apple Music storefront the thing was found in, other services don't have storefronts
+	Storefront string `json:",omitempty"`
+
+	// Everything from here on is only for keeping track of what we've stored, so it's left out of responses
+
+	// LinkKey identifies what Link points to, so the same thing can be found no matter how its link is written
+	LinkKey string `json:"-"`
+
+	// Aliases are the other links which have been seen for the same thing
+	Aliases []string `json:"-"`
+
+	// LastVerified is when the service last told us whether the link still exists
+	LastVerified *time.Time `json:"-"`
+
+	// LastChecked is when we last asked the service about the link, even if it didn't give us an answer
+	LastChecked *time.Time `json:"-"`
+
+	// Dead is set when the service no longer knows about the link
+	Dead bool `json:"-"`
 }
 
 func NewTrack(isrc string, name string, artistNames []string, albumName string, artworkLink string, source StreamingServiceType, market Market, link string) *Track {
@@ -30,3 +55,34 @@ func NewTrack(isrc string, name string, artistNames []string, albumName string,
 func (t *Track) GetSource() StreamingServiceType {
 	return t.Source
 }
+
+func (t *Track) GetLink() string {
+	return t.Link
+}
+
+func (t *Track) GetArtworkLink() string {
+	return t.ArtworkLink
+}
+
+func (t *Track) IsDead() bool {
+	return t.Dead
+}
+
+func (t *Track) GetLinkKey() string {
+	return t.LinkKey
+}
+
+func (t *Track) HasLink(link string) bool {
+	return hasLink(link, t.Link, t.Aliases)
+}
+
+func (t *Track) AddAlias(link string) {
+	if !t.HasLink(link) {
+		t.Aliases = append(t.Aliases, link)
+	}
+}
+
+// AdoptIdOf gives the track the same ISRC as an existing one, for when they turn out to be the same track
+func (t *Track) AdoptIdOf(existing *Track) {
+	t.Isrc = existing.Isrc
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+const TrendingCollectionName = "trending"
+
+type TrendingWindow string
+
+const (
+	TrendingDay  TrendingWindow = "24h"
+	TrendingWeek TrendingWindow = "7d"
+)
+
+var TrendingWindows = []TrendingWindow{TrendingDay, TrendingWeek}
+
+// Duration is how far back the window looks
+func (w TrendingWindow) Duration() time.Duration {
+	switch w {
+	case TrendingDay:
+		return 24 * time.Hour
+	case TrendingWeek:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// HalfLife is how long it takes for a resolution to count for half as much.
+// It's a quarter of the window, so anything from the start of the window counts for a 16th of something from now.
+func (w TrendingWindow) HalfLife() time.Duration {
+	return w.Duration() / 4
+}
+
+// TrendingScore is how popular a thing has been recently, based on how often it's been looked up
+type TrendingScore struct {
+	Window  TrendingWindow
+	Type    Type
+	Id      string
+	Score   float64
+	Updated time.Time
+}
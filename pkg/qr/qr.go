@@ -0,0 +1,198 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+)
+
+var ErrTooSmall = errors.New("the QR code doesn't fit in the given size")
+
+type Level string
+
+const (
+	LevelLow      Level = "L"
+	LevelMedium   Level = "M"
+	LevelQuartile Level = "Q"
+	LevelHigh     Level = "H"
+)
+
+func (l Level) recoveryLevel() (qrcode.RecoveryLevel, bool) {
+	switch l {
+	case LevelLow:
+		return qrcode.Low, true
+	case LevelMedium:
+		return qrcode.Medium, true
+	case LevelQuartile:
+		return qrcode.High, true
+	case LevelHigh:
+		return qrcode.Highest, true
+	default:
+		return 0, false
+	}
+}
+
+// Options control how a QR code is drawn
+type Options struct {
+	// Size is the width and height of the image in pixels
+	Size int
+
+	// Level is how much of the code can be damaged (or covered up) before it can't be read
+	Level Level
+
+	// Margin is the width of the blank border around the code, in modules.
+	// Scanners need at least 4 to reliably find the code.
+	Margin int
+}
+
+// Artwork covers this fraction of the code's width. With the highest error correction, up to 30% of the code
+// can be lost, so this leaves some room for the code being scanned at an angle or in bad lighting.
+const artworkRatio = 0.22
+
+// symbol is a QR code laid out on a grid of modules, including the margin
+type symbol struct {
+	modules [][]bool
+	width   int
+	scale   int
+	offset  int
+}
+
+func newSymbol(content string, opts Options) (*symbol, error) {
+	level, ok := opts.Level.recoveryLevel()
+	if !ok {
+		return nil, fmt.Errorf("unknown error correction level %s", opts.Level)
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+
+	// We draw our own margin, so that it can be any size
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	width := len(modules) + 2*opts.Margin
+	scale := opts.Size / width
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: it needs at least %dpx", ErrTooSmall, width)
+	}
+
+	// Modules are always whole pixels, so anything left over is split between the edges
+	offset := (opts.Size-scale*width)/2 + opts.Margin*scale
+
+	return &symbol{modules, width, scale, offset}, nil
+}
+
+// artworkModules is the size of the box (in modules) the artwork is drawn in, and where it starts.
+// The box has an odd size, so it sits exactly in the middle.
+func (s *symbol) artworkModules() (int, int) {
+	n := len(s.modules)
+	size := int(float64(n) * artworkRatio)
+	if size%2 != n%2 {
+		size++
+	}
+
+	return size, (n - size) / 2
+}
+
+// PNG draws the QR code for the given content. If artwork is given, it's drawn on a white box in the middle.
+func PNG(content string, opts Options, artwork image.Image) ([]byte, error) {
+	s, err := newSymbol(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Size, opts.Size))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for y, row := range s.modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			r := image.Rect(s.offset+x*s.scale, s.offset+y*s.scale, s.offset+(x+1)*s.scale, s.offset+(y+1)*s.scale)
+			draw.Draw(img, r, image.Black, image.Point{}, draw.Src)
+		}
+	}
+
+	if artwork != nil {
+		size, start := s.artworkModules()
+		box := image.Rect(
+			s.offset+start*s.scale,
+			s.offset+start*s.scale,
+			s.offset+(start+size)*s.scale,
+			s.offset+(start+size)*s.scale)
+
+		draw.Draw(img, box, image.White, image.Point{}, draw.Src)
+
+		// Leave a module of white around the artwork so it doesn't run into the code
+		inner := box.Inset(s.scale)
+		draw.CatmullRom.Scale(img, fit(artwork.Bounds(), inner), artwork, artwork.Bounds(), draw.Over, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG draws the QR code for the given content. If an artwork link is given, it's linked to from the image
+// rather than embedded, so it's up to whatever shows the image to load it.
+func SVG(content string, opts Options, artworkLink string) ([]byte, error) {
+	s, err := newSymbol(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var path strings.Builder
+	for y, row := range s.modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, s.width, s.width)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, s.width, s.width)
+	fmt.Fprintf(&buf, `<path fill="#000000" d="%s"/>`, path.String())
+
+	if len(artworkLink) > 0 {
+		size, start := s.artworkModules()
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="#ffffff"/>`, start+opts.Margin, start+opts.Margin, size, size)
+		fmt.Fprintf(&buf, `<image href="%s" x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet"/>`,
+			html.EscapeString(artworkLink), start+opts.Margin+1, start+opts.Margin+1, size-2, size-2)
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// fit returns the largest rectangle with the same aspect ratio as src which fits in the middle of dst
+func fit(src image.Rectangle, dst image.Rectangle) image.Rectangle {
+	if src.Dx() <= 0 || src.Dy() <= 0 {
+		return dst
+	}
+
+	w, h := dst.Dx(), dst.Dy()
+	if src.Dx()*h > src.Dy()*w {
+		h = src.Dy() * w / src.Dx()
+	} else {
+		w = src.Dx() * h / src.Dy()
+	}
+
+	min := dst.Min.Add(image.Pt((dst.Dx()-w)/2, (dst.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
@@ -0,0 +1,78 @@
+package qr_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/qr"
+)
+
+const testContent = "https://maestro.test/share/track/USUM71703861"
+
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+func Test_PNG(t *testing.T) {
+	b, err := qr.PNG(testContent, qr.Options{Size: 300, Level: qr.LevelMedium, Margin: 4}, nil)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+
+	// The margin is blank, and the finder pattern in the top left corner starts straight after it
+	assert.False(t, isDark(img.At(0, 0)))
+	assert.False(t, isDark(img.At(299, 299)))
+
+	// The URL fits in a version 3 code (29 modules), plus 8 modules of margin is 37, so each module is 8px
+	// and there's 2px left over on each side
+	assert.False(t, isDark(img.At(2+4*8-1, 2+4*8-1)))
+	assert.True(t, isDark(img.At(2+4*8, 2+4*8)))
+}
+
+func Test_PNGWithArtwork(t *testing.T) {
+	artwork := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			artwork.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	b, err := qr.PNG(testContent, qr.Options{Size: 300, Level: qr.LevelHigh, Margin: 4}, artwork)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	r, g, b2, _ := img.At(150, 150).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.Zero(t, g)
+	assert.Zero(t, b2)
+}
+
+func Test_SVG(t *testing.T) {
+	b, err := qr.SVG(testContent, qr.Options{Size: 256, Level: qr.LevelLow, Margin: 2}, `https://images.test/a.jpg?x=1&y="2"`)
+	require.NoError(t, err)
+
+	svg := string(b)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"`))
+	assert.True(t, strings.HasSuffix(svg, `</svg>`))
+	assert.Contains(t, svg, `<path fill="#000000" d="M2 2h1v1h-1z`)
+	assert.Contains(t, svg, `href="https://images.test/a.jpg?x=1&amp;y=&#34;2&#34;"`)
+}
+
+func Test_Errors(t *testing.T) {
+	_, err := qr.PNG(testContent, qr.Options{Size: 20, Level: qr.LevelMedium, Margin: 4}, nil)
+	assert.ErrorIs(t, err, qr.ErrTooSmall)
+
+	_, err = qr.SVG(testContent, qr.Options{Size: 256, Level: "X", Margin: 4}, "")
+	assert.Error(t, err)
+}
@@ -42,11 +42,12 @@ type Song struct {
 
 type SongAttributes struct {
 	Isrc        string
-	AlbumName   string //(Required) The name of the album the song appears on.
-	ArtistName  string //(Required) The artist’s name.
-	TrackNumber int    //(Required) The track number.
-	Name        string //(Required) The localized name of the song.
-	URL         string `json:"Url"` //(Required) The URL for sharing a song in the iTunes Store.
+	AlbumName   string  //(Required) The name of the album the song appears on.
+	ArtistName  string  //(Required) The artist’s name.
+	TrackNumber int     //(Required) The track number.
+	Name        string  //(Required) The localized name of the song.
+	URL         string  `json:"Url"` //(Required) The URL for sharing a song in the iTunes Store.
+	Artwork     Artwork //The album artwork.
 }
 
 type Artwork struct {
@@ -73,6 +74,7 @@ type AlbumAttributes struct {
 	Name       string  //(Required) The localized name of the album.
 	URL        string  `json:"Url"`
 	IsSingle   bool
+	Upc        string
 }
 
 type QueryParams struct {
@@ -105,29 +107,29 @@ type Relationship struct {
 	Type string
 }
 
-const baseURL = "https://api.music.apple.com"
-
 type client struct {
-	client *http.Client
+	baseURL string
+	client  *http.Client
 }
 
-func NewAppleMusicClient(token string) *client {
-	return &client{client: clients.NewClientWithBearerAuth(token)}
+func NewAppleMusicClient(baseURL string, token string, transport http.RoundTripper) *client {
+	return &client{baseURL: baseURL, client: &http.Client{Transport: clients.NewBearerAuthTransport(token, transport)}}
 }
 
 func (a *client) SearchArtist(term string, storefront model.Market) ([]Artist, error) {
 
 	querySafeTerm := url2.QueryEscape(term)
-	url := fmt.Sprintf("%s/v1/catalog/%s/search?term=%s&types=artists", baseURL, storefront, querySafeTerm)
+	url := fmt.Sprintf("%s/v1/catalog/%s/search?term=%s&types=artists", a.baseURL, storefront, querySafeTerm)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -151,16 +153,17 @@ func (a *client) SearchArtist(term string, storefront model.Market) ([]Artist, e
 func (a *client) SearchAlbum(term string, storefront model.Market) ([]Album, error) {
 
 	querySafeTerm := url2.QueryEscape(term)
-	url := fmt.Sprintf("%s/v1/catalog/%s/search?term='%s'&types=albums", baseURL, storefront, querySafeTerm)
+	url := fmt.Sprintf("%s/v1/catalog/%s/search?term='%s'&types=albums", a.baseURL, storefront, querySafeTerm)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -184,16 +187,17 @@ func (a *client) SearchAlbum(term string, storefront model.Market) ([]Album, err
 func (a *client) SearchSong(term string, storefront model.Market) ([]Song, error) {
 
 	querySafeTerm := url2.QueryEscape(term)
-	url := fmt.Sprintf("%s/v1/catalog/%s/search?term=%s&types=songs", baseURL, storefront, querySafeTerm)
+	url := fmt.Sprintf("%s/v1/catalog/%s/search?term=%s&types=songs", a.baseURL, storefront, querySafeTerm)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -214,18 +218,50 @@ func (a *client) SearchSong(term string, storefront model.Market) ([]Song, error
 	return songs, nil
 }
 
-func (a *client) GetArtist(id string, storefront model.Market) (*Artist, error) {
+func (a *client) Search(term string, types string, storefront model.Market, limit int) (*SearchResult, error) {
 
-	url := fmt.Sprintf("%s/v1/catalog/%s/artists/%s", baseURL, storefront, id)
+	querySafeTerm := url2.QueryEscape(term)
+	url := fmt.Sprintf("%s/v1/catalog/%s/search?term=%s&types=%s&limit=%d", a.baseURL, storefront, querySafeTerm, types, limit)
 
 	httpRes, err := a.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
 	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, clients.NewResponseError(httpRes)
+	}
+
+	resBytes, err := ioutil.ReadAll(httpRes.Body)
+
+	var res *SearchResponse
+	err = json.Unmarshal(resBytes, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		return &SearchResult{}, nil
+	}
+
+	return &res.Results, nil
+}
+
+func (a *client) GetArtist(id string, storefront model.Market) (*Artist, error) {
+
+	url := fmt.Sprintf("%s/v1/catalog/%s/artists/%s", a.baseURL, storefront, id)
+
+	httpRes, err := a.client.Get(url)
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -254,16 +290,17 @@ func (a *client) GetArtist(id string, storefront model.Market) (*Artist, error)
 
 func (a *client) GetAlbum(id string, storefront model.Market) (*Album, error) {
 
-	url := fmt.Sprintf("%s/v1/catalog/%s/albums/%s?include=artists", baseURL, storefront, id)
+	url := fmt.Sprintf("%s/v1/catalog/%s/albums/%s?include=artists", a.baseURL, storefront, id)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -292,16 +329,17 @@ func (a *client) GetAlbum(id string, storefront model.Market) (*Album, error) {
 
 func (a *client) GetSong(id string, storefront model.Market) (*Song, error) {
 
-	url := fmt.Sprintf("%s/v1/catalog/%s/songs/%s?include=artists,albums", baseURL, storefront, id)
+	url := fmt.Sprintf("%s/v1/catalog/%s/songs/%s?include=artists,albums", a.baseURL, storefront, id)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -330,16 +368,17 @@ func (a *client) GetSong(id string, storefront model.Market) (*Song, error) {
 
 func (a *client) GetSongByIsrc(isrc string, storefront model.Market) ([]Song, error) {
 
-	url := fmt.Sprintf("%s/v1/catalog/%s/songs?filter[isrc]=%s", baseURL, storefront, isrc)
+	url := fmt.Sprintf("%s/v1/catalog/%s/songs?filter[isrc]=%s", a.baseURL, storefront, isrc)
 
 	httpRes, err := a.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -357,3 +396,34 @@ func (a *client) GetSongByIsrc(isrc string, storefront model.Market) ([]Song, er
 
 	return songs, nil
 }
+
+func (a *client) GetAlbumsByUpc(upc string, storefront model.Market) ([]Album, error) {
+
+	url := fmt.Sprintf("%s/v1/catalog/%s/albums?filter[upc]=%s&include=artists", a.baseURL, storefront, upc)
+
+	httpRes, err := a.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, clients.NewResponseError(httpRes)
+	}
+
+	resBytes, err := ioutil.ReadAll(httpRes.Body)
+
+	var res *AlbumResult
+	err = json.Unmarshal(resBytes, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var albums []Album
+	for _, album := range res.Data {
+		albums = append(albums, *album)
+	}
+
+	return albums, nil
+}
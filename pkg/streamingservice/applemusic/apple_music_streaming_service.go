@@ -2,6 +2,7 @@ package applemusic
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
@@ -18,10 +19,10 @@ type appleMusicStreamingService struct {
 	metricsRecorder  metrics.Recorder
 }
 
-func NewAppleMusicStreamingService(cfg config.AppleMusic, mr metrics.Recorder) streamingservice.StreamingService {
-	shareLinkPatternRegex := regexp.MustCompile("(https?:\\/\\/)?music\\.apple\\.com\\/(?P<storefront>[A-Za-z0-9]+)\\/(?P<type>[A-Za-z]+)\\/(?:.+\\/)(?P<id>[0-9]+)(?:\\?i=(?P<song_id>[0-9]+))?")
+func NewAppleMusicStreamingService(cfg config.AppleMusic, mr metrics.Recorder, transport http.RoundTripper) streamingservice.StreamingService {
+	shareLinkPatternRegex := regexp.MustCompile("(https?:\\/\\/)?music\\.apple\\.com\\/(?P<storefront>[A-Za-z0-9]+)\\/(?P<type>[A-Za-z]+)\\/(?:.+\\/)?(?P<id>[0-9]+)(?:\\?i=(?P<song_id>[0-9]+))?")
 
-	amc := NewAppleMusicClient(cfg.Token())
+	amc := NewAppleMusicClient(cfg.ApiUrl(), cfg.Token(), transport)
 
 	return &appleMusicStreamingService{
 		cfg,
@@ -90,6 +91,30 @@ func (s *appleMusicStreamingService) SearchAlbum(album *model.Album) (*model.Alb
 	return resAlbum, true, err
 }
 
+func (s *appleMusicStreamingService) GetAlbumByUpc(upc string) (*model.Album, bool, error) {
+
+	go s.metricsRecorder.CountAppleMusicRequest()
+
+	albumsRes, err := s.client.GetAlbumsByUpc(upc, model.DefaultMarket)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(albumsRes) == 0 {
+		return nil, false, nil
+	}
+
+	// Todo: Narrow down results
+	foundAlbum := albumsRes[0]
+
+	album, err := s.newAlbum(&foundAlbum, model.DefaultMarket)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return album, true, nil
+}
+
 func (s *appleMusicStreamingService) GetTrackByIsrc(isrc string) (*model.Track, bool, error) {
 
 	songsRes, err := s.client.GetSongByIsrc(isrc, model.DefaultMarket)
@@ -217,6 +242,83 @@ func (s *appleMusicStreamingService) GetFromLink(link string) (model.Type, inter
 	}
 }
 
+func (s *appleMusicStreamingService) Search(query string, typ model.Type, limit int) ([]model.Thing, error) {
+
+	var types string
+	switch typ {
+	case model.ArtistType:
+		types = "artists"
+	case model.AlbumType:
+		types = "albums"
+	case model.TrackType:
+		types = "songs"
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	go s.metricsRecorder.CountAppleMusicRequest()
+
+	searchRes, err := s.client.Search(query, types, model.DefaultMarket, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search results don't include relationships, so everything is built from the attributes alone.
+	// Looking up the relationships for every result would be far too many requests.
+	var res []model.Thing
+	if searchRes.Artists != nil {
+		for _, artist := range searchRes.Artists.Data {
+			newArtist := model.NewArtist(
+				artist.Attributes.Name,
+				"",
+				s.Key(),
+				model.DefaultMarket,
+				artist.Attributes.URL)
+			newArtist.ServiceId = artist.ID
+			newArtist.Storefront = storefront(model.DefaultMarket)
+
+			res = append(res, newArtist)
+		}
+	}
+
+	if searchRes.Albums != nil {
+		for _, album := range searchRes.Albums.Data {
+			newAlbum := model.NewAlbum(
+				cleanAlbumName(album.Attributes),
+				[]string{album.Attributes.ArtistName},
+				getArtworkURL(&album.Attributes.Artwork),
+				s.Key(),
+				model.DefaultMarket,
+				album.Attributes.URL)
+			newAlbum.Upc = album.Attributes.Upc
+			newAlbum.ServiceId = album.ID
+			newAlbum.Storefront = storefront(model.DefaultMarket)
+
+			res = append(res, newAlbum)
+		}
+	}
+
+	if searchRes.Songs != nil {
+		for _, song := range searchRes.Songs.Data {
+			newTrack := model.NewTrack(
+				song.Attributes.Isrc,
+				song.Attributes.Name,
+				[]string{song.Attributes.ArtistName},
+				song.Attributes.AlbumName,
+				getArtworkURL(&song.Attributes.Artwork),
+				s.Key(),
+				model.DefaultMarket,
+				song.Attributes.URL)
+			newTrack.ServiceId = song.ID
+			newTrack.Storefront = storefront(model.DefaultMarket)
+
+			res = append(res, newTrack)
+		}
+	}
+
+	return res, nil
+}
+
 func (s *appleMusicStreamingService) CleanLink(link string) string {
 
 	match := s.shareLinkPattern.FindStringIndex(link)
@@ -235,22 +337,15 @@ func (s *appleMusicStreamingService) newArtist(artist *Artist, market model.Mark
 		s.Key(),
 		market,
 		artist.Attributes.URL)
+	newArtist.ServiceId = artist.ID
+	newArtist.Storefront = storefront(market)
 
 	return newArtist, nil
 }
 
 func (s *appleMusicStreamingService) newAlbum(album *Album, market model.Market) (*model.Album, error) {
 
-	// Clean up album name
-	// Todo: Revisit
-	albumName := album.Attributes.Name
-	if album.Attributes.IsSingle {
-		singleRegex := regexp.MustCompile("\\s-\\sSingle$")
-		indexes := singleRegex.FindStringIndex(albumName)
-		if len(indexes) > 0 {
-			albumName = albumName[0:indexes[0]]
-		}
-	}
+	albumName := cleanAlbumName(album.Attributes)
 
 	// Query relationships for artist names
 	artistNames, err := s.getAlbumArtistNames(album, market)
@@ -265,6 +360,9 @@ func (s *appleMusicStreamingService) newAlbum(album *Album, market model.Market)
 		s.Key(),
 		market,
 		album.Attributes.URL)
+	newAlbum.Upc = album.Attributes.Upc
+	newAlbum.ServiceId = album.ID
+	newAlbum.Storefront = storefront(market)
 
 	return newAlbum, nil
 }
@@ -292,10 +390,32 @@ func (s *appleMusicStreamingService) newTrack(song *Song, market model.Market) (
 		s.Key(),
 		market,
 		song.Attributes.URL)
+	track.ServiceId = song.ID
+	track.Storefront = storefront(market)
 
 	return track, nil
 }
 
+// cleanAlbumName removes the " - Single" suffix Apple Music adds to singles
+// Todo: Revisit
+func cleanAlbumName(attributes *AlbumAttributes) string {
+	albumName := attributes.Name
+	if attributes.IsSingle {
+		singleRegex := regexp.MustCompile("\\s-\\sSingle$")
+		indexes := singleRegex.FindStringIndex(albumName)
+		if len(indexes) > 0 {
+			albumName = albumName[0:indexes[0]]
+		}
+	}
+
+	return albumName
+}
+
+// storefront returns the storefront for the given market, as it appears in Apple Music links
+func storefront(market model.Market) string {
+	return strings.ToLower(string(market))
+}
+
 func getArtworkURL(art *Artwork) string {
 	url := art.URL
 	url = strings.ReplaceAll(url, "{w}", fmt.Sprintf("%d", art.Width))
@@ -0,0 +1,224 @@
+package applemusic_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"github.com/yukitsune/maestro/pkg/streamingservice/applemusic"
+)
+
+func newTestService(t *testing.T) streamingservice.StreamingService {
+	srv, err := clients.NewFixtureServer(filepath.Join("testdata", "fixtures"), "https://api.music.apple.com")
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+
+	// A real token is only needed when re-recording the fixtures
+	v := viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetEnvPrefix("MAESTRO")
+	v.AutomaticEnv()
+	v.SetDefault("services.apple_music.token", "test-token")
+	v.Set("services.apple_music.api_url", srv.URL)
+
+	return applemusic.NewAppleMusicStreamingService(config.NewAppleMusicViperConfig(v), metrics.NewNoopMetricsRecorder(), http.DefaultTransport)
+}
+
+func Test_GetFromLink(t *testing.T) {
+	testCases := []struct {
+		name       string
+		link       string
+		expectType model.Type
+		expect     any
+	}{
+		{
+			name:       "artist",
+			link:       "https://music.apple.com/us/artist/cheap-trick/450029",
+			expectType: model.ArtistType,
+			expect: &model.Artist{
+				Name:       "Cheap Trick",
+				Source:     model.AppleMusicStreamingService,
+				Market:     "us",
+				Link:       "https://music.apple.com/us/artist/cheap-trick/450029",
+				ServiceId:  "450029",
+				Storefront: "us",
+			},
+		},
+		{
+			name:       "album",
+			link:       "https://music.apple.com/us/album/heaven-tonight/192688317",
+			expectType: model.AlbumType,
+			expect: &model.Album{
+				Upc:         "074643529821",
+				Name:        "Heaven Tonight",
+				ArtistNames: []string{"Cheap Trick"},
+				ArtworkLink: "https://is1-ssl.mzstatic.com/image/thumb/Music/v4/heaven-tonight/3000x3000bb.jpg",
+				Source:      model.AppleMusicStreamingService,
+				Market:      "us",
+				Link:        "https://music.apple.com/us/album/heaven-tonight/192688317",
+				ServiceId:   "192688317",
+				Storefront:  "us",
+			},
+		},
+		{
+			name:       "track",
+			link:       "https://music.apple.com/us/album/surrender/192688317?i=192688344",
+			expectType: model.TrackType,
+			expect: &model.Track{
+				Isrc:        "USSM17800845",
+				Name:        "Surrender",
+				ArtistNames: []string{"Cheap Trick"},
+				AlbumName:   "Heaven Tonight",
+				ArtworkLink: "https://is1-ssl.mzstatic.com/image/thumb/Music/v4/heaven-tonight/3000x3000bb.jpg",
+				Source:      model.AppleMusicStreamingService,
+				Market:      "us",
+				Link:        "https://music.apple.com/us/album/surrender/192688317?i=192688344",
+				ServiceId:   "192688344",
+				Storefront:  "us",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			typ, res, err := svc.GetFromLink(testCase.link)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectType, typ)
+			assert.Equal(t, testCase.expect, res)
+		})
+	}
+}
+
+func Test_SearchArtist(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchArtist(&model.Artist{Name: "Cheap Trick", Market: "us"})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Cheap Trick", res.Name)
+	assert.Equal(t, "https://music.apple.com/us/artist/cheap-trick/450029", res.Link)
+	assert.Equal(t, model.AppleMusicStreamingService, res.Source)
+}
+
+func Test_SearchAlbum(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchAlbum(&model.Album{Name: "Heaven Tonight", ArtistNames: []string{"Cheap Trick"}, Market: "us"})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Heaven Tonight", res.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, res.ArtistNames)
+	assert.Equal(t, "https://music.apple.com/us/album/heaven-tonight/192688317", res.Link)
+}
+
+func Test_SearchTrack(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchTrack(&model.Track{Name: "Surrender", ArtistNames: []string{"Cheap Trick"}, Market: "us"})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "USSM17800845", res.Isrc)
+	assert.Equal(t, "https://music.apple.com/us/album/surrender/192688317?i=192688344", res.Link)
+}
+
+func Test_GetTrackByIsrc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		isrc        string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known isrc",
+			isrc:        "USSM17800845",
+			expectFound: true,
+			expectLink:  "https://music.apple.com/au/album/surrender/192688317?i=192688344",
+		},
+		{
+			name:        "unknown isrc",
+			isrc:        "XX0000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetTrackByIsrc(testCase.isrc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.isrc, res.Isrc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_GetAlbumByUpc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		upc         string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known upc",
+			upc:         "074643529821",
+			expectFound: true,
+			expectLink:  "https://music.apple.com/au/album/heaven-tonight/192688317",
+		},
+		{
+			name:        "unknown upc",
+			upc:         "000000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetAlbumByUpc(testCase.upc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.upc, res.Upc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_Search(t *testing.T) {
+	svc := newTestService(t)
+
+	res, err := svc.Search("Cheap Trick Surrender", model.TrackType, 5)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	track, ok := res[0].(*model.Track)
+	require.True(t, ok)
+	assert.Equal(t, "Surrender", track.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, track.ArtistNames)
+	assert.Equal(t, "Heaven Tonight", track.AlbumName)
+	assert.Equal(t, "USSM17800845", track.Isrc)
+	assert.Equal(t, "https://music.apple.com/au/album/surrender/192688317?i=192688344", track.Link)
+}
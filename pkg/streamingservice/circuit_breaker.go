@@ -0,0 +1,183 @@
+package streamingservice
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+var ErrCircuitOpen = errors.New("service is temporarily unavailable")
+
+// CircuitBreaker keeps track of how many requests to a service are failing.
+// Once the ratio of failed requests within an interval is too high, the circuit opens and requests fail immediately.
+// After the cool-down, a single request is let through (half-open). If it succeeds the circuit closes again,
+// otherwise it re-opens for another cool-down.
+type CircuitBreaker struct {
+	cfg           config.CircuitBreaker
+	now           func() time.Time
+	onStateChange func(model.CircuitState)
+
+	mu          sync.Mutex
+	notifyMu    sync.Mutex
+	state       model.CircuitState
+	changed     bool
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     bool
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker. onStateChange is called whenever the state changes, it may be nil.
+func NewCircuitBreaker(cfg config.CircuitBreaker, onStateChange func(model.CircuitState)) *CircuitBreaker {
+	return NewCircuitBreakerWithClock(cfg, onStateChange, time.Now)
+}
+
+func NewCircuitBreakerWithClock(cfg config.CircuitBreaker, onStateChange func(model.CircuitState), now func() time.Time) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:           cfg,
+		now:           now,
+		onStateChange: onStateChange,
+		state:         model.CircuitClosed,
+		windowStart:   now(),
+	}
+}
+
+func (b *CircuitBreaker) State() model.CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An open circuit which has cooled down will let the next request through
+	if b.state == model.CircuitOpen && b.now().Sub(b.openedAt) >= b.cfg.CoolDown() {
+		return model.CircuitHalfOpen
+	}
+
+	return b.state
+}
+
+// Execute runs fn if the circuit allows it, and records whether it failed
+func (b *CircuitBreaker) Execute(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	now := b.now()
+	switch b.state {
+	case model.CircuitClosed:
+		if now.Sub(b.windowStart) >= b.cfg.Interval() {
+			b.resetCounts(now)
+		}
+
+		return nil
+
+	case model.CircuitOpen:
+		if now.Sub(b.openedAt) < b.cfg.CoolDown() {
+			return ErrCircuitOpen
+		}
+
+		b.setState(model.CircuitHalfOpen)
+		b.probing = true
+		return nil
+
+	case model.CircuitHalfOpen:
+		// Only one request is allowed through while we're checking if the service has recovered
+		if b.probing {
+			return ErrCircuitOpen
+		}
+
+		b.probing = true
+		return nil
+	}
+
+	return nil
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	now := b.now()
+	switch b.state {
+	case model.CircuitClosed:
+		b.requests++
+		if isFailure(err) {
+			b.failures++
+		}
+
+		ratio := float64(b.failures) / float64(b.requests)
+		if b.requests >= b.cfg.MinRequests() && ratio >= b.cfg.FailureRatio() {
+			b.open(now)
+		}
+
+	case model.CircuitHalfOpen:
+		b.probing = false
+		if isFailure(err) {
+			b.open(now)
+			return
+		}
+
+		b.resetCounts(now)
+		b.setState(model.CircuitClosed)
+	}
+}
+
+// isFailure returns true if err suggests the service itself is struggling.
+// Things like a 404 mean the service is working fine, it just doesn't have what we asked for.
+func isFailure(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassTimeout, ErrorClassNetwork, ErrorClassRateLimited, ErrorClassUpstream:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.setState(model.CircuitOpen)
+}
+
+func (b *CircuitBreaker) resetCounts(now time.Time) {
+	b.requests = 0
+	b.failures = 0
+	b.windowStart = now
+}
+
+func (b *CircuitBreaker) setState(state model.CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+	b.changed = true
+}
+
+// unlock releases the lock, then lets onStateChange know if the state has changed.
+// The next change can't be reported until this one has been, so they're always reported in the order they happened.
+func (b *CircuitBreaker) unlock() {
+	if !b.changed || b.onStateChange == nil {
+		b.mu.Unlock()
+		return
+	}
+
+	state := b.state
+	b.changed = false
+
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	b.mu.Unlock()
+	b.onStateChange(state)
+}
@@ -0,0 +1,96 @@
+package streamingservice
+
+import (
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+type circuitBreakerService struct {
+	svc     StreamingService
+	breaker *CircuitBreaker
+}
+
+// WithCircuitBreaker wraps the given service so that any calls to its API go through the CircuitBreaker.
+// Calls fail with ErrCircuitOpen while the circuit is open.
+func WithCircuitBreaker(svc StreamingService, breaker *CircuitBreaker) StreamingService {
+	return &circuitBreakerService{svc, breaker}
+}
+
+func (s *circuitBreakerService) Config() config.Service {
+	return s.svc.Config()
+}
+
+func (s *circuitBreakerService) LinkBelongsToService(link string) bool {
+	return s.svc.LinkBelongsToService(link)
+}
+
+func (s *circuitBreakerService) CleanLink(link string) string {
+	return s.svc.CleanLink(link)
+}
+
+func (s *circuitBreakerService) SearchArtist(artist *model.Artist) (res *model.Artist, found bool, err error) {
+	err = s.breaker.Execute(func() error {
+		res, found, err = s.svc.SearchArtist(artist)
+		return err
+	})
+
+	return res, found, err
+}
+
+func (s *circuitBreakerService) SearchAlbum(album *model.Album) (res *model.Album, found bool, err error) {
+	err = s.breaker.Execute(func() error {
+		res, found, err = s.svc.SearchAlbum(album)
+		return err
+	})
+
+	return res, found, err
+}
+
+func (s *circuitBreakerService) GetAlbumByUpc(upc string) (res *model.Album, found bool, err error) {
+	err = s.breaker.Execute(func() error {
+		res, found, err = s.svc.GetAlbumByUpc(upc)
+		return err
+	})
+
+	return res, found, err
+}
+
+func (s *circuitBreakerService) SearchTrack(track *model.Track) (res *model.Track, found bool, err error) {
+	err = s.breaker.Execute(func() error {
+		res, found, err = s.svc.SearchTrack(track)
+		return err
+	})
+
+	return res, found, err
+}
+
+func (s *circuitBreakerService) GetTrackByIsrc(isrc string) (res *model.Track, found bool, err error) {
+	err = s.breaker.Execute(func() error {
+		res, found, err = s.svc.GetTrackByIsrc(isrc)
+		return err
+	})
+
+	return res, found, err
+}
+
+func (s *circuitBreakerService) GetFromLink(link string) (typ model.Type, res interface{}, err error) {
+	err = s.breaker.Execute(func() error {
+		typ, res, err = s.svc.GetFromLink(link)
+		return err
+	})
+
+	if err != nil {
+		return model.UnknownType, nil, err
+	}
+
+	return typ, res, nil
+}
+
+func (s *circuitBreakerService) Search(query string, typ model.Type, limit int) (res []model.Thing, err error) {
+	err = s.breaker.Execute(func() error {
+		res, err = s.svc.Search(query, typ, limit)
+		return err
+	})
+
+	return res, err
+}
@@ -0,0 +1,209 @@
+package streamingservice_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker() (*streamingservice.CircuitBreaker, *testClock) {
+	return newNotifyingTestBreaker(nil)
+}
+
+func newNotifyingTestBreaker(onStateChange func(model.CircuitState)) (*streamingservice.CircuitBreaker, *testClock) {
+	v := viper.New()
+	v.Set("services.test.circuit_breaker.failure_ratio", 0.5)
+	v.Set("services.test.circuit_breaker.min_requests", 4)
+	v.Set("services.test.circuit_breaker.interval", time.Minute)
+	v.Set("services.test.circuit_breaker.cool_down", 30*time.Second)
+
+	clock := &testClock{time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	breaker := streamingservice.NewCircuitBreakerWithClock(config.NewCircuitBreakerViperConfig(v, "test"), onStateChange, clock.Now)
+	return breaker, clock
+}
+
+func succeed() error {
+	return nil
+}
+
+func fail() error {
+	return &clients.ResponseError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+}
+
+func badRequest() error {
+	return &clients.ResponseError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+}
+
+func notFound() error {
+	return &clients.ResponseError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+}
+
+func Test_CircuitBreakerOpensWhenFailureRatioIsReached(t *testing.T) {
+	breaker, _ := newTestBreaker()
+
+	assert.NoError(t, breaker.Execute(succeed))
+	assert.Error(t, breaker.Execute(fail))
+	assert.NoError(t, breaker.Execute(succeed))
+	assert.Equal(t, model.CircuitClosed, breaker.State())
+
+	assert.Error(t, breaker.Execute(fail))
+	assert.Equal(t, model.CircuitOpen, breaker.State())
+
+	called := false
+	err := breaker.Execute(func() error {
+		called = true
+		return nil
+	})
+
+	assert.ErrorIs(t, err, streamingservice.ErrCircuitOpen)
+	assert.False(t, called)
+}
+
+func Test_CircuitBreakerIgnoresClientErrors(t *testing.T) {
+	testCases := map[string]func() error{
+		"not found":     notFound,
+		"bad request":   badRequest,
+		"unknown error": func() error { return errors.New("link doesn't link to deezer") },
+	}
+
+	for name, err := range testCases {
+		t.Run(name, func(t *testing.T) {
+			breaker, _ := newTestBreaker()
+
+			for i := 0; i < 10; i++ {
+				assert.Error(t, breaker.Execute(err))
+			}
+
+			assert.Equal(t, model.CircuitClosed, breaker.State())
+			assert.NoError(t, breaker.Execute(succeed))
+		})
+	}
+}
+
+func Test_CircuitBreakerNeedsMinimumRequests(t *testing.T) {
+	breaker, _ := newTestBreaker()
+
+	for i := 0; i < 3; i++ {
+		assert.Error(t, breaker.Execute(fail))
+	}
+
+	assert.Equal(t, model.CircuitClosed, breaker.State())
+}
+
+func Test_CircuitBreakerForgetsFailuresAfterInterval(t *testing.T) {
+	breaker, clock := newTestBreaker()
+
+	for i := 0; i < 3; i++ {
+		assert.Error(t, breaker.Execute(fail))
+	}
+
+	clock.Advance(time.Minute)
+
+	assert.NoError(t, breaker.Execute(succeed))
+	assert.Equal(t, model.CircuitClosed, breaker.State())
+}
+
+func Test_CircuitBreakerHalfOpen(t *testing.T) {
+	testCases := []struct {
+		name        string
+		probe       func() error
+		expectState model.CircuitState
+	}{
+		{
+			name:        "successful probe closes the circuit",
+			probe:       succeed,
+			expectState: model.CircuitClosed,
+		},
+		{
+			name:        "failed probe re-opens the circuit",
+			probe:       fail,
+			expectState: model.CircuitOpen,
+		},
+		{
+			name:        "not found probe closes the circuit",
+			probe:       notFound,
+			expectState: model.CircuitClosed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			breaker, clock := newTestBreaker()
+			for i := 0; i < 4; i++ {
+				_ = breaker.Execute(fail)
+			}
+
+			require.Equal(t, model.CircuitOpen, breaker.State())
+
+			clock.Advance(30 * time.Second)
+			assert.Equal(t, model.CircuitHalfOpen, breaker.State())
+
+			_ = breaker.Execute(func() error {
+				// Only the probe is let through while the circuit is half-open
+				assert.ErrorIs(t, breaker.Execute(succeed), streamingservice.ErrCircuitOpen)
+				return testCase.probe()
+			})
+
+			assert.Equal(t, testCase.expectState, breaker.State())
+		})
+	}
+}
+
+func Test_WithCircuitBreakerSkipsFailingService(t *testing.T) {
+	breaker, _ := newTestBreaker()
+
+	fake := sstesting.NewFakeStreamingService(model.AppleMusicStreamingService).
+		WithError(fail())
+	svc := streamingservice.WithCircuitBreaker(fake, breaker)
+
+	for i := 0; i < 10; i++ {
+		_, _, err := svc.GetTrackByIsrc("USSM17800845")
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 4, fake.Calls())
+
+	typ, res, err := svc.GetFromLink(fake.Link(model.TrackType, "surrender"))
+	assert.ErrorIs(t, err, streamingservice.ErrCircuitOpen)
+	assert.Equal(t, model.UnknownType, typ)
+	assert.Nil(t, res)
+}
+
+func Test_CircuitBreakerReportsStateChangesInOrder(t *testing.T) {
+	var states []model.CircuitState
+	breaker, clock := newNotifyingTestBreaker(func(state model.CircuitState) {
+		states = append(states, state)
+	})
+
+	for i := 0; i < 4; i++ {
+		_ = breaker.Execute(fail)
+	}
+
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, breaker.Execute(succeed))
+
+	// Reported as soon as they happen, rather than whenever a goroutine gets around to it
+	assert.Equal(t, []model.CircuitState{model.CircuitOpen, model.CircuitHalfOpen, model.CircuitClosed}, states)
+}
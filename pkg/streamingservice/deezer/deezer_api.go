@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+
+	"github.com/yukitsune/maestro/pkg/clients"
 )
 
 type searchArtistResponse struct {
@@ -25,6 +27,7 @@ type searchAlbumResponse struct {
 
 type Album struct {
 	Id     int
+	Upc    string
 	Title  string
 	Link   string
 	Cover  string
@@ -44,29 +47,33 @@ type Track struct {
 	Album  Album
 }
 
-const baseURL = "https://api.deezer.com"
+type searchResponse[T any] struct {
+	Data []T
+}
 
 type client struct {
-	client *http.Client
+	baseURL string
+	client  *http.Client
 }
 
-func NewDeezerClient() *client {
-	return &client{client: &http.Client{}}
+func NewDeezerClient(baseURL string, transport http.RoundTripper) *client {
+	return &client{baseURL: baseURL, client: &http.Client{Transport: transport}}
 }
 
 func (d *client) SearchArtist(artistName string) ([]Artist, error) {
 
 	q := url.QueryEscape(fmt.Sprintf("artist:\"%s\"", artistName))
-	url := fmt.Sprintf("%s/search/artist?q=%s", baseURL, q)
+	url := fmt.Sprintf("%s/search/artist?q=%s", d.baseURL, q)
 
 	httpRes, err := d.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -90,16 +97,17 @@ func (d *client) SearchArtist(artistName string) ([]Artist, error) {
 func (d *client) SearchAlbum(artistName string, albumName string) ([]Album, error) {
 
 	q := url.QueryEscape(fmt.Sprintf("artist:\"%s\" album:\"%s\"", artistName, albumName))
-	apiURL := fmt.Sprintf("%s/search/album?q=%s", baseURL, q)
+	apiURL := fmt.Sprintf("%s/search/album?q=%s", d.baseURL, q)
 
 	httpRes, err := d.client.Get(apiURL)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -123,16 +131,17 @@ func (d *client) SearchAlbum(artistName string, albumName string) ([]Album, erro
 func (d *client) SearchTrack(artistName string, albumName string, trackName string) ([]Track, error) {
 
 	q := url.QueryEscape(fmt.Sprintf("artist:\"%s\" album:\"%s\" track:\"%s\"", artistName, albumName, trackName))
-	apiURL := fmt.Sprintf("%s/search/track?q=%s", baseURL, q)
+	apiURL := fmt.Sprintf("%s/search/track?q=%s", d.baseURL, q)
 
 	httpRes, err := d.client.Get(apiURL)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -153,22 +162,54 @@ func (d *client) SearchTrack(artistName string, albumName string, trackName stri
 	return tracks, nil
 }
 
+// search runs a free-text search for the given kind of thing, e.g. "artist", "album", or "track"
+func search[T any](d *client, kind string, query string, limit int) ([]T, error) {
+
+	apiURL := fmt.Sprintf("%s/search/%s?q=%s&limit=%d", d.baseURL, kind, url.QueryEscape(query), limit)
+
+	httpRes, err := d.client.Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, clients.NewResponseError(httpRes)
+	}
+
+	resBytes, err := ioutil.ReadAll(httpRes.Body)
+
+	var apiRes *searchResponse[T]
+	err = json.Unmarshal(resBytes, &apiRes)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiRes == nil {
+		return nil, nil
+	}
+
+	return apiRes.Data, nil
+}
+
 func (d *client) GetArtist(id int) (*Artist, error) {
 
-	url := fmt.Sprintf("%s/artist/%d", baseURL, id)
+	url := fmt.Sprintf("%s/artist/%d", d.baseURL, id)
 
 	httpRes, err := d.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode == http.StatusNotFound {
 		return nil, nil
 	}
 
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -184,20 +225,21 @@ func (d *client) GetArtist(id int) (*Artist, error) {
 
 func (d *client) GetAlbum(id int) (*Album, error) {
 
-	url := fmt.Sprintf("%s/album/%d", baseURL, id)
+	url := fmt.Sprintf("%s/album/%d", d.baseURL, id)
 
 	httpRes, err := d.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode == http.StatusNotFound {
 		return nil, nil
 	}
 
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -213,20 +255,21 @@ func (d *client) GetAlbum(id int) (*Album, error) {
 
 func (d *client) GetTrack(id int) (*Track, error) {
 
-	url := fmt.Sprintf("%s/track/%d", baseURL, id)
+	url := fmt.Sprintf("%s/track/%d", d.baseURL, id)
 
 	httpRes, err := d.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode == http.StatusNotFound {
 		return nil, nil
 	}
 
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -241,20 +284,21 @@ func (d *client) GetTrack(id int) (*Track, error) {
 }
 
 func (d *client) GetTrackByIsrc(isrc string) (*Track, error) {
-	url := fmt.Sprintf("%s/track/isrc:%s", baseURL, isrc)
+	url := fmt.Sprintf("%s/track/isrc:%s", d.baseURL, isrc)
 
 	httpRes, err := d.client.Get(url)
-	defer httpRes.Body.Close()
 	if err != nil {
 		return nil, err
 	}
 
+	defer httpRes.Body.Close()
+
 	if httpRes.StatusCode == http.StatusNotFound {
 		return nil, nil
 	}
 
 	if httpRes.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("api responded with %s", httpRes.Status)
+		return nil, clients.NewResponseError(httpRes)
 	}
 
 	resBytes, err := ioutil.ReadAll(httpRes.Body)
@@ -272,3 +316,37 @@ func (d *client) GetTrackByIsrc(isrc string) (*Track, error) {
 
 	return res, nil
 }
+
+func (d *client) GetAlbumByUpc(upc string) (*Album, error) {
+	url := fmt.Sprintf("%s/album/upc:%s", d.baseURL, upc)
+
+	httpRes, err := d.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, clients.NewResponseError(httpRes)
+	}
+
+	resBytes, err := ioutil.ReadAll(httpRes.Body)
+
+	var res *Album
+	err = json.Unmarshal(resBytes, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	// If we haven't got a valid link, don't bother returning it
+	if len(res.Link) == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
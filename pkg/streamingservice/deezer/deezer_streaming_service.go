@@ -1,11 +1,13 @@
 package deezer
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
 
+	"github.com/yukitsune/maestro/pkg/clients"
 	"github.com/yukitsune/maestro/pkg/config"
 	"github.com/yukitsune/maestro/pkg/metrics"
 	"github.com/yukitsune/maestro/pkg/model"
@@ -18,40 +20,20 @@ type deezerStreamingService struct {
 	shareLinkPattern  *regexp.Regexp
 	actualLinkPattern *regexp.Regexp
 	metricsRecorder   metrics.Recorder
+	resolver          clients.RedirectResolver
 }
 
-func getActualLink(link string, linkRegexp *regexp.Regexp) (string, error) {
-	var actualLink string
-
-	if linkRegexp.MatchString(link) {
-		return link, nil
-	}
-
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			urlString := req.URL.String()
-			if linkRegexp.MatchString(urlString) {
-				actualLink = urlString
-				return http.ErrUseLastResponse
-			}
-
-			return nil
-		},
-	}
-
-	_, err := client.Get(link)
-	return actualLink, err
-}
-
-func NewDeezerStreamingService(config config.Deezer, mr metrics.Recorder) streamingservice.StreamingService {
-	shareLinkPattern := regexp.MustCompile("(https?:\\/\\/)?deezer\\.page\\.link\\/(?P<id>[A-Za-z0-9]+)")
-	actualLinkPattern := regexp.MustCompile("(https?:\\/\\/)?(www\\.)?deezer\\.com\\/(?P<lang>[A-Za-z]+\\/)?(?P<type>[A-Za-z]+)\\/(?P<id>[0-9]+)")
+func NewDeezerStreamingService(config config.Deezer, mr metrics.Recorder, transport http.RoundTripper, resolver clients.RedirectResolver) streamingservice.StreamingService {
+	// Both patterns are anchored so that links which only mention Deezer somewhere don't belong to it
+	shareLinkPattern := regexp.MustCompile("^(https?:\\/\\/)?(deezer\\.page\\.link|link\\.deezer\\.com\\/s)\\/(?P<id>[A-Za-z0-9]+)")
+	actualLinkPattern := regexp.MustCompile("^(https?:\\/\\/)?(www\\.)?deezer\\.com\\/(?P<lang>[A-Za-z]+\\/)?(?P<type>[A-Za-z]+)\\/(?P<id>[0-9]+)")
 	return &deezerStreamingService{
 		config,
-		NewDeezerClient(),
+		NewDeezerClient(config.ApiUrl(), transport),
 		shareLinkPattern,
 		actualLinkPattern,
 		mr,
+		resolver,
 	}
 }
 
@@ -83,12 +65,7 @@ func (s *deezerStreamingService) SearchArtist(artist *model.Artist) (*model.Arti
 	// Todo: Narrow down results
 	deezerArtist := searchRes[0]
 
-	res := model.NewArtist(
-		deezerArtist.Name,
-		deezerArtist.Picture,
-		s.Key(),
-		model.DefaultMarket,
-		deezerArtist.Link)
+	res := s.newArtist(&deezerArtist)
 
 	return res, true, nil
 }
@@ -118,13 +95,7 @@ func (s *deezerStreamingService) SearchAlbum(album *model.Album) (*model.Album,
 		// Todo: Narrow down results
 		deezerAlbum := &searchRes[0]
 
-		res = model.NewAlbum(
-			deezerAlbum.Title,
-			[]string{deezerAlbum.Artist.Name},
-			deezerAlbum.Cover,
-			s.Key(),
-			model.DefaultMarket,
-			deezerAlbum.Link)
+		res = s.newAlbum(deezerAlbum)
 	}
 
 	return res, res != nil, nil
@@ -143,15 +114,25 @@ func (s *deezerStreamingService) GetTrackByIsrc(isrc string) (*model.Track, bool
 		return nil, false, nil
 	}
 
-	res := model.NewTrack(
-		deezerTrack.Isrc,
-		deezerTrack.Title,
-		[]string{deezerTrack.Artist.Name},
-		deezerTrack.Album.Title,
-		deezerTrack.Album.Cover,
-		s.Key(),
-		model.DefaultMarket,
-		deezerTrack.Link)
+	res := s.newTrack(deezerTrack)
+
+	return res, true, nil
+}
+
+func (s *deezerStreamingService) GetAlbumByUpc(upc string) (*model.Album, bool, error) {
+
+	go s.metricsRecorder.CountDeezerRequest()
+
+	deezerAlbum, err := s.client.GetAlbumByUpc(upc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if deezerAlbum == nil {
+		return nil, false, nil
+	}
+
+	res := s.newAlbum(deezerAlbum)
 
 	return res, true, nil
 }
@@ -199,15 +180,7 @@ func (s *deezerStreamingService) SearchTrack(track *model.Track) (*model.Track,
 			}
 		}
 
-		res = model.NewTrack(
-			deezerTrack.Isrc,
-			deezerTrack.Title,
-			[]string{deezerTrack.Artist.Name},
-			deezerTrack.Album.Title,
-			deezerTrack.Album.Cover,
-			s.Key(),
-			model.DefaultMarket,
-			deezerTrack.Link)
+		res = s.newTrack(deezerTrack)
 	}
 
 	return res, res != nil, nil
@@ -221,9 +194,19 @@ func (s *deezerStreamingService) GetFromLink(link string) (model.Type, interface
 	// format: 	https://www.deezer.com/<lang>/<artist|album|track>/<id>
 	// Todo: How we gonna get the region?
 
-	actualLink, err := getActualLink(link, s.actualLinkPattern)
-	if err != nil {
-		return model.UnknownType, nil, err
+	actualLink := link
+	if !s.actualLinkPattern.MatchString(link) {
+		resolved, err := s.resolver.Resolve(context.Background(), link)
+		if err != nil {
+			return model.UnknownType, nil, err
+		}
+
+		// Short links can go anywhere, make sure we've ended up back at Deezer
+		if !s.actualLinkPattern.MatchString(resolved) {
+			return model.UnknownType, nil, fmt.Errorf("%s doesn't link to deezer", link)
+		}
+
+		actualLink = resolved
 	}
 
 	matches := streamingservice.FindStringSubmatchMap(s.actualLinkPattern, actualLink)
@@ -246,12 +229,12 @@ func (s *deezerStreamingService) GetFromLink(link string) (model.Type, interface
 			return model.UnknownType, nil, err
 		}
 
-		artist := model.NewArtist(
-			foundArtist.Name,
-			foundArtist.Picture,
-			s.Key(),
-			model.DefaultMarket,
-			foundArtist.Link)
+		// The artist no longer exists (or never did)
+		if foundArtist == nil || len(foundArtist.Link) == 0 {
+			return model.UnknownType, nil, nil
+		}
+
+		artist := s.newArtist(foundArtist)
 
 		return model.ArtistType, artist, nil
 
@@ -268,13 +251,12 @@ func (s *deezerStreamingService) GetFromLink(link string) (model.Type, interface
 			return model.UnknownType, nil, err
 		}
 
-		album := model.NewAlbum(
-			foundAlbum.Title,
-			[]string{foundAlbum.Artist.Name}, // Todo:
-			foundAlbum.Cover,
-			s.Key(),
-			model.DefaultMarket,
-			foundAlbum.Link)
+		// The album no longer exists (or never did)
+		if foundAlbum == nil || len(foundAlbum.Link) == 0 {
+			return model.UnknownType, nil, nil
+		}
+
+		album := s.newAlbum(foundAlbum)
 
 		return model.AlbumType, album, nil
 
@@ -291,15 +273,12 @@ func (s *deezerStreamingService) GetFromLink(link string) (model.Type, interface
 			return model.UnknownType, nil, err
 		}
 
-		track := model.NewTrack(
-			foundTrack.Isrc,
-			foundTrack.Title,
-			[]string{foundTrack.Artist.Name}, // Todo:
-			foundTrack.Album.Title,
-			foundTrack.Album.Cover,
-			s.Key(),
-			model.DefaultMarket,
-			foundTrack.Link)
+		// The track no longer exists (or never did)
+		if foundTrack == nil || len(foundTrack.Link) == 0 {
+			return model.UnknownType, nil, nil
+		}
+
+		track := s.newTrack(foundTrack)
 
 		return model.TrackType, track, nil
 
@@ -308,6 +287,50 @@ func (s *deezerStreamingService) GetFromLink(link string) (model.Type, interface
 	}
 }
 
+func (s *deezerStreamingService) Search(query string, typ model.Type, limit int) ([]model.Thing, error) {
+
+	go s.metricsRecorder.CountDeezerRequest()
+
+	var res []model.Thing
+	switch typ {
+	case model.ArtistType:
+		artists, err := search[Artist](s.client, "artist", query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, artist := range artists {
+			res = append(res, s.newArtist(&artist))
+		}
+
+	case model.AlbumType:
+		albums, err := search[Album](s.client, "album", query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, album := range albums {
+			res = append(res, s.newAlbum(&album))
+		}
+
+	case model.TrackType:
+		// Tracks in search results don't include the ISRC, it's not worth looking up each one just for that
+		tracks, err := search[Track](s.client, "track", query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, track := range tracks {
+			res = append(res, s.newTrack(&track))
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	return res, nil
+}
+
 func (s *deezerStreamingService) CleanLink(link string) string {
 
 	match := s.shareLinkPattern.FindStringIndex(link)
@@ -317,3 +340,44 @@ func (s *deezerStreamingService) CleanLink(link string) string {
 
 	return link
 }
+
+func (s *deezerStreamingService) newArtist(artist *Artist) *model.Artist {
+	res := model.NewArtist(
+		artist.Name,
+		artist.Picture,
+		s.Key(),
+		model.DefaultMarket,
+		artist.Link)
+	res.ServiceId = strconv.Itoa(artist.Id)
+
+	return res
+}
+
+func (s *deezerStreamingService) newAlbum(album *Album) *model.Album {
+	res := model.NewAlbum(
+		album.Title,
+		[]string{album.Artist.Name}, // Todo: Deezer only tells us about the main artist
+		album.Cover,
+		s.Key(),
+		model.DefaultMarket,
+		album.Link)
+	res.Upc = album.Upc
+	res.ServiceId = strconv.Itoa(album.Id)
+
+	return res
+}
+
+func (s *deezerStreamingService) newTrack(track *Track) *model.Track {
+	res := model.NewTrack(
+		track.Isrc,
+		track.Title,
+		[]string{track.Artist.Name}, // Todo: Deezer only tells us about the main artist
+		track.Album.Title,
+		track.Album.Cover,
+		s.Key(),
+		model.DefaultMarket,
+		track.Link)
+	res.ServiceId = strconv.Itoa(track.Id)
+
+	return res
+}
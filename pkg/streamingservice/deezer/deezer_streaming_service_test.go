@@ -0,0 +1,269 @@
+package deezer_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"github.com/yukitsune/maestro/pkg/streamingservice/deezer"
+)
+
+func newTestService(t *testing.T) streamingservice.StreamingService {
+	srv, err := clients.NewFixtureServer(filepath.Join("testdata", "fixtures"), "https://api.deezer.com")
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+
+	v := viper.New()
+	v.Set("services.deezer.api_url", srv.URL)
+
+	// Share links are resolved without going anywhere near the network
+	transport := clients.NewRedirectingTransport(map[string]string{
+		"https://deezer.page.link/szbWkX6rKbfJ8XCD6": "https://www.deezer.com/en/track/3135556?deferredFl=1",
+		"https://deezer.page.link/notDeezer":         "https://example.com/track/3135556",
+	})
+
+	resolver := clients.NewRedirectResolver(streamingservice.ShortLinkHosts, 5, time.Second, cache.NewLRUCache(10, time.Minute), transport)
+	return deezer.NewDeezerStreamingService(config.NewDeezerViperConfig(v), metrics.NewNoopMetricsRecorder(), http.DefaultTransport, resolver)
+}
+
+func Test_GetFromLink(t *testing.T) {
+	testCases := []struct {
+		name       string
+		link       string
+		expectType model.Type
+		expect     any
+	}{
+		{
+			name:       "artist",
+			link:       "https://www.deezer.com/en/artist/1143",
+			expectType: model.ArtistType,
+			expect: &model.Artist{
+				Name:        "Cheap Trick",
+				ArtworkLink: "https://api.deezer.com/artist/1143/image",
+				Source:      model.DeezerStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://www.deezer.com/artist/1143",
+				ServiceId:   "1143",
+			},
+		},
+		{
+			name:       "album",
+			link:       "https://www.deezer.com/album/302127",
+			expectType: model.AlbumType,
+			expect: &model.Album{
+				Upc:         "074643529821",
+				Name:        "Heaven Tonight",
+				ArtistNames: []string{"Cheap Trick"},
+				ArtworkLink: "https://api.deezer.com/album/302127/image",
+				Source:      model.DeezerStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://www.deezer.com/album/302127",
+				ServiceId:   "302127",
+			},
+		},
+		{
+			name:       "track",
+			link:       "https://www.deezer.com/en/track/3135556?utm_source=deezer",
+			expectType: model.TrackType,
+			expect: &model.Track{
+				Isrc:        "USSM17800845",
+				Name:        "Surrender",
+				ArtistNames: []string{"Cheap Trick"},
+				AlbumName:   "Heaven Tonight",
+				ArtworkLink: "https://api.deezer.com/album/302127/image",
+				Source:      model.DeezerStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://www.deezer.com/track/3135556",
+				ServiceId:   "3135556",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			typ, res, err := svc.GetFromLink(testCase.link)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectType, typ)
+			assert.Equal(t, testCase.expect, res)
+		})
+	}
+}
+
+func Test_SearchArtist(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchArtist(&model.Artist{Name: "Cheap Trick", Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Cheap Trick", res.Name)
+	assert.Equal(t, "https://www.deezer.com/artist/1143", res.Link)
+	assert.Equal(t, model.DeezerStreamingService, res.Source)
+}
+
+func Test_SearchAlbum(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchAlbum(&model.Album{Name: "Heaven Tonight", ArtistNames: []string{"Cheap Trick"}, Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Heaven Tonight", res.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, res.ArtistNames)
+	assert.Equal(t, "https://www.deezer.com/album/302127", res.Link)
+}
+
+func Test_SearchTrack(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchTrack(&model.Track{Name: "Surrender", AlbumName: "Heaven Tonight", ArtistNames: []string{"Cheap Trick"}, Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// Search results don't include the ISRC, so the track needs to be looked up again
+	assert.Equal(t, "USSM17800845", res.Isrc)
+	assert.Equal(t, "https://www.deezer.com/track/3135556", res.Link)
+}
+
+func Test_GetTrackByIsrc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		isrc        string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known isrc",
+			isrc:        "USSM17800845",
+			expectFound: true,
+			expectLink:  "https://www.deezer.com/track/3135556",
+		},
+		{
+			name:        "unknown isrc",
+			isrc:        "XX0000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetTrackByIsrc(testCase.isrc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.isrc, res.Isrc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_GetAlbumByUpc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		upc         string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known upc",
+			upc:         "074643529821",
+			expectFound: true,
+			expectLink:  "https://www.deezer.com/album/302127",
+		},
+		{
+			name:        "unknown upc",
+			upc:         "000000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetAlbumByUpc(testCase.upc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.upc, res.Upc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_GetFromLinkForRemovedTrack(t *testing.T) {
+	svc := newTestService(t)
+
+	// Deezer responds with an error in the body rather than a 404
+	typ, res, err := svc.GetFromLink("https://www.deezer.com/track/9999999999")
+	require.NoError(t, err)
+	assert.Equal(t, model.UnknownType, typ)
+	assert.Nil(t, res)
+}
+
+func Test_Search(t *testing.T) {
+	svc := newTestService(t)
+
+	res, err := svc.Search("Cheap Trick Surrender", model.TrackType, 5)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	track, ok := res[0].(*model.Track)
+	require.True(t, ok)
+	assert.Equal(t, "Surrender", track.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, track.ArtistNames)
+	assert.Equal(t, "Heaven Tonight", track.AlbumName)
+	assert.Equal(t, "", track.Isrc)
+	assert.Equal(t, "https://www.deezer.com/track/3135556", track.Link)
+}
+
+func Test_GetFromLinkForShareLink(t *testing.T) {
+	svc := newTestService(t)
+
+	typ, res, err := svc.GetFromLink("https://deezer.page.link/szbWkX6rKbfJ8XCD6")
+	require.NoError(t, err)
+	assert.Equal(t, model.TrackType, typ)
+	assert.Equal(t, "https://www.deezer.com/track/3135556", res.(*model.Track).Link)
+
+	// Share links which don't end up at Deezer are refused
+	_, _, err = svc.GetFromLink("https://deezer.page.link/notDeezer")
+	assert.Error(t, err)
+}
+
+func Test_LinkBelongsToService(t *testing.T) {
+	testCases := []struct {
+		link   string
+		expect bool
+	}{
+		{"https://www.deezer.com/en/track/3135556", true},
+		{"https://deezer.page.link/szbWkX6rKbfJ8XCD6", true},
+		{"https://link.deezer.com/s/30ABCDEF", true},
+		{"http://169.254.169.254/latest?r=deezer.page.link/abc", false},
+		{"https://example.com/www.deezer.com/track/3135556", false},
+	}
+
+	svc := newTestService(t)
+	for _, testCase := range testCases {
+		t.Run(testCase.link, func(t *testing.T) {
+			assert.Equal(t, testCase.expect, svc.LinkBelongsToService(testCase.link))
+		})
+	}
+}
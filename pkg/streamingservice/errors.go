@@ -0,0 +1,73 @@
+package streamingservice
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/yukitsune/maestro/pkg/clients"
+)
+
+type ErrorClass string
+
+const (
+	ErrorClassCircuitOpen  ErrorClass = "circuit_open"
+	ErrorClassTimeout      ErrorClass = "timeout"
+	ErrorClassRateLimited  ErrorClass = "rate_limited"
+	ErrorClassUnauthorized ErrorClass = "unauthorized"
+	ErrorClassUpstream     ErrorClass = "upstream_error"
+	ErrorClassBadResponse  ErrorClass = "bad_response"
+	ErrorClassNetwork      ErrorClass = "network"
+	ErrorClassUnknown      ErrorClass = "unknown"
+)
+
+func (c ErrorClass) String() string {
+	return string(c)
+}
+
+// ClassifyError figures out roughly why a request to a streaming service failed
+func ClassifyError(err error) ErrorClass {
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorClassCircuitOpen
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var resErr *clients.ResponseError
+	if errors.As(err, &resErr) {
+		switch {
+		case resErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorClassRateLimited
+		case resErr.StatusCode == http.StatusUnauthorized || resErr.StatusCode == http.StatusForbidden:
+			return ErrorClassUnauthorized
+		case resErr.StatusCode >= http.StatusInternalServerError:
+			return ErrorClassUpstream
+		default:
+			return ErrorClassBadResponse
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassUnknown
+}
+
+// IsNotFound returns true if the service told us that the thing we asked for doesn't exist
+func IsNotFound(err error) bool {
+	var resErr *clients.ResponseError
+	if errors.As(err, &resErr) {
+		return resErr.StatusCode == http.StatusNotFound || resErr.StatusCode == http.StatusGone
+	}
+
+	return false
+}
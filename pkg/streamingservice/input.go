@@ -0,0 +1,168 @@
+package streamingservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yukitsune/maestro/pkg/clients"
+)
+
+type InputType string
+
+const (
+	LinkInput InputType = "link"
+	IsrcInput InputType = "isrc"
+	UpcInput  InputType = "upc"
+)
+
+// ErrInvalidInput is returned when the input isn't something we know how to look up
+var ErrInvalidInput = errors.New("invalid input")
+
+// Input is something which can be looked up, normalised so that the same thing always has the same value
+type Input struct {
+	Type  InputType
+	Value string
+}
+
+// InputParser figures out what someone has given us, e.g. a link, a Spotify URI, or an ISRC
+type InputParser interface {
+	Parse(ctx context.Context, input string) (*Input, error)
+}
+
+var (
+	isrcPattern       = regexp.MustCompile("^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$")
+	upcPattern        = regexp.MustCompile("^[0-9]{12,13}$")
+	spotifyURIPattern = regexp.MustCompile("^spotify:(?P<type>artist|album|track):(?P<id>[A-Za-z0-9]+)$")
+)
+
+// ShortLinkHosts are the hosts whose links need to be followed to find out what they point to
+var ShortLinkHosts = []string{
+	"spotify.link",
+	"spoti.fi",
+	"link.deezer.com",
+	"deezer.page.link",
+}
+
+type inputParser struct {
+	resolver clients.RedirectResolver
+}
+
+// NewInputParser creates an InputParser which expands short links with the given resolver.
+// The resolver should accept the ShortLinkHosts.
+func NewInputParser(resolver clients.RedirectResolver) InputParser {
+	return &inputParser{resolver}
+}
+
+func (p *inputParser) Parse(ctx context.Context, input string) (*Input, error) {
+	input = strings.TrimSpace(input)
+	if len(input) == 0 {
+		return nil, fmt.Errorf("%w: nothing to look up", ErrInvalidInput)
+	}
+
+	// spotify:track:4cOdK2wGLETKBW3PvgPWqT
+	if spotifyURIPattern.MatchString(input) {
+		matches := FindStringSubmatchMap(spotifyURIPattern, input)
+		link := fmt.Sprintf("https://open.spotify.com/%s/%s", matches["type"], matches["id"])
+		return &Input{LinkInput, link}, nil
+	}
+
+	// ISRCs and UPCs are often written with spaces or hyphens between the groups of characters
+	if !strings.ContainsAny(input, "/:.") {
+		code := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(input))
+		if isrcPattern.MatchString(code) {
+			return &Input{IsrcInput, code}, nil
+		}
+
+		if upcPattern.MatchString(code) {
+			return &Input{UpcInput, code}, nil
+		}
+	}
+
+	u, err := url.Parse(input)
+	if err != nil || u == nil {
+		return nil, fmt.Errorf("%w: couldn't parse the given link: %s", ErrInvalidInput, input)
+	}
+
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%w: given link must be absolute", ErrInvalidInput)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: unsupported link: %s", ErrInvalidInput, input)
+	}
+
+	if p.resolver.CanResolve(u.String()) {
+		u, err = p.expand(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Input{LinkInput, canonicalLink(u).String()}, nil
+}
+
+// expand finds out where a short link points to
+func (p *inputParser) expand(ctx context.Context, u *url.URL) (*url.URL, error) {
+	resolved, err := p.resolver.Resolve(ctx, u.String())
+	if err != nil {
+		// Short links which go nowhere (or somewhere we won't go) are as good as no link at all
+		if errors.Is(err, clients.ErrNoRedirect) || errors.Is(err, clients.ErrTooManyRedirects) || errors.Is(err, clients.ErrPrivateAddress) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+		}
+
+		return nil, fmt.Errorf("failed to follow short link %s: %w", u, err)
+	}
+
+	expanded, err := url.Parse(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("%w: short link %s goes to an invalid link", ErrInvalidInput, u)
+	}
+
+	return expanded, nil
+}
+
+// canonicalLink rewrites the older or regional forms of links into the ones the streaming services understand.
+// It reads links the same way as ParseLink, so that both agree on what a link points to.
+func canonicalLink(u *url.URL) *url.URL {
+	c := *u
+
+	switch linkHost(u) {
+
+	// https://open.spotify.com/intl-de/track/4cOdK2wGLETKBW3PvgPWqT
+	case "open.spotify.com":
+		segments := linkSegments(u)
+		if trimmed := trimSpotifyLanguage(segments); len(trimmed) != len(segments) {
+			c.Path = "/" + strings.Join(trimmed, "/")
+		}
+
+	// https://geo.music.apple.com/au/album/surrender/1585865534?i=1585865535&app=music
+	case "geo.music.apple.com":
+		c.Host = "music.apple.com"
+		c.RawQuery = appleMusicQuery(c.Query())
+
+	// https://itunes.apple.com/au/album/surrender/id1585865534?i=1585865535&uo=4
+	case "itunes.apple.com":
+		c.Host = "music.apple.com"
+		if segments := linkSegments(u); len(segments) > 0 {
+			segments[len(segments)-1] = trimItunesId(segments[len(segments)-1])
+			c.Path = "/" + strings.Join(segments, "/")
+		}
+		c.RawQuery = appleMusicQuery(c.Query())
+	}
+
+	return &c
+}
+
+// appleMusicQuery keeps the song ID from an Apple Music link, and drops everything else
+func appleMusicQuery(q url.Values) string {
+	songId := q.Get("i")
+	if len(songId) == 0 {
+		return ""
+	}
+
+	return url.Values{"i": {songId}}.Encode()
+}
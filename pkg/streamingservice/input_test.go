@@ -0,0 +1,156 @@
+package streamingservice_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/cache"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+func Test_ParseInput(t *testing.T) {
+	transport := clients.NewRedirectingTransport(map[string]string{
+		"https://spotify.link/AbCdEf":                "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT?si=10587ef152a8493f",
+		"https://spoti.fi/3xYz":                      "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+		"https://link.deezer.com/s/30ABCDEF":         "https://deezer.page.link/szbWkX6rKbfJ8XCD6",
+		"https://deezer.page.link/szbWkX6rKbfJ8XCD6": "https://www.deezer.com/en/track/606334862?deferredFl=1",
+	})
+
+	testCases := []struct {
+		name          string
+		input         string
+		expectInvalid bool
+		expectType    streamingservice.InputType
+		expectValue   string
+	}{
+		{
+			name:        "link",
+			input:       "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT",
+		},
+		{
+			name:        "spotify uri",
+			input:       "spotify:track:4cOdK2wGLETKBW3PvgPWqT",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT",
+		},
+		{
+			name:        "regional spotify link",
+			input:       "https://open.spotify.com/intl-de/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+		},
+		{
+			name:        "regional spotify link with www",
+			input:       "https://www.open.spotify.com/intl-pt-BR/track/4cOdK2wGLETKBW3PvgPWqT",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://www.open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT",
+		},
+		{
+			name:        "spotify.link short link",
+			input:       "https://spotify.link/AbCdEf",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT?si=10587ef152a8493f",
+		},
+		{
+			name:        "spoti.fi short link",
+			input:       "https://spoti.fi/3xYz",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+		},
+		{
+			name:        "deezer short link which redirects to another short link",
+			input:       "https://link.deezer.com/s/30ABCDEF",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://www.deezer.com/en/track/606334862?deferredFl=1",
+		},
+		{
+			name:          "short link which doesn't go anywhere",
+			input:         "https://spotify.link/missing",
+			expectInvalid: true,
+		},
+		{
+			name:        "geo apple music link",
+			input:       "https://geo.music.apple.com/au/album/surrender/1585865534?i=1585865535&app=music",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://music.apple.com/au/album/surrender/1585865534?i=1585865535",
+		},
+		{
+			name:        "itunes link",
+			input:       "https://itunes.apple.com/au/album/heaven-tonight/id1585865534?uo=4",
+			expectType:  streamingservice.LinkInput,
+			expectValue: "https://music.apple.com/au/album/heaven-tonight/1585865534",
+		},
+		{
+			name:        "isrc",
+			input:       "USSM17800845",
+			expectType:  streamingservice.IsrcInput,
+			expectValue: "USSM17800845",
+		},
+		{
+			name:        "hyphenated lowercase isrc",
+			input:       " us-sm1-78-00845 ",
+			expectType:  streamingservice.IsrcInput,
+			expectValue: "USSM17800845",
+		},
+		{
+			name:        "upc",
+			input:       "074643529821",
+			expectType:  streamingservice.UpcInput,
+			expectValue: "074643529821",
+		},
+		{
+			name:        "ean",
+			input:       "0074643529821",
+			expectType:  streamingservice.UpcInput,
+			expectValue: "0074643529821",
+		},
+		{
+			name:          "empty",
+			input:         "  ",
+			expectInvalid: true,
+		},
+		{
+			name:          "free text",
+			input:         "cheap trick surrender",
+			expectInvalid: true,
+		},
+		{
+			name:          "relative link",
+			input:         "/track/4cOdK2wGLETKBW3PvgPWqT",
+			expectInvalid: true,
+		},
+		{
+			name:          "unsupported spotify uri",
+			input:         "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M",
+			expectInvalid: true,
+		},
+	}
+
+	resolver := clients.NewRedirectResolver(streamingservice.ShortLinkHosts, 5, time.Second, cache.NewLRUCache(10, time.Minute), transport)
+	parser := streamingservice.NewInputParser(resolver)
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			res, err := parser.Parse(context.Background(), testCase.input)
+			if testCase.expectInvalid {
+				assert.ErrorIs(t, err, streamingservice.ErrInvalidInput)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectType, res.Type)
+			assert.Equal(t, testCase.expectValue, res.Value)
+
+			// Rewriting a link shouldn't change what it points to
+			if key, ok := streamingservice.LinkKey(testCase.input); ok {
+				rewrittenKey, _ := streamingservice.LinkKey(res.Value)
+				assert.Equal(t, key, rewrittenKey)
+			}
+		})
+	}
+}
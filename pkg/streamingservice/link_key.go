@@ -0,0 +1,130 @@
+package streamingservice
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// LinkInfo is what can be worked out about a thing from its link alone, without asking the streaming service
+type LinkInfo struct {
+	Source model.StreamingServiceType
+	Type   model.Type
+	Id     string
+
+	// Storefront is only known for Apple Music links
+	Storefront string
+}
+
+// Key identifies the thing the link points to, e.g. spotify:track:4cOdK2wGLETKBW3PvgPWqT
+func (l *LinkInfo) Key() string {
+	return fmt.Sprintf("%s:%s:%s", l.Source, l.Type, l.Id)
+}
+
+// LinkKey identifies what a link points to, e.g. spotify:track:4cOdK2wGLETKBW3PvgPWqT.
+// Links which are written differently but point to the same thing (different storefronts, languages, query
+// parameters, etc.) all have the same key. Returns false if the link isn't one we know how to identify.
+func LinkKey(link string) (string, bool) {
+	info, ok := ParseLink(link)
+	if !ok {
+		return "", false
+	}
+
+	return info.Key(), true
+}
+
+// ParseLink works out which service, type, and ID a link points to.
+// Returns false if the link isn't one we know how to identify.
+func ParseLink(link string) (*LinkInfo, bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u == nil {
+		return nil, false
+	}
+
+	segments := linkSegments(u)
+
+	switch linkHost(u) {
+
+	// https://open.spotify.com/intl-de/track/4cOdK2wGLETKBW3PvgPWqT
+	case "open.spotify.com":
+		segments = trimSpotifyLanguage(segments)
+		if len(segments) != 2 {
+			return nil, false
+		}
+
+		return newLinkInfo(model.SpotifyStreamingService, segments[0], segments[1], "")
+
+	// https://music.apple.com/au/album/surrender/1585865534?i=1585865535
+	case "music.apple.com", "geo.music.apple.com", "itunes.apple.com":
+		if len(segments) < 3 {
+			return nil, false
+		}
+
+		// The name in the link doesn't change what it points to
+		typ := segments[1]
+		id := trimItunesId(segments[len(segments)-1])
+		if songId := u.Query().Get("i"); typ == "album" && len(songId) > 0 {
+			typ, id = "song", songId
+		}
+
+		if typ == "song" {
+			typ = string(model.TrackType)
+		}
+
+		return newLinkInfo(model.AppleMusicStreamingService, typ, id, strings.ToLower(segments[0]))
+
+	// https://www.deezer.com/en/track/606334862
+	case "deezer.com":
+		if len(segments) == 3 {
+			segments = segments[1:]
+		}
+
+		if len(segments) != 2 {
+			return nil, false
+		}
+
+		return newLinkInfo(model.DeezerStreamingService, segments[0], segments[1], "")
+	}
+
+	return nil, false
+}
+
+func newLinkInfo(source model.StreamingServiceType, typ string, id string, storefront string) (*LinkInfo, bool) {
+	switch model.Type(typ) {
+	case model.ArtistType, model.AlbumType, model.TrackType:
+	default:
+		return nil, false
+	}
+
+	if len(id) == 0 {
+		return nil, false
+	}
+
+	return &LinkInfo{source, model.Type(typ), id, storefront}, true
+}
+
+// linkHost is the host of a link, without the "www." some links have in front of it
+func linkHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// linkSegments splits the path of a link into its non-empty segments
+func linkSegments(u *url.URL) []string {
+	return strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+}
+
+// trimSpotifyLanguage drops the language from the path of a Spotify link, e.g. intl-de/track/… becomes track/…
+func trimSpotifyLanguage(segments []string) []string {
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "intl-") {
+		return segments[1:]
+	}
+
+	return segments
+}
+
+// trimItunesId drops the "id" iTunes links put in front of their IDs, e.g. id1585865534 becomes 1585865534
+func trimItunesId(segment string) string {
+	return strings.TrimPrefix(segment, "id")
+}
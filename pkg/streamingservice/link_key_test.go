@@ -0,0 +1,102 @@
+package streamingservice_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+func Test_LinkKey(t *testing.T) {
+	testCases := []struct {
+		name      string
+		link      string
+		expectKey string
+	}{
+		{
+			name:      "spotify",
+			link:      "https://open.spotify.com/track/4cOdK2wGLETKBW3PvgPWqT?si=10587ef152a8493f",
+			expectKey: "spotify:track:4cOdK2wGLETKBW3PvgPWqT",
+		},
+		{
+			name:      "regional spotify link over http",
+			link:      "http://open.spotify.com/intl-de/track/4cOdK2wGLETKBW3PvgPWqT",
+			expectKey: "spotify:track:4cOdK2wGLETKBW3PvgPWqT",
+		},
+		{
+			name:      "apple music album",
+			link:      "https://music.apple.com/au/album/heaven-tonight/1585865534",
+			expectKey: "apple_music:album:1585865534",
+		},
+		{
+			name:      "apple music album from another storefront",
+			link:      "https://music.apple.com/gb/album/1585865534",
+			expectKey: "apple_music:album:1585865534",
+		},
+		{
+			name:      "apple music track on an album",
+			link:      "https://music.apple.com/us/album/surrender/1585865534?i=1585865535",
+			expectKey: "apple_music:track:1585865535",
+		},
+		{
+			name:      "apple music song",
+			link:      "https://music.apple.com/us/song/surrender/1585865535",
+			expectKey: "apple_music:track:1585865535",
+		},
+		{
+			name:      "itunes artist",
+			link:      "https://itunes.apple.com/us/artist/cheap-trick/id460807",
+			expectKey: "apple_music:artist:460807",
+		},
+		{
+			name:      "deezer",
+			link:      "https://www.deezer.com/track/606334862",
+			expectKey: "deezer:track:606334862",
+		},
+		{
+			name:      "deezer with a language",
+			link:      "https://deezer.com/en/album/87375282",
+			expectKey: "deezer:album:87375282",
+		},
+		{
+			name: "deezer share link",
+			link: "https://deezer.page.link/szbWkX6rKbfJ8XCD6",
+		},
+		{
+			name: "playlist",
+			link: "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M",
+		},
+		{
+			name: "unknown service",
+			link: "https://example.com/track/123",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			key, ok := streamingservice.LinkKey(testCase.link)
+			assert.Equal(t, len(testCase.expectKey) > 0, ok)
+			assert.Equal(t, testCase.expectKey, key)
+		})
+	}
+}
+
+func Test_ParseLink(t *testing.T) {
+	info, ok := streamingservice.ParseLink("https://music.apple.com/GB/album/surrender/1585865534?i=1585865535")
+	require.True(t, ok)
+
+	assert.Equal(t, model.AppleMusicStreamingService, info.Source)
+	assert.Equal(t, model.TrackType, info.Type)
+	assert.Equal(t, "1585865535", info.Id)
+	assert.Equal(t, "gb", info.Storefront)
+
+	info, ok = streamingservice.ParseLink("https://www.deezer.com/fr/album/87375282")
+	require.True(t, ok)
+
+	assert.Equal(t, model.DeezerStreamingService, info.Source)
+	assert.Equal(t, model.AlbumType, info.Type)
+	assert.Equal(t, "87375282", info.Id)
+	assert.Empty(t, info.Storefront)
+}
@@ -2,7 +2,10 @@ package provider
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/yukitsune/maestro/pkg/clients"
 	"github.com/yukitsune/maestro/pkg/config"
 	"github.com/yukitsune/maestro/pkg/metrics"
 	"github.com/yukitsune/maestro/pkg/model"
@@ -10,24 +13,31 @@ import (
 	"github.com/yukitsune/maestro/pkg/streamingservice/applemusic"
 	"github.com/yukitsune/maestro/pkg/streamingservice/deezer"
 	"github.com/yukitsune/maestro/pkg/streamingservice/spotify"
+	"golang.org/x/time/rate"
 )
 
 type defaultServiceProvider struct {
 	cfgMap   map[model.StreamingServiceType]config.Service
 	svcFuncs map[model.StreamingServiceType]func(config.Service) (streamingservice.StreamingService, error)
+	breakers map[model.StreamingServiceType]*streamingservice.CircuitBreaker
 }
 
-func NewDefaultProvider(cfg config.Services, rec metrics.Recorder) (streamingservice.ServiceProvider, error) {
+func NewDefaultProvider(cfg config.Services, resolver clients.RedirectResolver, rec metrics.Recorder) (streamingservice.ServiceProvider, error) {
 
 	cfgMap := cfg.AsMap()
 	svcFuncs := make(map[model.StreamingServiceType]func(config.Service) (streamingservice.StreamingService, error))
+	breakers := make(map[model.StreamingServiceType]*streamingservice.CircuitBreaker)
 
-	for key, _ := range cfgMap {
+	// Services are created for every request, but the transports (and their rate limiters) and circuit breakers
+	// need to be shared by every request, so they're created up-front
+	for key, cfg := range cfgMap {
 		switch key {
 		case model.AppleMusicStreamingService:
+			transport := newTransport(key, cfg.(config.AppleMusic).Timeout(), cfg.(config.AppleMusic).RateLimit(), rec)
+			breakers[key] = newCircuitBreaker(key, cfg.(config.AppleMusic).CircuitBreaker(), rec)
 			fn := func(cfg config.Service) (streamingservice.StreamingService, error) {
 				appleCfg := cfg.(config.AppleMusic)
-				svc := applemusic.NewAppleMusicStreamingService(appleCfg, rec)
+				svc := applemusic.NewAppleMusicStreamingService(appleCfg, rec, transport)
 				return svc, nil
 			}
 
@@ -35,9 +45,11 @@ func NewDefaultProvider(cfg config.Services, rec metrics.Recorder) (streamingser
 			break
 
 		case model.DeezerStreamingService:
+			transport := newTransport(key, cfg.(config.Deezer).Timeout(), cfg.(config.Deezer).RateLimit(), rec)
+			breakers[key] = newCircuitBreaker(key, cfg.(config.Deezer).CircuitBreaker(), rec)
 			fn := func(cfg config.Service) (streamingservice.StreamingService, error) {
 				deezerCfg := cfg.(config.Deezer)
-				svc := deezer.NewDeezerStreamingService(deezerCfg, rec)
+				svc := deezer.NewDeezerStreamingService(deezerCfg, rec, transport, resolver)
 				return svc, nil
 			}
 
@@ -45,9 +57,11 @@ func NewDefaultProvider(cfg config.Services, rec metrics.Recorder) (streamingser
 			break
 
 		case model.SpotifyStreamingService:
+			transport := newTransport(key, cfg.(config.Spotify).Timeout(), cfg.(config.Spotify).RateLimit(), rec)
+			breakers[key] = newCircuitBreaker(key, cfg.(config.Spotify).CircuitBreaker(), rec)
 			fn := func(cfg config.Service) (streamingservice.StreamingService, error) {
 				spotifyCfg := cfg.(config.Spotify)
-				s, err := spotify.NewSpotifyStreamingService(spotifyCfg, rec)
+				s, err := spotify.NewSpotifyStreamingService(spotifyCfg, rec, transport)
 				if err != nil {
 					return nil, fmt.Errorf("failed to initialize spotify streaming service: %s", err.Error())
 				}
@@ -63,9 +77,50 @@ func NewDefaultProvider(cfg config.Services, rec metrics.Recorder) (streamingser
 	return &defaultServiceProvider{
 		cfgMap,
 		svcFuncs,
+		breakers,
 	}, nil
 }
 
+func newTransport(key model.StreamingServiceType, timeout time.Duration, cfg config.RateLimit, rec metrics.Recorder) http.RoundTripper {
+	limiter := newLimiter(cfg)
+	onThrottle := func(*http.Response) {
+		go rec.CountThrottledRequest(key.String())
+	}
+
+	// Retries need to wait for the rate limiter too, so the retry transport goes on the outside.
+	// The timeout covers everything, so it goes on the outside of that.
+	return clients.NewTimeoutTransport(
+		timeout,
+		clients.NewRetryTransport(
+			cfg.MaxRetries(),
+			cfg.MaxBackoff(),
+			onThrottle,
+			clients.NewRateLimitTransport(limiter, http.DefaultTransport)))
+}
+
+// newLimiter creates a rate limiter from the given config, fixing any settings the limiter can't work with.
+// A burst of less than 1 would let no requests through at all, so at least 1 request is always allowed.
+func newLimiter(cfg config.RateLimit) *rate.Limiter {
+	rps := cfg.RequestsPerSecond()
+	if rps < 0 {
+		rps = 0
+	}
+
+	burst := cfg.Burst()
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+func newCircuitBreaker(key model.StreamingServiceType, cfg config.CircuitBreaker, rec metrics.Recorder) *streamingservice.CircuitBreaker {
+	rec.ReportCircuitState(key.String(), model.CircuitClosed)
+	return streamingservice.NewCircuitBreaker(cfg, func(state model.CircuitState) {
+		rec.ReportCircuitState(key.String(), state)
+	})
+}
+
 func (p *defaultServiceProvider) GetService(serviceType model.StreamingServiceType) (streamingservice.StreamingService, error) {
 	cfg, err := p.GetConfig(serviceType)
 	if err != nil {
@@ -77,7 +132,7 @@ func (p *defaultServiceProvider) GetService(serviceType model.StreamingServiceTy
 		return nil, fmt.Errorf("couldn't find service type %s", serviceType)
 	}
 
-	return svcFunc(cfg)
+	return p.createService(serviceType, cfg, svcFunc)
 }
 
 func (p *defaultServiceProvider) ListServices() (streamingservice.StreamingServices, error) {
@@ -88,11 +143,12 @@ func (p *defaultServiceProvider) ListServices() (streamingservice.StreamingServi
 			return nil, err
 		}
 
+		// Disabled services are left out, callers can find them via ListConfigs
 		if !cfg.Enabled() {
-			return nil, fmt.Errorf("service %s is disabled", key)
+			continue
 		}
 
-		svc, err := fn(cfg)
+		svc, err := p.createService(key, cfg, fn)
 		if err != nil {
 			return nil, err
 		}
@@ -103,6 +159,20 @@ func (p *defaultServiceProvider) ListServices() (streamingservice.StreamingServi
 	return svcs, nil
 }
 
+func (p *defaultServiceProvider) createService(key model.StreamingServiceType, cfg config.Service, fn func(config.Service) (streamingservice.StreamingService, error)) (streamingservice.StreamingService, error) {
+	svc, err := fn(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	breaker, ok := p.breakers[key]
+	if !ok {
+		return svc, nil
+	}
+
+	return streamingservice.WithCircuitBreaker(svc, breaker), nil
+}
+
 func (p *defaultServiceProvider) GetConfig(key model.StreamingServiceType) (config.Service, error) {
 	cfg, ok := p.cfgMap[key]
 	if !ok {
@@ -115,3 +185,12 @@ func (p *defaultServiceProvider) GetConfig(key model.StreamingServiceType) (conf
 func (p *defaultServiceProvider) ListConfigs() map[model.StreamingServiceType]config.Service {
 	return p.cfgMap
 }
+
+func (p *defaultServiceProvider) GetCircuitState(key model.StreamingServiceType) model.CircuitState {
+	breaker, ok := p.breakers[key]
+	if !ok {
+		return model.CircuitClosed
+	}
+
+	return breaker.State()
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/yukitsune/maestro/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+func Test_NewLimiter(t *testing.T) {
+	testCases := []struct {
+		name              string
+		requestsPerSecond float64
+		burst             int
+		expectLimit       rate.Limit
+		expectBurst       int
+	}{
+		{
+			name:              "valid settings are kept",
+			requestsPerSecond: 10,
+			burst:             5,
+			expectLimit:       10,
+			expectBurst:       5,
+		},
+		{
+			name:              "zero burst allows one request",
+			requestsPerSecond: 10,
+			burst:             0,
+			expectLimit:       10,
+			expectBurst:       1,
+		},
+		{
+			name:              "negative burst allows one request",
+			requestsPerSecond: 10,
+			burst:             -3,
+			expectLimit:       10,
+			expectBurst:       1,
+		},
+		{
+			name:              "negative requests per second becomes zero",
+			requestsPerSecond: -1,
+			burst:             5,
+			expectLimit:       0,
+			expectBurst:       5,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cfg := config.NewRateLimitViperConfig(viper.New(), "test", testCase.requestsPerSecond, testCase.burst)
+
+			limiter := newLimiter(cfg)
+
+			assert.Equal(t, testCase.expectLimit, limiter.Limit())
+			assert.Equal(t, testCase.expectBurst, limiter.Burst())
+			assert.True(t, limiter.Allow(), "the first request should always be allowed")
+		})
+	}
+}
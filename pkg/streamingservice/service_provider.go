@@ -10,4 +10,5 @@ type ServiceProvider interface {
 	ListServices() (StreamingServices, error)
 	GetConfig(model.StreamingServiceType) (config.Service, error)
 	ListConfigs() map[model.StreamingServiceType]config.Service
+	GetCircuitState(model.StreamingServiceType) model.CircuitState
 }
@@ -3,9 +3,11 @@ package spotify
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	clients2 "github.com/yukitsune/maestro/pkg/clients"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"regexp"
 
@@ -22,8 +24,8 @@ type spotifyStreamingService struct {
 	metricsRecorder  metrics.Recorder
 }
 
-func GetAccessToken(clientID string, secret string) (token string, error error) {
-	tokenURL := "https://accounts.spotify.com/api/token"
+func GetAccessToken(accountsURL string, clientID string, secret string) (token string, error error) {
+	tokenURL := fmt.Sprintf("%s/api/token", accountsURL)
 
 	reqToken := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", clientID, secret)))
 	client := clients2.NewClientWithBasicAuth(reqToken)
@@ -31,12 +33,12 @@ func GetAccessToken(clientID string, secret string) (token string, error error)
 	res, err := client.PostForm(tokenURL, url.Values{
 		"grant_type": {"client_credentials"},
 	})
-	defer res.Body.Close()
-
 	if err != nil {
 		return token, err
 	}
 
+	defer res.Body.Close()
+
 	resBytes, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return token, err
@@ -61,17 +63,35 @@ func GetAccessToken(clientID string, secret string) (token string, error error)
 	return token, nil
 }
 
-func NewSpotifyStreamingService(cfg config.Spotify, mr metrics.Recorder) (*spotifyStreamingService, error) {
+// apiError converts errors from the spotify client into the same errors that the other services return
+func apiError(err error) error {
+	var spotifyErr spotify.Error
+	if errors.As(err, &spotifyErr) {
+		return &clients2.ResponseError{
+			StatusCode: spotifyErr.Status,
+			Status:     fmt.Sprintf("%d %s", spotifyErr.Status, http.StatusText(spotifyErr.Status)),
+		}
+	}
+
+	return err
+}
+
+func NewSpotifyStreamingService(cfg config.Spotify, mr metrics.Recorder, transport http.RoundTripper) (*spotifyStreamingService, error) {
 	shareLinkPatternRegex := regexp.MustCompile("(https?:\\/\\/)?open\\.spotify\\.com\\/(?P<type>[A-Za-z]+)\\/(?P<id>[A-Za-z0-9]+)")
 
 	go mr.CountSpotifyRequest()
-	token, err := GetAccessToken(cfg.ClientId(), cfg.ClientSecret())
+	token, err := GetAccessToken(cfg.AccountsUrl(), cfg.ClientId(), cfg.ClientSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	// The spotify client doesn't let us change the base URL, so we need to rewrite the requests ourselves
+	apiTransport, err := clients2.NewBaseURLTransport(cfg.ApiUrl(), clients2.NewBearerAuthTransport(token, transport))
 	if err != nil {
 		return nil, err
 	}
 
-	c := clients2.NewClientWithBearerAuth(token)
-	sc := spotify.NewClient(c)
+	sc := spotify.NewClient(&http.Client{Transport: apiTransport})
 	return &spotifyStreamingService{
 		cfg,
 		&sc,
@@ -103,7 +123,7 @@ func (s *spotifyStreamingService) SearchArtist(artist *model.Artist) (*model.Art
 		Country: &country,
 	})
 	if err != nil {
-		return nil, false, err
+		return nil, false, apiError(err)
 	}
 
 	if searchRes.Artists == nil || len(searchRes.Artists.Artists) == 0 {
@@ -111,12 +131,7 @@ func (s *spotifyStreamingService) SearchArtist(artist *model.Artist) (*model.Art
 	}
 
 	spotifyArtist := searchRes.Artists.Artists[0]
-	res := model.NewArtist(
-		spotifyArtist.Name,
-		imageURL(spotifyArtist.Images),
-		s.Key(),
-		model.DefaultMarket,
-		spotifyArtist.ExternalURLs["spotify"])
+	res := s.newArtist(&spotifyArtist)
 
 	return res, true, nil
 }
@@ -139,7 +154,7 @@ func (s *spotifyStreamingService) SearchAlbum(album *model.Album) (*model.Album,
 			Country: &country,
 		})
 		if err != nil {
-			return nil, false, err
+			return nil, false, apiError(err)
 		}
 
 		if searchRes.Albums == nil || len(searchRes.Albums.Albums) == 0 {
@@ -149,29 +164,45 @@ func (s *spotifyStreamingService) SearchAlbum(album *model.Album) (*model.Album,
 		// Todo: Narrow down results
 		spotifyAlbum := searchRes.Albums.Albums[0]
 
-		res = model.NewAlbum(
-			spotifyAlbum.Name,
-			artistName(spotifyAlbum.Artists),
-			imageURL(spotifyAlbum.Images),
-			s.Key(),
-			model.DefaultMarket,
-			spotifyAlbum.ExternalURLs["spotify"])
+		res = s.newAlbum(&spotifyAlbum)
 	}
 
 	return res, res != nil, nil
 }
 
-func (s *spotifyStreamingService) GetTrackByIsrc(isrc string) (*model.Track, bool, error) {
+func (s *spotifyStreamingService) GetAlbumByUpc(upc string) (*model.Album, bool, error) {
 
-	q := fmt.Sprintf("isrc:\"%s\"", isrc)
+	go s.metricsRecorder.CountSpotifyRequest()
 
-	searchRes, err := s.client.SearchOpt(q, spotify.SearchTypeTrack, &spotify.Options{})
+	q := fmt.Sprintf("upc:\"%s\"", upc)
+
+	searchRes, err := s.client.SearchOpt(q, spotify.SearchTypeAlbum, &spotify.Options{})
 	if err != nil {
-		return nil, false, err
+		return nil, false, apiError(err)
 	}
 
+	if searchRes.Albums == nil || len(searchRes.Albums.Albums) == 0 {
+		return nil, false, nil
+	}
+
+	// Todo: Narrow down results
+	spotifyAlbum := searchRes.Albums.Albums[0]
+
+	res := s.newAlbum(&spotifyAlbum)
+
+	// Search results don't include external IDs, but we already know what it is
+	res.Upc = upc
+
+	return res, true, nil
+}
+
+func (s *spotifyStreamingService) GetTrackByIsrc(isrc string) (*model.Track, bool, error) {
+
+	q := fmt.Sprintf("isrc:\"%s\"", isrc)
+
+	searchRes, err := s.client.SearchOpt(q, spotify.SearchTypeTrack, &spotify.Options{})
 	if err != nil {
-		return nil, false, err
+		return nil, false, apiError(err)
 	}
 
 	if searchRes.Tracks == nil || len(searchRes.Tracks.Tracks) == 0 {
@@ -181,15 +212,7 @@ func (s *spotifyStreamingService) GetTrackByIsrc(isrc string) (*model.Track, boo
 	// Todo: Narrow down results
 	spotifyTrack := searchRes.Tracks.Tracks[0]
 
-	res := model.NewTrack(
-		spotifyTrack.ExternalIDs["isrc"],
-		spotifyTrack.Name,
-		artistName(spotifyTrack.Artists),
-		spotifyTrack.Album.Name,
-		imageURL(spotifyTrack.Album.Images),
-		s.Key(),
-		model.DefaultMarket,
-		spotifyTrack.ExternalURLs["spotify"])
+	res := s.newTrack(&spotifyTrack)
 
 	return res, true, nil
 }
@@ -219,7 +242,7 @@ func (s *spotifyStreamingService) SearchTrack(track *model.Track) (*model.Track,
 			Country: &country,
 		})
 		if err != nil {
-			return nil, false, err
+			return nil, false, apiError(err)
 		}
 
 		if searchRes.Tracks == nil || len(searchRes.Tracks.Tracks) == 0 {
@@ -229,15 +252,7 @@ func (s *spotifyStreamingService) SearchTrack(track *model.Track) (*model.Track,
 		// Todo: Narrow down results
 		spotifyTrack = searchRes.Tracks.Tracks[0]
 
-		res = model.NewTrack(
-			spotifyTrack.ExternalIDs["isrc"],
-			spotifyTrack.Name,
-			artistName(spotifyTrack.Artists),
-			spotifyTrack.Album.Name,
-			imageURL(spotifyTrack.Album.Images),
-			s.Key(),
-			model.DefaultMarket,
-			spotifyTrack.ExternalURLs["spotify"])
+		res = s.newTrack(&spotifyTrack)
 	}
 
 	return res, res != nil, nil
@@ -261,15 +276,10 @@ func (s *spotifyStreamingService) GetFromLink(link string) (model.Type, interfac
 
 		foundArtist, err := s.client.GetArtist(id)
 		if err != nil {
-			return model.UnknownType, false, err
+			return model.UnknownType, nil, apiError(err)
 		}
 
-		artist := model.NewArtist(
-			foundArtist.Name,
-			imageURL(foundArtist.Images),
-			s.Key(),
-			model.DefaultMarket,
-			foundArtist.ExternalURLs["spotify"])
+		artist := s.newArtist(foundArtist)
 
 		return model.ArtistType, artist, nil
 
@@ -278,16 +288,11 @@ func (s *spotifyStreamingService) GetFromLink(link string) (model.Type, interfac
 
 		foundAlbum, err := s.client.GetAlbum(id)
 		if err != nil {
-			return model.UnknownType, nil, err
+			return model.UnknownType, nil, apiError(err)
 		}
 
-		album := model.NewAlbum(
-			foundAlbum.Name,
-			artistName(foundAlbum.Artists),
-			imageURL(foundAlbum.Images),
-			s.Key(),
-			model.DefaultMarket,
-			foundAlbum.ExternalURLs["spotify"])
+		album := s.newAlbum(&foundAlbum.SimpleAlbum)
+		album.Upc = foundAlbum.ExternalIDs["upc"]
 
 		return model.AlbumType, album, nil
 
@@ -296,18 +301,10 @@ func (s *spotifyStreamingService) GetFromLink(link string) (model.Type, interfac
 
 		foundTrack, err := s.client.GetTrack(id)
 		if err != nil {
-			return model.UnknownType, nil, err
+			return model.UnknownType, nil, apiError(err)
 		}
 
-		track := model.NewTrack(
-			foundTrack.ExternalIDs["isrc"],
-			foundTrack.Name,
-			artistName(foundTrack.Artists),
-			foundTrack.Album.Name,
-			imageURL(foundTrack.Album.Images),
-			s.Key(),
-			model.DefaultMarket,
-			foundTrack.ExternalURLs["spotify"])
+		track := s.newTrack(foundTrack)
 
 		return model.TrackType, track, nil
 
@@ -316,6 +313,52 @@ func (s *spotifyStreamingService) GetFromLink(link string) (model.Type, interfac
 	}
 }
 
+func (s *spotifyStreamingService) Search(query string, typ model.Type, limit int) ([]model.Thing, error) {
+
+	var searchType spotify.SearchType
+	switch typ {
+	case model.ArtistType:
+		searchType = spotify.SearchTypeArtist
+	case model.AlbumType:
+		searchType = spotify.SearchTypeAlbum
+	case model.TrackType:
+		searchType = spotify.SearchTypeTrack
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	go s.metricsRecorder.CountSpotifyRequest()
+
+	searchRes, err := s.client.SearchOpt(query, searchType, &spotify.Options{
+		Limit: &limit,
+	})
+	if err != nil {
+		return nil, apiError(err)
+	}
+
+	var res []model.Thing
+	if searchRes.Artists != nil {
+		for _, spotifyArtist := range searchRes.Artists.Artists {
+			res = append(res, s.newArtist(&spotifyArtist))
+		}
+	}
+
+	// Simplified albums don't include the UPC, so albums found by searching will need to be matched by name
+	if searchRes.Albums != nil {
+		for _, spotifyAlbum := range searchRes.Albums.Albums {
+			res = append(res, s.newAlbum(&spotifyAlbum))
+		}
+	}
+
+	if searchRes.Tracks != nil {
+		for _, spotifyTrack := range searchRes.Tracks.Tracks {
+			res = append(res, s.newTrack(&spotifyTrack))
+		}
+	}
+
+	return res, nil
+}
+
 func (s *spotifyStreamingService) CleanLink(link string) string {
 
 	match := s.shareLinkPattern.FindStringIndex(link)
@@ -360,3 +403,43 @@ func findStringSubmatchMap(r *regexp.Regexp, s string) map[string]string {
 
 	return result
 }
+
+func (s *spotifyStreamingService) newArtist(artist *spotify.FullArtist) *model.Artist {
+	res := model.NewArtist(
+		artist.Name,
+		imageURL(artist.Images),
+		s.Key(),
+		model.DefaultMarket,
+		artist.ExternalURLs["spotify"])
+	res.ServiceId = artist.ID.String()
+
+	return res
+}
+
+func (s *spotifyStreamingService) newAlbum(album *spotify.SimpleAlbum) *model.Album {
+	res := model.NewAlbum(
+		album.Name,
+		artistName(album.Artists),
+		imageURL(album.Images),
+		s.Key(),
+		model.DefaultMarket,
+		album.ExternalURLs["spotify"])
+	res.ServiceId = album.ID.String()
+
+	return res
+}
+
+func (s *spotifyStreamingService) newTrack(track *spotify.FullTrack) *model.Track {
+	res := model.NewTrack(
+		track.ExternalIDs["isrc"],
+		track.Name,
+		artistName(track.Artists),
+		track.Album.Name,
+		imageURL(track.Album.Images),
+		s.Key(),
+		model.DefaultMarket,
+		track.ExternalURLs["spotify"])
+	res.ServiceId = track.ID.String()
+
+	return res
+}
@@ -0,0 +1,243 @@
+package spotify_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/metrics"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+	"github.com/yukitsune/maestro/pkg/streamingservice/spotify"
+)
+
+func newTestService(t *testing.T) streamingservice.StreamingService {
+	fixturesDir := filepath.Join("testdata", "fixtures")
+
+	accountsSrv, err := clients.NewFixtureServer(fixturesDir, "https://accounts.spotify.com")
+	require.NoError(t, err)
+	t.Cleanup(accountsSrv.Close)
+
+	apiSrv, err := clients.NewFixtureServer(fixturesDir, "https://api.spotify.com")
+	require.NoError(t, err)
+	t.Cleanup(apiSrv.Close)
+
+	// Real credentials are only needed when re-recording the fixtures
+	v := viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetEnvPrefix("MAESTRO")
+	v.AutomaticEnv()
+	v.SetDefault("services.spotify.client_id", "test-client-id")
+	v.SetDefault("services.spotify.client_secret", "test-client-secret")
+	v.Set("services.spotify.accounts_url", accountsSrv.URL)
+	v.Set("services.spotify.api_url", apiSrv.URL)
+
+	svc, err := spotify.NewSpotifyStreamingService(config.NewSpotifyViperConfig(v), metrics.NewNoopMetricsRecorder(), http.DefaultTransport)
+	require.NoError(t, err)
+
+	return svc
+}
+
+func Test_GetFromLink(t *testing.T) {
+	testCases := []struct {
+		name       string
+		link       string
+		expectType model.Type
+		expect     any
+	}{
+		{
+			name:       "artist",
+			link:       "https://open.spotify.com/artist/1LB8qB5BPb3MHQrfkvifXU",
+			expectType: model.ArtistType,
+			expect: &model.Artist{
+				Name:        "Cheap Trick",
+				ArtworkLink: "https://i.scdn.co/image/cheap-trick-640",
+				Source:      model.SpotifyStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://open.spotify.com/artist/1LB8qB5BPb3MHQrfkvifXU",
+				ServiceId:   "1LB8qB5BPb3MHQrfkvifXU",
+			},
+		},
+		{
+			name:       "album",
+			link:       "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+			expectType: model.AlbumType,
+			expect: &model.Album{
+				Upc:         "074643529821",
+				Name:        "Heaven Tonight",
+				ArtistNames: []string{"Cheap Trick"},
+				ArtworkLink: "https://i.scdn.co/image/heaven-tonight-640",
+				Source:      model.SpotifyStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+				ServiceId:   "5Sb8ORG8KwH8ipXYDbTMiJ",
+			},
+		},
+		{
+			name:       "track",
+			link:       "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb?si=10587ef152a8493f",
+			expectType: model.TrackType,
+			expect: &model.Track{
+				Isrc:        "USSM17800845",
+				Name:        "Surrender",
+				ArtistNames: []string{"Cheap Trick"},
+				AlbumName:   "Heaven Tonight",
+				ArtworkLink: "https://i.scdn.co/image/heaven-tonight-640",
+				Source:      model.SpotifyStreamingService,
+				Market:      model.DefaultMarket,
+				Link:        "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb",
+				ServiceId:   "3RWpQY6JbJqUkSpFvyTRPb",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			typ, res, err := svc.GetFromLink(testCase.link)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectType, typ)
+			assert.Equal(t, testCase.expect, res)
+		})
+	}
+}
+
+func Test_SearchArtist(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchArtist(&model.Artist{Name: "Cheap Trick", Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Cheap Trick", res.Name)
+	assert.Equal(t, "https://open.spotify.com/artist/1LB8qB5BPb3MHQrfkvifXU", res.Link)
+	assert.Equal(t, model.SpotifyStreamingService, res.Source)
+}
+
+func Test_SearchAlbum(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchAlbum(&model.Album{Name: "Heaven Tonight", ArtistNames: []string{"Cheap Trick"}, Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "Heaven Tonight", res.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, res.ArtistNames)
+	assert.Equal(t, "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ", res.Link)
+}
+
+func Test_SearchTrack(t *testing.T) {
+	svc := newTestService(t)
+
+	res, found, err := svc.SearchTrack(&model.Track{Name: "Surrender", AlbumName: "Heaven Tonight", ArtistNames: []string{"Cheap Trick"}, Market: model.DefaultMarket})
+	require.NoError(t, err)
+	require.True(t, found)
+
+	assert.Equal(t, "USSM17800845", res.Isrc)
+	assert.Equal(t, "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb", res.Link)
+}
+
+func Test_GetTrackByIsrc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		isrc        string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known isrc",
+			isrc:        "USSM17800845",
+			expectFound: true,
+			expectLink:  "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb",
+		},
+		{
+			name:        "unknown isrc",
+			isrc:        "XX0000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetTrackByIsrc(testCase.isrc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.isrc, res.Isrc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_GetAlbumByUpc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		upc         string
+		expectFound bool
+		expectLink  string
+	}{
+		{
+			name:        "known upc",
+			upc:         "074643529821",
+			expectFound: true,
+			expectLink:  "https://open.spotify.com/album/5Sb8ORG8KwH8ipXYDbTMiJ",
+		},
+		{
+			name:        "unknown upc",
+			upc:         "000000000000",
+			expectFound: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			svc := newTestService(t)
+
+			res, found, err := svc.GetAlbumByUpc(testCase.upc)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectFound, found)
+
+			if testCase.expectFound {
+				assert.Equal(t, testCase.upc, res.Upc)
+				assert.Equal(t, testCase.expectLink, res.Link)
+			}
+		})
+	}
+}
+
+func Test_GetFromLinkForRemovedTrack(t *testing.T) {
+	svc := newTestService(t)
+
+	typ, res, err := svc.GetFromLink("https://open.spotify.com/track/0DeadTrack000000000000")
+	require.Error(t, err)
+	assert.True(t, streamingservice.IsNotFound(err))
+	assert.Equal(t, model.UnknownType, typ)
+	assert.Nil(t, res)
+}
+
+func Test_Search(t *testing.T) {
+	svc := newTestService(t)
+
+	res, err := svc.Search("Cheap Trick Surrender", model.TrackType, 5)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	track, ok := res[0].(*model.Track)
+	require.True(t, ok)
+	assert.Equal(t, "Surrender", track.Name)
+	assert.Equal(t, []string{"Cheap Trick"}, track.ArtistNames)
+	assert.Equal(t, "Heaven Tonight", track.AlbumName)
+	assert.Equal(t, "USSM17800845", track.Isrc)
+	assert.Equal(t, "https://open.spotify.com/track/3RWpQY6JbJqUkSpFvyTRPb", track.Link)
+}
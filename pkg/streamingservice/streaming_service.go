@@ -15,9 +15,13 @@ type StreamingService interface {
 	SearchArtist(artist *model.Artist) (*model.Artist, bool, error)
 
 	SearchAlbum(album *model.Album) (*model.Album, bool, error)
+	GetAlbumByUpc(upc string) (*model.Album, bool, error)
 
 	SearchTrack(song *model.Track) (*model.Track, bool, error)
 	GetTrackByIsrc(isrc string) (*model.Track, bool, error)
 
 	GetFromLink(link string) (model.Type, interface{}, error)
+
+	// Search finds up to limit things of the given type which match the free-text query, best matches first
+	Search(query string, typ model.Type, limit int) ([]model.Thing, error)
 }
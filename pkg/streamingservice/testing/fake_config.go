@@ -0,0 +1,44 @@
+package testing
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+type FakeConfig struct {
+	key     model.StreamingServiceType
+	mu      sync.Mutex
+	enabled bool
+}
+
+func NewFakeConfig(key model.StreamingServiceType) *FakeConfig {
+	return &FakeConfig{key: key, enabled: true}
+}
+
+func (c *FakeConfig) Type() model.StreamingServiceType {
+	return c.key
+}
+
+func (c *FakeConfig) Name() string {
+	return c.key.String()
+}
+
+func (c *FakeConfig) LogoFileName() string {
+	return fmt.Sprintf("%s.png", c.key)
+}
+
+func (c *FakeConfig) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.enabled
+}
+
+func (c *FakeConfig) setEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.enabled = enabled
+}
@@ -0,0 +1,66 @@
+package testing
+
+import (
+	"fmt"
+
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+type fakeServiceProvider struct {
+	services map[model.StreamingServiceType]*FakeStreamingService
+}
+
+func NewFakeServiceProvider(services ...*FakeStreamingService) streamingservice.ServiceProvider {
+	svcMap := make(map[model.StreamingServiceType]*FakeStreamingService)
+	for _, service := range services {
+		svcMap[service.Key()] = service
+	}
+
+	return &fakeServiceProvider{svcMap}
+}
+
+func (p *fakeServiceProvider) GetService(key model.StreamingServiceType) (streamingservice.StreamingService, error) {
+	svc, ok := p.services[key]
+	if !ok {
+		return nil, fmt.Errorf("couldn't find service type %s", key)
+	}
+
+	return svc, nil
+}
+
+func (p *fakeServiceProvider) ListServices() (streamingservice.StreamingServices, error) {
+	svcs := make(streamingservice.StreamingServices)
+	for key, svc := range p.services {
+		if !svc.Config().Enabled() {
+			continue
+		}
+
+		svcs[key] = svc
+	}
+
+	return svcs, nil
+}
+
+func (p *fakeServiceProvider) GetConfig(key model.StreamingServiceType) (config.Service, error) {
+	svc, ok := p.services[key]
+	if !ok {
+		return nil, fmt.Errorf("couldn't find service config with key %s", key)
+	}
+
+	return svc.Config(), nil
+}
+
+func (p *fakeServiceProvider) ListConfigs() map[model.StreamingServiceType]config.Service {
+	cfgs := make(map[model.StreamingServiceType]config.Service)
+	for key, svc := range p.services {
+		cfgs[key] = svc.Config()
+	}
+
+	return cfgs
+}
+
+func (p *fakeServiceProvider) GetCircuitState(_ model.StreamingServiceType) model.CircuitState {
+	return model.CircuitClosed
+}
@@ -0,0 +1,348 @@
+package testing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// FakeStreamingService is a scriptable streaming service for use in tests.
+// Things added via the With* methods can be found by link, by name, or by ISRC.
+type FakeStreamingService struct {
+	config  *FakeConfig
+	mu      sync.Mutex
+	artists []*model.Artist
+	albums  []*model.Album
+	tracks  []*model.Track
+	err     error
+	delay   time.Duration
+	calls   int
+}
+
+func NewFakeStreamingService(key model.StreamingServiceType) *FakeStreamingService {
+	return &FakeStreamingService{
+		config: NewFakeConfig(key),
+	}
+}
+
+// LinkFor builds a link which belongs to the service with the given key
+func LinkFor(key model.StreamingServiceType, typ model.Type, id string) string {
+	return fmt.Sprintf("%s%s/%s", linkPrefix(key), typ, id)
+}
+
+func linkPrefix(key model.StreamingServiceType) string {
+	return fmt.Sprintf("https://%s.test/", key)
+}
+
+func (s *FakeStreamingService) Key() model.StreamingServiceType {
+	return s.config.Type()
+}
+
+// Link builds a link which belongs to this service
+func (s *FakeStreamingService) Link(typ model.Type, id string) string {
+	return LinkFor(s.Key(), typ, id)
+}
+
+func (s *FakeStreamingService) WithArtists(artists ...*model.Artist) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.artists = append(s.artists, artists...)
+	return s
+}
+
+func (s *FakeStreamingService) WithAlbums(albums ...*model.Album) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.albums = append(s.albums, albums...)
+	return s
+}
+
+func (s *FakeStreamingService) WithTracks(tracks ...*model.Track) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tracks = append(s.tracks, tracks...)
+	return s
+}
+
+// Remove makes the things with the given links disappear from the service, as if they were taken down
+func (s *FakeStreamingService) Remove(links ...string) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, link := range links {
+		s.artists = without(s.artists, link, func(a *model.Artist) string { return a.Link })
+		s.albums = without(s.albums, link, func(a *model.Album) string { return a.Link })
+		s.tracks = without(s.tracks, link, func(t *model.Track) string { return t.Link })
+	}
+
+	return s
+}
+
+// WithError makes every subsequent call to the service fail with the given error
+func (s *FakeStreamingService) WithError(err error) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+	return s
+}
+
+// WithDelay makes every subsequent call to the service take at least the given duration
+func (s *FakeStreamingService) WithDelay(delay time.Duration) *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delay = delay
+	return s
+}
+
+func (s *FakeStreamingService) Disabled() *FakeStreamingService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config.setEnabled(false)
+	return s
+}
+
+// Calls returns the number of times the service has been queried
+func (s *FakeStreamingService) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func (s *FakeStreamingService) Config() config.Service {
+	return s.config
+}
+
+func (s *FakeStreamingService) LinkBelongsToService(link string) bool {
+	return strings.HasPrefix(link, linkPrefix(s.Key()))
+}
+
+func (s *FakeStreamingService) CleanLink(link string) string {
+	if !s.LinkBelongsToService(link) {
+		return link
+	}
+
+	if i := strings.Index(link, "?"); i >= 0 {
+		return link[:i]
+	}
+
+	return link
+}
+
+func (s *FakeStreamingService) SearchArtist(artist *model.Artist) (*model.Artist, bool, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, a := range s.artists {
+		if strings.EqualFold(a.Name, artist.Name) {
+			return copyOf(a), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (s *FakeStreamingService) SearchAlbum(album *model.Album) (*model.Album, bool, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, a := range s.albums {
+		if strings.EqualFold(a.Name, album.Name) {
+			return copyOf(a), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (s *FakeStreamingService) GetAlbumByUpc(upc string) (*model.Album, bool, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, a := range s.albums {
+		if len(a.Upc) > 0 && a.Upc == upc {
+			return copyOf(a), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (s *FakeStreamingService) SearchTrack(track *model.Track) (*model.Track, bool, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, t := range s.tracks {
+		if len(track.Isrc) > 0 && t.Isrc == track.Isrc {
+			return copyOf(t), true, nil
+		}
+
+		if len(track.Isrc) == 0 && strings.EqualFold(t.Name, track.Name) {
+			return copyOf(t), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (s *FakeStreamingService) GetTrackByIsrc(isrc string) (*model.Track, bool, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, false, s.err
+	}
+
+	for _, t := range s.tracks {
+		if t.Isrc == isrc {
+			return copyOf(t), true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (s *FakeStreamingService) GetFromLink(link string) (model.Type, interface{}, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return model.UnknownType, nil, s.err
+	}
+
+	for _, a := range s.artists {
+		if a.Link == link {
+			return model.ArtistType, copyOf(a), nil
+		}
+	}
+
+	for _, a := range s.albums {
+		if a.Link == link {
+			return model.AlbumType, copyOf(a), nil
+		}
+	}
+
+	for _, t := range s.tracks {
+		if t.Link == link {
+			return model.TrackType, copyOf(t), nil
+		}
+	}
+
+	return model.UnknownType, nil, nil
+}
+
+// Search matches things whose artist names and name contain every word of the query
+func (s *FakeStreamingService) Search(query string, typ model.Type, limit int) ([]model.Thing, error) {
+	s.call()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var res []model.Thing
+	switch typ {
+	case model.ArtistType:
+		for _, a := range s.artists {
+			if matchesQuery(query, a.Name) {
+				res = append(res, copyOf(a))
+			}
+		}
+	case model.AlbumType:
+		for _, a := range s.albums {
+			if matchesQuery(query, strings.Join(a.ArtistNames, " "), a.Name) {
+				res = append(res, copyOf(a))
+			}
+		}
+	case model.TrackType:
+		for _, t := range s.tracks {
+			if matchesQuery(query, strings.Join(t.ArtistNames, " "), t.Name) {
+				res = append(res, copyOf(t))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+func matchesQuery(query string, fields ...string) bool {
+	text := strings.ToLower(strings.Join(fields, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *FakeStreamingService) call() {
+	s.mu.Lock()
+	s.calls++
+	delay := s.delay
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+func copyOf[T any](v *T) *T {
+	c := *v
+	return &c
+}
+
+func without[T any](things []*T, link string, linkOf func(*T) string) []*T {
+	var res []*T
+	for _, thing := range things {
+		if linkOf(thing) != link {
+			res = append(res, thing)
+		}
+	}
+
+	return res
+}
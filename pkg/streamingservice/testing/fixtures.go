@@ -0,0 +1,61 @@
+package testing
+
+import (
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+// Isrc is the ISRC of the Track
+const Isrc = "USUM71703861"
+
+// ServiceKeys are the services which NewFakeStreamingServices creates fakes for by default
+var ServiceKeys = []model.StreamingServiceType{
+	model.AppleMusicStreamingService,
+	model.SpotifyStreamingService,
+	model.DeezerStreamingService,
+}
+
+// Artist, Album, and Track build the same thing as it would be found on the given service.
+// None of them have an ID set, as that's up to whatever stores them.
+func Artist(key model.StreamingServiceType) *model.Artist {
+	return model.NewArtist("Cheap Trick", "", key, model.DefaultMarket, LinkFor(key, model.ArtistType, "cheap-trick"))
+}
+
+func Album(key model.StreamingServiceType) *model.Album {
+	return model.NewAlbum("Heaven Tonight", []string{"Cheap Trick"}, "", key, model.DefaultMarket, LinkFor(key, model.AlbumType, "heaven-tonight"))
+}
+
+func Track(key model.StreamingServiceType) *model.Track {
+	return model.NewTrack(Isrc, "Surrender", []string{"Cheap Trick"}, "Heaven Tonight", "", key, model.DefaultMarket, LinkFor(key, model.TrackType, "surrender"))
+}
+
+// FakeStreamingServices are fakes for a few services, keyed by the service they stand in for
+type FakeStreamingServices map[model.StreamingServiceType]*FakeStreamingService
+
+// NewFakeStreamingServices creates a fake for each of the given services, or ServiceKeys if none are given.
+// Each of them knows about the Artist, Album, and Track.
+func NewFakeStreamingServices(keys ...model.StreamingServiceType) FakeStreamingServices {
+	if len(keys) == 0 {
+		keys = ServiceKeys
+	}
+
+	services := make(FakeStreamingServices)
+	for _, key := range keys {
+		services[key] = NewFakeStreamingService(key).
+			WithArtists(Artist(key)).
+			WithAlbums(Album(key)).
+			WithTracks(Track(key))
+	}
+
+	return services
+}
+
+// Provider creates a ServiceProvider for the fakes
+func (s FakeStreamingServices) Provider() streamingservice.ServiceProvider {
+	var fakes []*FakeStreamingService
+	for _, fake := range s {
+		fakes = append(fakes, fake)
+	}
+
+	return NewFakeServiceProvider(fakes...)
+}
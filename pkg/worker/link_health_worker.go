@@ -0,0 +1,317 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+// LinkHealthWorker periodically checks that the links we've stored still exist on their service.
+// Links which have been taken down are marked as dead so that they're no longer returned. Dead tracks and albums
+// are re-matched by their ISRC or UPC straight away, anything else is left for the ResolveWorker to search for.
+type LinkHealthWorker struct {
+	cfg             config.LinkHealthWorker
+	serviceProvider streamingservice.ServiceProvider
+	repo            db.Repository
+	logger          *logrus.Logger
+	now             func() time.Time
+}
+
+func NewLinkHealthWorker(cfg config.LinkHealthWorker, serviceProvider streamingservice.ServiceProvider, repo db.Repository, logger *logrus.Logger) *LinkHealthWorker {
+	return &LinkHealthWorker{
+		cfg:             cfg,
+		serviceProvider: serviceProvider,
+		repo:            repo,
+		logger:          logger,
+		now:             time.Now,
+	}
+}
+
+// Run verifies links straight away, then again every interval until the context is cancelled
+func (w *LinkHealthWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			w.logger.Errorf("failed to verify links: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce verifies a batch of artists, albums, and tracks which haven't been verified recently
+func (w *LinkHealthWorker) RunOnce(ctx context.Context) error {
+	services, err := w.serviceProvider.ListServices()
+	if err != nil {
+		return err
+	}
+
+	if len(services) == 0 {
+		return nil
+	}
+
+	// Links from disabled services can't be checked, so they're left alone until the service is enabled again
+	var keys []model.StreamingServiceType
+	for key := range services {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	checkedBefore := w.now().Add(-w.cfg.VerifyInterval())
+
+	artists, err := w.repo.GetArtistsToVerify(ctx, keys, checkedBefore, w.batchSize())
+	if err != nil {
+		return err
+	}
+
+	forEach(ctx, w, artists, func(artist *model.Artist) error {
+		return w.verifyArtist(ctx, artist, services)
+	})
+
+	albums, err := w.repo.GetAlbumsToVerify(ctx, keys, checkedBefore, w.batchSize())
+	if err != nil {
+		return err
+	}
+
+	forEach(ctx, w, albums, func(album *model.Album) error {
+		return w.verifyAlbum(ctx, album, services)
+	})
+
+	tracks, err := w.repo.GetTracksToVerify(ctx, keys, checkedBefore, w.batchSize())
+	if err != nil {
+		return err
+	}
+
+	forEach(ctx, w, tracks, func(track *model.Track) error {
+		return w.verifyTrack(ctx, track, services)
+	})
+
+	return ctx.Err()
+}
+
+func (w *LinkHealthWorker) verifyArtist(ctx context.Context, artist *model.Artist, services streamingservice.StreamingServices) error {
+	now := w.now()
+	artist.LastChecked = &now
+
+	dead, err := isDead(services[artist.Source], artist.Link)
+	if err != nil {
+		// The link waits its turn like everything else, otherwise it'd be first in line every time and hold up the rest
+		if updateErr := w.repo.UpdateArtistHealth(ctx, artist); updateErr != nil {
+			return updateErr
+		}
+
+		return err
+	}
+
+	artist.LastVerified = &now
+	artist.Dead = dead
+	if err := w.repo.UpdateArtistHealth(ctx, artist); err != nil {
+		return err
+	}
+
+	// Artists don't have anything like an ISRC, so the ResolveWorker will need to search for them by name
+	if dead {
+		w.logger.WithField("link", artist.Link).Infoln("artist is dead")
+	}
+
+	return nil
+}
+
+func (w *LinkHealthWorker) verifyAlbum(ctx context.Context, album *model.Album, services streamingservice.StreamingServices) error {
+	now := w.now()
+	album.LastChecked = &now
+
+	svc := services[album.Source]
+	dead, err := isDead(svc, album.Link)
+	if err != nil {
+		if updateErr := w.repo.UpdateAlbumHealth(ctx, album); updateErr != nil {
+			return updateErr
+		}
+
+		return err
+	}
+
+	album.LastVerified = &now
+	album.Dead = dead
+	if err := w.repo.UpdateAlbumHealth(ctx, album); err != nil {
+		return err
+	}
+
+	if !dead {
+		return nil
+	}
+
+	logger := w.logger.WithField("link", album.Link)
+	logger.Infoln("album is dead")
+
+	// Without a UPC, the ResolveWorker will need to search for the album by name
+	if len(album.Upc) == 0 {
+		return nil
+	}
+
+	replacement, found, err := svc.GetAlbumByUpc(album.Upc)
+	if err != nil || !found {
+		return err
+	}
+
+	existing, err := w.repo.GetAlbumByLink(ctx, replacement.Link)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	replacement.AlbumId = album.AlbumId
+	replacement.LastChecked = &now
+	replacement.LastVerified = &now
+	if _, err := w.repo.AddAlbum(ctx, []*model.Album{replacement}); err != nil {
+		return err
+	}
+
+	logger.Infof("re-matched album to %s", replacement.Link)
+	return nil
+}
+
+func (w *LinkHealthWorker) verifyTrack(ctx context.Context, track *model.Track, services streamingservice.StreamingServices) error {
+	now := w.now()
+	track.LastChecked = &now
+
+	svc := services[track.Source]
+	dead, err := isDead(svc, track.Link)
+	if err != nil {
+		if updateErr := w.repo.UpdateTrackHealth(ctx, track); updateErr != nil {
+			return updateErr
+		}
+
+		return err
+	}
+
+	track.LastVerified = &now
+	track.Dead = dead
+	if err := w.repo.UpdateTrackHealth(ctx, track); err != nil {
+		return err
+	}
+
+	if !dead {
+		return nil
+	}
+
+	logger := w.logger.WithField("link", track.Link)
+	logger.Infoln("track is dead")
+
+	if len(track.Isrc) == 0 {
+		return nil
+	}
+
+	replacement, found, err := svc.GetTrackByIsrc(track.Isrc)
+	if err != nil || !found {
+		return err
+	}
+
+	existing, err := w.repo.GetTrackByLink(ctx, replacement.Link)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	replacement.LastChecked = &now
+	replacement.LastVerified = &now
+	if _, err := w.repo.AddTracks(ctx, []*model.Track{replacement}); err != nil {
+		return err
+	}
+
+	logger.Infof("re-matched track to %s", replacement.Link)
+	return nil
+}
+
+// isDead asks the service about the given link. Failures which don't tell us anything about the link itself
+// (timeouts, rate limits, etc.) are returned as errors, and the link is tried again once it's due to be verified.
+func isDead(svc streamingservice.StreamingService, link string) (bool, error) {
+	_, res, err := svc.GetFromLink(link)
+	if err != nil {
+		if streamingservice.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return res == nil, nil
+}
+
+// forEach calls fn for each thing, running up to the configured concurrency at once
+func forEach[T model.Thing](ctx context.Context, w *LinkHealthWorker, things []T, fn func(T) error) {
+	sem := make(chan struct{}, w.concurrency())
+	wg := sync.WaitGroup{}
+
+	for _, thing := range things {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(thing T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := fn(thing); err != nil {
+				logLinkError(w.logger, thing.GetLink(), err)
+			}
+		}(thing)
+	}
+
+	wg.Wait()
+}
+
+func logLinkError(logger *logrus.Logger, link string, err error) {
+	// Services with an open circuit are expected to fail, so there's no need to be loud about it
+	if errors.Is(err, streamingservice.ErrCircuitOpen) {
+		logger.Debugf("failed to verify %s: %s", link, err.Error())
+		return
+	}
+
+	logger.Warnf("failed to verify %s: %s", link, err.Error())
+}
+
+func (w *LinkHealthWorker) concurrency() int {
+	if n := w.cfg.Concurrency(); n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+func (w *LinkHealthWorker) interval() time.Duration {
+	if d := w.cfg.Interval(); d > 0 {
+		return d
+	}
+
+	return time.Hour
+}
+
+func (w *LinkHealthWorker) batchSize() int {
+	if n := w.cfg.BatchSize(); n > 0 {
+		return n
+	}
+
+	return 100
+}
@@ -0,0 +1,247 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/clients"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"github.com/yukitsune/maestro/pkg/worker"
+)
+
+const testUpc = "074643529821"
+
+type linkHealthFixture struct {
+	repo     db.Repository
+	services sstesting.FakeStreamingServices
+	worker   *worker.LinkHealthWorker
+}
+
+func newLinkHealthFixture(t *testing.T) *linkHealthFixture {
+	keys := []model.StreamingServiceType{model.SpotifyStreamingService, model.DeezerStreamingService}
+	services := sstesting.NewFakeStreamingServices(keys...)
+
+	var artists []*model.Artist
+	var albums []*model.Album
+	var tracks []*model.Track
+	for _, key := range keys {
+		album := withAlbumId(sstesting.Album(key), "album-1")
+		album.Upc = testUpc
+
+		artists = append(artists, withArtistId(sstesting.Artist(key), "artist-1"))
+		albums = append(albums, album)
+		tracks = append(tracks, sstesting.Track(key))
+	}
+
+	v := viper.New()
+	v.Set("workers.link_health.verify_interval", time.Hour)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := db.NewInMemoryRepository()
+	w := worker.NewLinkHealthWorker(config.NewLinkHealthWorkerViperConfig(v), services.Provider(), repo, logger)
+
+	ctx := context.Background()
+	_, err := repo.AddArtist(ctx, artists)
+	require.NoError(t, err)
+
+	_, err = repo.AddAlbum(ctx, albums)
+	require.NoError(t, err)
+
+	_, err = repo.AddTracks(ctx, tracks)
+	require.NoError(t, err)
+
+	return &linkHealthFixture{repo, services, w}
+}
+
+func Test_LinkHealthWorkerVerifiesLiveLinks(t *testing.T) {
+	f := newLinkHealthFixture(t)
+	ctx := context.Background()
+
+	require.NoError(t, f.worker.RunOnce(ctx))
+
+	tracks, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	require.Len(t, tracks, 2)
+	for _, track := range tracks {
+		assert.NotNil(t, track.LastVerified)
+		assert.False(t, track.Dead)
+	}
+
+	// Everything has only just been verified, so there's nothing to do
+	callsBefore := f.services[model.SpotifyStreamingService].Calls()
+	require.NoError(t, f.worker.RunOnce(ctx))
+	assert.Equal(t, callsBefore, f.services[model.SpotifyStreamingService].Calls())
+}
+
+func Test_LinkHealthWorkerMarksDeadLinks(t *testing.T) {
+	f := newLinkHealthFixture(t)
+	ctx := context.Background()
+
+	artistLink := sstesting.Artist(model.DeezerStreamingService).Link
+	f.services[model.DeezerStreamingService].Remove(artistLink)
+
+	require.NoError(t, f.worker.RunOnce(ctx))
+
+	artist, err := f.repo.GetArtistByLink(ctx, artistLink)
+	require.NoError(t, err)
+	assert.True(t, artist.Dead)
+	assert.NotNil(t, artist.LastVerified)
+
+	// There's nothing to re-match artists with, it's up to the resolve worker to find it again
+	ids, err := f.repo.GetIncompleteArtistIds(ctx, []model.StreamingServiceType{model.SpotifyStreamingService, model.DeezerStreamingService}, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"artist-1"}, ids)
+}
+
+func Test_LinkHealthWorkerRematchesDeadLinks(t *testing.T) {
+	f := newLinkHealthFixture(t)
+	ctx := context.Background()
+
+	spotify := f.services[model.SpotifyStreamingService]
+
+	oldAlbum := sstesting.Album(model.SpotifyStreamingService)
+	newAlbum := sstesting.Album(model.SpotifyStreamingService)
+	newAlbum.Upc = testUpc
+	newAlbum.Link = sstesting.LinkFor(model.SpotifyStreamingService, model.AlbumType, "heaven-tonight-remastered")
+
+	oldTrack := sstesting.Track(model.SpotifyStreamingService)
+	newTrack := sstesting.Track(model.SpotifyStreamingService)
+	newTrack.Link = sstesting.LinkFor(model.SpotifyStreamingService, model.TrackType, "surrender-remastered")
+
+	spotify.Remove(oldAlbum.Link, oldTrack.Link).
+		WithAlbums(newAlbum).
+		WithTracks(newTrack)
+
+	require.NoError(t, f.worker.RunOnce(ctx))
+
+	albums, err := f.repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Len(t, albums, 3)
+
+	dead, err := f.repo.GetAlbumByLink(ctx, oldAlbum.Link)
+	require.NoError(t, err)
+	assert.True(t, dead.Dead)
+
+	replacement, err := f.repo.GetAlbumByLink(ctx, newAlbum.Link)
+	require.NoError(t, err)
+	require.NotNil(t, replacement)
+	assert.Equal(t, "album-1", replacement.AlbumId)
+	assert.False(t, replacement.Dead)
+
+	tracks, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, tracks, 3)
+
+	replacementTrack, err := f.repo.GetTrackByLink(ctx, newTrack.Link)
+	require.NoError(t, err)
+	assert.NotNil(t, replacementTrack)
+}
+
+func Test_LinkHealthWorkerDoesNotMarkLinksDeadOnErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		expectDead bool
+	}{
+		{
+			name:       "not found",
+			err:        &clients.ResponseError{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+			expectDead: true,
+		},
+		{
+			name:       "server error",
+			err:        &clients.ResponseError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+			expectDead: false,
+		},
+		{
+			name:       "network error",
+			err:        fmt.Errorf("connection reset by peer"),
+			expectDead: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newLinkHealthFixture(t)
+			ctx := context.Background()
+
+			f.services[model.DeezerStreamingService].WithError(testCase.err)
+			require.NoError(t, f.worker.RunOnce(ctx))
+
+			track, err := f.repo.GetTrackByLink(ctx, sstesting.Track(model.DeezerStreamingService).Link)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectDead, track.Dead)
+
+			// Links are only verified when the service gives us a proper answer
+			assert.Equal(t, testCase.expectDead, track.LastVerified != nil)
+		})
+	}
+}
+
+func Test_LinkHealthWorkerMovesOnFromFailingLinks(t *testing.T) {
+	f := newLinkHealthFixture(t)
+	ctx := context.Background()
+
+	f.services[model.SpotifyStreamingService].WithError(&clients.ResponseError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"})
+
+	v := viper.New()
+	v.Set("workers.link_health.verify_interval", time.Hour)
+	v.Set("workers.link_health.batch_size", 1)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	w := worker.NewLinkHealthWorker(config.NewLinkHealthWorkerViperConfig(v), f.services.Provider(), f.repo, logger)
+
+	// Spotify's links come first, so they'd be picked every time if failing to check them didn't count
+	require.NoError(t, w.RunOnce(ctx))
+	require.NoError(t, w.RunOnce(ctx))
+
+	spotifyTrack, err := f.repo.GetTrackByLink(ctx, sstesting.Track(model.SpotifyStreamingService).Link)
+	require.NoError(t, err)
+	assert.NotNil(t, spotifyTrack.LastChecked)
+	assert.Nil(t, spotifyTrack.LastVerified)
+	assert.False(t, spotifyTrack.Dead)
+
+	deezerTrack, err := f.repo.GetTrackByLink(ctx, sstesting.Track(model.DeezerStreamingService).Link)
+	require.NoError(t, err)
+	assert.NotNil(t, deezerTrack.LastVerified)
+	assert.False(t, deezerTrack.Dead)
+}
+
+func Test_LinkHealthWorkerFallsBackToDefaults(t *testing.T) {
+	f := newLinkHealthFixture(t)
+
+	v := viper.New()
+	v.Set("workers.link_health.interval", 0)
+	v.Set("workers.link_health.batch_size", 0)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	w := worker.NewLinkHealthWorker(config.NewLinkHealthWorkerViperConfig(v), f.services.Provider(), f.repo, logger)
+
+	// Shouldn't panic
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+
+	require.NoError(t, w.RunOnce(context.Background()))
+
+	tracks, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	for _, track := range tracks {
+		assert.NotNil(t, track.LastVerified)
+	}
+}
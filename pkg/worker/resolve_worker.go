@@ -0,0 +1,219 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/streamingservice"
+)
+
+type resolveJob struct {
+	typ model.Type
+	id  string
+}
+
+func (j resolveJob) String() string {
+	return fmt.Sprintf("%s:%s", j.typ, j.id)
+}
+
+// Resolver looks for an artist or album with the given ID, or a track with the given ISRC, on any services it's
+// missing from, and returns how many more services it was found on
+type Resolver interface {
+	Resolve(ctx context.Context, typ model.Type, id string) (int, error)
+}
+
+// ResolveWorker periodically looks for artists, albums, and tracks which are missing from some services,
+// and searches for them on those services. This covers services which failed when the thing was first found,
+// as well as services which have been enabled since then.
+type ResolveWorker struct {
+	cfg             config.ResolveWorker
+	serviceProvider streamingservice.ServiceProvider
+	repo            db.Repository
+	resolver        Resolver
+	logger          *logrus.Logger
+	now             func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]time.Time
+}
+
+// NewResolveWorker creates a ResolveWorker. Things are resolved through the given Resolver, which should be the same
+// as the API's, so that the worker doesn't add the same things as a lookup which is happening at the same time.
+func NewResolveWorker(cfg config.ResolveWorker, serviceProvider streamingservice.ServiceProvider, repo db.Repository, resolver Resolver, logger *logrus.Logger) *ResolveWorker {
+	return &ResolveWorker{
+		cfg:             cfg,
+		serviceProvider: serviceProvider,
+		repo:            repo,
+		resolver:        resolver,
+		logger:          logger,
+		now:             time.Now,
+		attempts:        make(map[string]time.Time),
+	}
+}
+
+// Run resolves everything straight away, then again every interval until the context is cancelled
+func (w *ResolveWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			w.logger.Errorf("failed to resolve missing services: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce makes a single pass over everything which is missing from at least one service
+func (w *ResolveWorker) RunOnce(ctx context.Context) error {
+	services, err := w.serviceProvider.ListServices()
+	if err != nil {
+		return err
+	}
+
+	if len(services) == 0 {
+		return nil
+	}
+
+	var keys []model.StreamingServiceType
+	for key := range services {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	w.forgetOldAttempts()
+
+	jobs := make(chan resolveJob)
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				n, err := w.resolver.Resolve(ctx, job.typ, job.id)
+				if err != nil {
+					w.logger.Errorf("failed to resolve %s: %s", job, err.Error())
+					continue
+				}
+
+				if n > 0 {
+					w.logger.Infof("resolved %s on %d more services", job, n)
+				}
+			}
+		}()
+	}
+
+	err = w.scan(ctx, jobs, model.ArtistType, keys, w.repo.GetIncompleteArtistIds)
+	if err == nil {
+		err = w.scan(ctx, jobs, model.AlbumType, keys, w.repo.GetIncompleteAlbumIds)
+	}
+
+	if err == nil {
+		err = w.scan(ctx, jobs, model.TrackType, keys, w.repo.GetIncompleteIsrcs)
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return err
+}
+
+type incompleteIdsFunc func(ctx context.Context, services []model.StreamingServiceType, after string, limit int) ([]string, error)
+
+func (w *ResolveWorker) scan(ctx context.Context, jobs chan<- resolveJob, typ model.Type, keys []model.StreamingServiceType, getIds incompleteIdsFunc) error {
+	batchSize := w.batchSize()
+
+	after := ""
+	for {
+		ids, err := getIds(ctx, keys, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			job := resolveJob{typ, id}
+			if !w.shouldAttempt(job) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case jobs <- job:
+			}
+		}
+
+		if len(ids) < batchSize {
+			return nil
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+func (w *ResolveWorker) concurrency() int {
+	if n := w.cfg.Concurrency(); n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+func (w *ResolveWorker) interval() time.Duration {
+	if d := w.cfg.Interval(); d > 0 {
+		return d
+	}
+
+	return time.Hour
+}
+
+func (w *ResolveWorker) batchSize() int {
+	if n := w.cfg.BatchSize(); n > 0 {
+		return n
+	}
+
+	return 100
+}
+
+// shouldAttempt returns false if we've tried to resolve the same thing recently, otherwise it records the attempt
+func (w *ResolveWorker) shouldAttempt(job resolveJob) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := job.String()
+	now := w.now()
+	if last, ok := w.attempts[key]; ok && now.Sub(last) < w.cfg.RetryInterval() {
+		return false
+	}
+
+	w.attempts[key] = now
+	return true
+}
+
+func (w *ResolveWorker) forgetOldAttempts() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for key, last := range w.attempts {
+		if now.Sub(last) >= w.cfg.RetryInterval() {
+			delete(w.attempts, key)
+		}
+	}
+}
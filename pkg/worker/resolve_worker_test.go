@@ -0,0 +1,189 @@
+package worker_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/api/handlers"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	sstesting "github.com/yukitsune/maestro/pkg/streamingservice/testing"
+	"github.com/yukitsune/maestro/pkg/worker"
+	"golang.org/x/sync/singleflight"
+)
+
+type testFixture struct {
+	repo     db.Repository
+	services sstesting.FakeStreamingServices
+	worker   *worker.ResolveWorker
+}
+
+func newTestFixture(t *testing.T) *testFixture {
+	services := sstesting.NewFakeStreamingServices()
+
+	// A small batch size makes sure we can page through everything
+	v := viper.New()
+	v.Set("workers.resolve.batch_size", 1)
+	v.Set("workers.resolve.concurrency", 2)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := db.NewInMemoryRepository()
+	provider := services.Provider()
+	w := worker.NewResolveWorker(config.NewResolveWorkerViperConfig(v), provider, repo, handlers.NewResolver(provider, repo, &singleflight.Group{}, logger), logger)
+
+	// Everything is only on Spotify
+	ctx := context.Background()
+	_, err := repo.AddArtist(ctx, []*model.Artist{withArtistId(sstesting.Artist(model.SpotifyStreamingService), "artist-1")})
+	require.NoError(t, err)
+
+	_, err = repo.AddAlbum(ctx, []*model.Album{withAlbumId(sstesting.Album(model.SpotifyStreamingService), "album-1")})
+	require.NoError(t, err)
+
+	_, err = repo.AddTracks(ctx, []*model.Track{sstesting.Track(model.SpotifyStreamingService)})
+	require.NoError(t, err)
+
+	return &testFixture{repo, services, w}
+}
+
+func withArtistId(artist *model.Artist, id string) *model.Artist {
+	artist.ArtistId = id
+	return artist
+}
+
+func withAlbumId(album *model.Album, id string) *model.Album {
+	album.AlbumId = id
+	return album
+}
+
+func Test_ResolveWorkerFindsMissingServices(t *testing.T) {
+	f := newTestFixture(t)
+	ctx := context.Background()
+
+	err := f.worker.RunOnce(ctx)
+	require.NoError(t, err)
+
+	artists, err := f.repo.GetArtistsById(ctx, "artist-1")
+	require.NoError(t, err)
+	assert.Len(t, artists, 3)
+	for _, artist := range artists {
+		assert.Equal(t, "artist-1", artist.ArtistId)
+	}
+
+	albums, err := f.repo.GetAlbumsById(ctx, "album-1")
+	require.NoError(t, err)
+	assert.Len(t, albums, 3)
+	for _, album := range albums {
+		assert.Equal(t, "album-1", album.AlbumId)
+	}
+
+	tracks, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, tracks, 3)
+
+	// Spotify already had everything, so it shouldn't have been asked
+	assert.Equal(t, 0, f.services[model.SpotifyStreamingService].Calls())
+
+	// Everything is complete, so there's nothing left to do
+	ids, err := f.repo.GetIncompleteArtistIds(ctx, []model.StreamingServiceType{model.AppleMusicStreamingService, model.SpotifyStreamingService, model.DeezerStreamingService}, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func Test_ResolveWorkerSkipsDisabledServices(t *testing.T) {
+	f := newTestFixture(t)
+	f.services[model.DeezerStreamingService].Disabled()
+	ctx := context.Background()
+
+	err := f.worker.RunOnce(ctx)
+	require.NoError(t, err)
+
+	tracks, err := f.repo.GetTracksByIsrc(ctx, sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, tracks, 2)
+	assert.Equal(t, 0, f.services[model.DeezerStreamingService].Calls())
+}
+
+func Test_ResolveWorkerDoesNotRetryTooSoon(t *testing.T) {
+	f := newTestFixture(t)
+	deezer := sstesting.NewFakeStreamingService(model.DeezerStreamingService)
+	f.services[model.DeezerStreamingService] = deezer
+
+	var fakes []*sstesting.FakeStreamingService
+	for _, svc := range f.services {
+		fakes = append(fakes, svc)
+	}
+
+	v := viper.New()
+	v.Set("workers.resolve.retry_interval", time.Hour)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	provider := sstesting.NewFakeServiceProvider(fakes...)
+	w := worker.NewResolveWorker(config.NewResolveWorkerViperConfig(v), provider, f.repo, handlers.NewResolver(provider, f.repo, &singleflight.Group{}, logger), logger)
+
+	ctx := context.Background()
+	require.NoError(t, w.RunOnce(ctx))
+	assert.Equal(t, 3, deezer.Calls())
+
+	// Deezer doesn't have anything, but we've only just checked
+	require.NoError(t, w.RunOnce(ctx))
+	assert.Equal(t, 3, deezer.Calls())
+}
+
+func Test_ResolveWorkerStopsWhenCancelled(t *testing.T) {
+	f := newTestFixture(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.worker.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker didn't stop")
+	}
+}
+
+func Test_ResolveWorkerFallsBackToDefaults(t *testing.T) {
+	f := newTestFixture(t)
+
+	v := viper.New()
+	v.Set("workers.resolve.interval", 0)
+	v.Set("workers.resolve.batch_size", 0)
+	v.Set("workers.resolve.concurrency", 0)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var fakes []*sstesting.FakeStreamingService
+	for _, svc := range f.services {
+		fakes = append(fakes, svc)
+	}
+
+	provider := sstesting.NewFakeServiceProvider(fakes...)
+	w := worker.NewResolveWorker(config.NewResolveWorkerViperConfig(v), provider, f.repo, handlers.NewResolver(provider, f.repo, &singleflight.Group{}, logger), logger)
+
+	// Neither of these should panic
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+
+	require.NoError(t, w.RunOnce(context.Background()))
+
+	tracks, err := f.repo.GetTracksByIsrc(context.Background(), sstesting.Isrc)
+	require.NoError(t, err)
+	assert.Len(t, tracks, 3)
+}
@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+// TrendingWorker periodically works out what's trending from how often things have been looked up recently.
+// Each day's lookups count for less as they get older, so something which was popular last week won't stay at the top.
+type TrendingWorker struct {
+	cfg    config.TrendingWorker
+	repo   db.Repository
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+func NewTrendingWorker(cfg config.TrendingWorker, repo db.Repository, logger *logrus.Logger) *TrendingWorker {
+	return &TrendingWorker{
+		cfg:    cfg,
+		repo:   repo,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Run updates the trending scores straight away, then again every interval until the context is cancelled
+func (w *TrendingWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval())
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			w.logger.Errorf("failed to update trending scores: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce scores everything which has been looked up within each window, and keeps the highest scores of each type
+func (w *TrendingWorker) RunOnce(ctx context.Context) error {
+	now := w.now().UTC()
+
+	for _, window := range model.TrendingWindows {
+		since := now.Add(-window.Duration())
+		counts, err := w.repo.GetDailyCounts(ctx, model.ResolveEvent, since)
+		if err != nil {
+			return err
+		}
+
+		scores := trendingScores(counts, window, w.cfg.Limit(), since, now)
+		if err := w.repo.ReplaceTrending(ctx, window, scores); err != nil {
+			return err
+		}
+
+		w.logger.Debugf("updated %d trending scores for %s", len(scores), window)
+	}
+
+	return nil
+}
+
+func (w *TrendingWorker) interval() time.Duration {
+	if d := w.cfg.Interval(); d > 0 {
+		return d
+	}
+
+	return 15 * time.Minute
+}
+
+// trendingScores adds up the decayed counts for each thing since the start of the window, and keeps the highest of each type
+func trendingScores(counts []*model.AnalyticsCount, window model.TrendingWindow, limit int, since time.Time, now time.Time) []*model.TrendingScore {
+	type thing struct {
+		typ model.Type
+		id  string
+	}
+
+	byThing := make(map[thing]*model.TrendingScore)
+	for _, count := range counts {
+		key := thing{count.Type, count.Id}
+		if _, ok := byThing[key]; !ok {
+			byThing[key] = &model.TrendingScore{Window: window, Type: count.Type, Id: count.Id, Updated: now}
+		}
+
+		byThing[key].Score += float64(count.Count) * coverage(count.Day, since) * decay(count.Day, window, now)
+	}
+
+	byType := make(map[model.Type][]*model.TrendingScore)
+	for _, score := range byThing {
+		byType[score.Type] = append(byType[score.Type], score)
+	}
+
+	var scores []*model.TrendingScore
+	for _, typeScores := range byType {
+		sort.Slice(typeScores, func(i, j int) bool {
+			if typeScores[i].Score != typeScores[j].Score {
+				return typeScores[i].Score > typeScores[j].Score
+			}
+
+			return typeScores[i].Id < typeScores[j].Id
+		})
+
+		if limit > 0 && len(typeScores) > limit {
+			typeScores = typeScores[:limit]
+		}
+
+		scores = append(scores, typeScores...)
+	}
+
+	return scores
+}
+
+// coverage is how much of a day is within a window starting at the given time. Counts are only kept per day, so
+// the day the window starts on only counts for the part of it which is in the window, as if its lookups were spread
+// evenly across the day.
+func coverage(day time.Time, since time.Time) float64 {
+	if !since.After(day) {
+		return 1
+	}
+
+	return math.Max(0, day.Add(24*time.Hour).Sub(since).Hours()/24)
+}
+
+// decay is how much a day's lookups are worth now. Counts are only kept per day, so they're treated as if they all
+// happened in the middle of the day.
+func decay(day time.Time, window model.TrendingWindow, now time.Time) float64 {
+	age := now.Sub(day.Add(12 * time.Hour))
+	if age < 0 {
+		age = 0
+	}
+
+	return math.Pow(0.5, age.Hours()/window.HalfLife().Hours())
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/model"
+)
+
+func Test_TrendingScoresOnlyCountThePartOfTheFirstDayInTheWindow(t *testing.T) {
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	since := now.Add(-model.TrendingDay.Duration())
+	counts := []*model.AnalyticsCount{
+		{Day: model.Day(since), Type: model.TrackType, Id: "yesterday", Count: 8},
+		{Day: model.Day(now), Type: model.TrackType, Id: "today", Count: 8},
+	}
+
+	scores := trendingScores(counts, model.TrendingDay, 10, since, now)
+	require.Len(t, scores, 2)
+
+	byId := make(map[string]float64)
+	for _, score := range scores {
+		byId[score.Id] = score.Score
+	}
+
+	// The window starts at 18:00 yesterday, so only the last quarter of yesterday's lookups count
+	assert.InDelta(t, 8*0.25*decay(model.Day(since), model.TrendingDay, now), byId["yesterday"], 0.0001)
+	assert.InDelta(t, 8*decay(model.Day(now), model.TrendingDay, now), byId["today"], 0.0001)
+}
+
+func Test_Coverage(t *testing.T) {
+	day := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 1.0, coverage(day, day.Add(-time.Hour)), "days after the start of the window are all in it")
+	assert.Equal(t, 1.0, coverage(day, day), "a window starting at midnight covers the whole day")
+	assert.Equal(t, 0.5, coverage(day, day.Add(12*time.Hour)))
+	assert.Equal(t, 0.0, coverage(day, day.Add(30*time.Hour)), "days before the window aren't in it")
+}
@@ -0,0 +1,112 @@
+package worker_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukitsune/maestro/pkg/config"
+	"github.com/yukitsune/maestro/pkg/db"
+	"github.com/yukitsune/maestro/pkg/model"
+	"github.com/yukitsune/maestro/pkg/worker"
+)
+
+func newTrendingWorker(repo db.Repository, limit int) *worker.TrendingWorker {
+	v := viper.New()
+	v.Set("workers.trending.limit", limit)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return worker.NewTrendingWorker(config.NewTrendingWorkerViperConfig(v), repo, logger)
+}
+
+func recordResolutions(t *testing.T, repo db.Repository, typ model.Type, id string, n int, at time.Time) {
+	for i := 0; i < n; i++ {
+		err := repo.RecordEvent(context.Background(), &model.AnalyticsEvent{
+			Kind:   model.ResolveEvent,
+			Type:   typ,
+			Id:     id,
+			Source: model.SpotifyStreamingService,
+			Time:   at,
+		})
+
+		require.NoError(t, err)
+	}
+}
+
+func trendingIds(t *testing.T, repo db.Repository, window model.TrendingWindow, typ model.Type) []string {
+	scores, err := repo.GetTrending(context.Background(), window, typ, 10)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, score := range scores {
+		assert.Equal(t, window, score.Window)
+		assert.Greater(t, score.Score, 0.0)
+		ids = append(ids, score.Id)
+	}
+
+	return ids
+}
+
+func Test_TrendingWorker(t *testing.T) {
+	repo := db.NewInMemoryRepository()
+	now := time.Now()
+
+	recordResolutions(t, repo, model.TrackType, "old", 5, now.AddDate(0, 0, -5))
+	recordResolutions(t, repo, model.TrackType, "yesterday", 3, now.AddDate(0, 0, -1))
+	recordResolutions(t, repo, model.TrackType, "today", 4, now)
+	recordResolutions(t, repo, model.AlbumType, "album-1", 1, now)
+
+	// Clicks aren't lookups, so they don't count
+	require.NoError(t, repo.RecordEvent(context.Background(), &model.AnalyticsEvent{
+		Kind:   model.ClickEvent,
+		Type:   model.TrackType,
+		Id:     "clicked",
+		Target: model.SpotifyStreamingService,
+		Time:   now,
+	}))
+
+	require.NoError(t, newTrendingWorker(repo, 10).RunOnce(context.Background()))
+
+	assert.Equal(t, []string{"today", "yesterday"}, trendingIds(t, repo, model.TrendingDay, model.TrackType))
+
+	// Older lookups are still counted over a week, but for much less
+	assert.Equal(t, []string{"today", "yesterday", "old"}, trendingIds(t, repo, model.TrendingWeek, model.TrackType))
+	assert.Equal(t, []string{"album-1"}, trendingIds(t, repo, model.TrendingWeek, model.AlbumType))
+}
+
+func Test_TrendingWorkerKeepsTheHighestScoresOfEachType(t *testing.T) {
+	repo := db.NewInMemoryRepository()
+	now := time.Now()
+
+	recordResolutions(t, repo, model.TrackType, "first", 3, now)
+	recordResolutions(t, repo, model.TrackType, "second", 2, now)
+	recordResolutions(t, repo, model.TrackType, "third", 1, now)
+	recordResolutions(t, repo, model.AlbumType, "album-1", 1, now)
+
+	require.NoError(t, newTrendingWorker(repo, 2).RunOnce(context.Background()))
+
+	assert.Equal(t, []string{"first", "second"}, trendingIds(t, repo, model.TrendingDay, model.TrackType))
+	assert.Equal(t, []string{"album-1"}, trendingIds(t, repo, model.TrendingDay, model.AlbumType))
+}
+
+func Test_TrendingWorkerFallsBackToDefaultInterval(t *testing.T) {
+	v := viper.New()
+	v.Set("workers.trending.interval", 0)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	w := worker.NewTrendingWorker(config.NewTrendingWorkerViperConfig(v), db.NewInMemoryRepository(), logger)
+
+	// Shouldn't panic
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+}